@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"rdsdba/internal/output"
+	"rdsdba/pkg/engine"
+	"rdsdba/pkg/mysql"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	autoIncOK       = "ok"
+	autoIncWarn     = "warn"
+	autoIncCritical = "critical"
+)
+
+var (
+	// AutoIncCmd checks auto increment columns for exhaustion
+	AutoIncCmd = &cobra.Command{
+		Use:     "autoinc",
+		Aliases: []string{"auto-increment"},
+		Short:   "Check auto increment columns for exhaustion",
+		Long: `Compare the current AUTO_INCREMENT value of every table with the maximum of its column integer type and signedness,
+report the percent used with warn and critical thresholds. With --snapshot the values are saved to a file,
+the next run estimates the days left from the growth between both snapshots.
+Exit status is 2 when a table reaches the critical threshold.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			critical, err := autoIncRun()
+			if err != nil {
+				logger.Error().Err(err).Msg("")
+				os.Exit(1)
+			}
+			if critical {
+				os.Exit(2)
+			}
+		},
+	}
+	autoIncWarnPct     float64
+	autoIncCriticalPct float64
+	autoIncMinPct      float64
+	autoIncSnapshot    string
+)
+
+// autoIncSnapshotFile keeps the next auto increment values of a run by schema.table
+type autoIncSnapshotFile struct {
+	TakenAt time.Time         `json:"taken_at"`
+	Values  map[string]uint64 `json:"values"`
+}
+
+type autoIncReport struct {
+	engine.Table
+	Column  string  `json:"column"`
+	Type    string  `json:"type"`
+	Next    uint64  `json:"next"`
+	Max     uint64  `json:"max"`
+	UsedPct float64 `json:"used_pct"`
+	Status  string  `json:"status"`
+	// PerDay and DaysLeft are estimated from the previous snapshot, nil without one or without growth
+	PerDay   *float64 `json:"per_day,omitempty"`
+	DaysLeft *float64 `json:"days_left,omitempty"`
+}
+
+func init() {
+	RootCmd.AddCommand(AutoIncCmd)
+
+	AutoIncCmd.Flags().Float64Var(&autoIncWarnPct, "warn", 70, "percent used to warn at")
+	AutoIncCmd.Flags().Float64Var(&autoIncCriticalPct, "critical", 85, "percent used to report critical at")
+	AutoIncCmd.Flags().Float64Var(&autoIncMinPct, "min-used", 0, "only show columns with at least this percent used")
+	AutoIncCmd.Flags().StringVar(&autoIncSnapshot, "snapshot", "", "file to estimate growth from the previous run and save this run to")
+	AutoIncCmd.Flags().StringSliceVarP(&only, "only", "o", nil, "only check tables matching these patterns, comma separated format:schema_name.table_name, globs allowed")
+	AutoIncCmd.Flags().StringSliceVarP(&skip, "skip", "s", nil, "skip tables matching these patterns, comma separated format:schema_name.table_name, globs allowed")
+	addFormatFlag(AutoIncCmd)
+}
+
+// autoIncRun reports whether a table reached the critical threshold
+func autoIncRun() (bool, error) {
+	if err := output.CheckFormat(outputFormat); err != nil {
+		return false, err
+	}
+	filter, err := engine.NewTableFilter(only, skip)
+	if err != nil {
+		return false, err
+	}
+	previous, err := readAutoIncSnapshot(autoIncSnapshot)
+	if err != nil {
+		return false, err
+	}
+
+	i, err := mysql.NewInstance(cfg)
+	if err != nil {
+		return false, err
+	}
+	defer i.Close()
+
+	tables, err := i.TableInfos(context.Background())
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+
+	current := autoIncSnapshotFile{TakenAt: now, Values: make(map[string]uint64)}
+	var reports []autoIncReport
+	critical := false
+	for _, t := range tables {
+		if t.AutoIncMax == 0 || !filter.Match(t.Table) {
+			continue
+		}
+		current.Values[t.String()] = *t.AutoIncrement
+
+		r := autoIncReport{
+			Table:   t.Table,
+			Column:  t.AutoIncColumn,
+			Type:    t.AutoIncType,
+			Next:    *t.AutoIncrement,
+			Max:     t.AutoIncMax,
+			UsedPct: t.AutoIncUsed * 100,
+			Status:  autoIncOK,
+		}
+		switch {
+		case r.UsedPct >= autoIncCriticalPct:
+			r.Status = autoIncCritical
+			critical = true
+		case r.UsedPct >= autoIncWarnPct:
+			r.Status = autoIncWarn
+		}
+		if previous != nil {
+			estimateAutoIncGrowth(&r, previous, now)
+		}
+		if r.UsedPct >= autoIncMinPct {
+			reports = append(reports, r)
+		}
+	}
+	sort.SliceStable(reports, func(a, b int) bool { return reports[a].UsedPct > reports[b].UsedPct })
+
+	if len(autoIncSnapshot) > 0 {
+		if err = writeAutoIncSnapshot(autoIncSnapshot, current); err != nil {
+			return critical, err
+		}
+	}
+
+	header := []string{"TABLE", "COLUMN", "TYPE", "NEXT", "MAX", "USED", "STATUS", "PER_DAY", "DAYS_LEFT"}
+	rows := make([][]string, 0, len(reports))
+	for _, r := range reports {
+		perDay, daysLeft := "", ""
+		if r.PerDay != nil {
+			perDay = strconv.FormatFloat(*r.PerDay, 'f', 0, 64)
+		}
+		if r.DaysLeft != nil {
+			daysLeft = strconv.FormatFloat(*r.DaysLeft, 'f', 0, 64)
+		}
+		rows = append(rows, []string{
+			r.String(), r.Column, r.Type, strconv.FormatUint(r.Next, 10), strconv.FormatUint(r.Max, 10),
+			fmt.Sprintf("%.2f%%", r.UsedPct), r.Status, perDay, daysLeft,
+		})
+	}
+	if reports == nil {
+		reports = []autoIncReport{}
+	}
+	return critical, printReport(reports, header, rows)
+}
+
+// estimateAutoIncGrowth sets the daily growth since the previous snapshot and the days left at that pace
+func estimateAutoIncGrowth(r *autoIncReport, previous *autoIncSnapshotFile, now time.Time) {
+	before, ok := previous.Values[r.String()]
+	days := now.Sub(previous.TakenAt).Hours() / 24
+	// values going down mean the table was rebuilt or truncated
+	if !ok || days <= 0 || r.Next < before {
+		return
+	}
+	perDay := float64(r.Next-before) / days
+	r.PerDay = &perDay
+	if perDay > 0 {
+		// unsigned, Next 0 or past Max would wrap around to years left
+		daysLeft := 0.0
+		if r.Next > 0 && r.Next-1 < r.Max {
+			daysLeft = float64(r.Max-(r.Next-1)) / perDay
+		}
+		r.DaysLeft = &daysLeft
+	}
+}
+
+// readAutoIncSnapshot returns nil when no snapshot file given or it doesn't exist yet
+func readAutoIncSnapshot(path string) (*autoIncSnapshotFile, error) {
+	if len(path) == 0 {
+		return nil, nil
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		logger.Info().Str("snapshot", path).Msg("no previous snapshot, growth is estimated from the next run")
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s autoIncSnapshotFile
+	if err = json.Unmarshal(content, &s); err != nil {
+		return nil, fmt.Errorf("invalid snapshot %s: %w", path, err)
+	}
+	return &s, nil
+}
+
+func writeAutoIncSnapshot(path string, s autoIncSnapshotFile) error {
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0o644)
+}
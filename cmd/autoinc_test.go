@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"rdsdba/pkg/engine"
+)
+
+func TestEstimateAutoIncGrowth(t *testing.T) {
+	now := time.Date(2023, 2, 11, 0, 0, 0, 0, time.UTC)
+	table := engine.Table{SchemaName: "shop", TableName: "orders"}
+	previous := func(next uint64, daysAgo int) *autoIncSnapshotFile {
+		return &autoIncSnapshotFile{TakenAt: now.AddDate(0, 0, -daysAgo), Values: map[string]uint64{table.String(): next}}
+	}
+	value := func(f float64) *float64 { return &f }
+
+	tests := []struct {
+		name     string
+		next     uint64
+		max      uint64
+		previous *autoIncSnapshotFile
+		perDay   *float64
+		daysLeft *float64
+	}{
+		{"normal growth", 1001, 10000, previous(1, 10), value(100), value(90)},
+		{"no growth", 500, 10000, previous(500, 10), value(0), nil},
+		{"went backwards", 50, 10000, previous(100, 10), nil, nil},
+		{"next 0", 0, 255, previous(0, 10), value(0), nil},
+		{"exhausted", 256, 255, previous(156, 10), value(10), value(0)},
+		{"next above max", 300, 255, previous(200, 10), value(10), value(0)},
+		{"not in snapshot", 1001, 10000, &autoIncSnapshotFile{TakenAt: now.AddDate(0, 0, -10)}, nil, nil},
+		{"snapshot in the future", 1001, 10000, previous(1, -1), nil, nil},
+	}
+	for _, test := range tests {
+		r := autoIncReport{Table: table, Next: test.next, Max: test.max}
+		estimateAutoIncGrowth(&r, test.previous, now)
+		for _, f := range []struct {
+			field     string
+			got, want *float64
+		}{{"per day", r.PerDay, test.perDay}, {"days left", r.DaysLeft, test.daysLeft}} {
+			if (f.got == nil) != (f.want == nil) || f.got != nil && *f.got != *f.want {
+				t.Errorf("%s: %s = %v, want %v", test.name, f.field, deref(f.got), deref(f.want))
+			}
+		}
+	}
+}
+
+func deref(f *float64) interface{} {
+	if f == nil {
+		return nil
+	}
+	return *f
+}
@@ -0,0 +1,283 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+
+	"rdsdba/internal/output"
+	"rdsdba/pkg/engine"
+	"rdsdba/pkg/mysql"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// ChecksumCmd compares table contents of two endpoints chunk by chunk
+	ChecksumCmd = &cobra.Command{
+		Use:   "checksum",
+		Short: "Compare table data of two endpoints by chunked checksums",
+		Long: `Compare the tables of the first --host, the source, with the second --host, the target, e.g. a primary and its replica
+or the two sides of a migration. Tables are cut in chunks of --chunk-size rows by primary key range on the source, and each chunk
+is checksummed on both endpoints at the same time with the row count and the xor of the CRC32 of every row, like pt-table-checksum
+without writing anything. A table without primary key is one chunk, other keys must be integer, char or varchar columns. Chunks changed by writes during the run differ too,
+so differing chunks are checked again --retries times, run it when the replica caught up or during low write traffic.
+--sleep pauses after every chunk to throttle the load. Tables are selected with the --only/--skip patterns of warmup.
+Exit status is 2 when chunks differ or a table couldn't be compared.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			flagged, err := checksumRun()
+			if err != nil {
+				logger.Error().Err(err).Msg("")
+				os.Exit(1)
+			}
+			if flagged {
+				os.Exit(2)
+			}
+		},
+	}
+	checksumChunkSize int
+	checksumRetries   int
+
+	ErrChecksumEndpoints = errors.New("give the source then the target with --host")
+)
+
+// chunkDiff is a chunk whose rows differ between source and target
+type chunkDiff struct {
+	Chunk  mysql.Chunk         `json:"chunk"`
+	Source mysql.ChunkChecksum `json:"source"`
+	Target mysql.ChunkChecksum `json:"target"`
+}
+
+// tableChecksum is the comparison of a table
+type tableChecksum struct {
+	Table      string      `json:"table"`
+	Chunks     int         `json:"chunks"`
+	SourceRows int64       `json:"source_rows"`
+	TargetRows int64       `json:"target_rows"`
+	Diffs      []chunkDiff `json:"diffs"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// checksumReport is the json output
+type checksumReport struct {
+	Source string          `json:"source"`
+	Target string          `json:"target"`
+	Tables []tableChecksum `json:"tables"`
+}
+
+func init() {
+	RootCmd.AddCommand(ChecksumCmd)
+
+	ChecksumCmd.Flags().StringSliceVarP(&only, "only", "o", nil, "only compare tables matching these patterns, comma separated format:schema_name.table_name, globs allowed")
+	ChecksumCmd.Flags().StringSliceVarP(&skip, "skip", "s", nil, "skip tables matching these patterns, comma separated format:schema_name.table_name, globs allowed")
+	ChecksumCmd.Flags().IntVar(&checksumChunkSize, "chunk-size", 10000, "rows per chunk")
+	ChecksumCmd.Flags().IntVar(&checksumRetries, "retries", 2, "how many times a differing chunk is checked again after --sleep before it is reported")
+	ChecksumCmd.Flags().DurationVar(&cfg.Sleep, "sleep", 0, "pause after every chunk to leave I/O to the application, e.g. 100ms")
+	addFormatFlag(ChecksumCmd)
+	ChecksumCmd.MarkFlagsMutuallyExclusive("skip", "only")
+}
+
+// checksumRun reports whether chunks differ or tables failed
+func checksumRun() (bool, error) {
+	if len(hosts) != 2 {
+		return false, ErrChecksumEndpoints
+	}
+	if checksumChunkSize <= 0 {
+		return false, fmt.Errorf("invalid chunk size %d", checksumChunkSize)
+	}
+	if err := output.CheckFormat(outputFormat); err != nil {
+		return false, err
+	}
+	filter, err := engine.NewTableFilter(only, skip)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cfg.HealthCheckInterval = 0
+	cfg.SessionVars = withDefaults(mysql.WarmupSessionVars, cfg.SessionVars)
+	report := checksumReport{Tables: []tableChecksum{}}
+	source, err := checksumEndpoint(hosts[0], &report.Source)
+	if err != nil {
+		return false, err
+	}
+	defer source.Close()
+	target, err := checksumEndpoint(hosts[1], &report.Target)
+	if err != nil {
+		return false, err
+	}
+	defer target.Close()
+
+	var tables []engine.Table
+	if only != nil && !engine.HasWildcard(only) {
+		tables, err = engine.TabStrToTabStruct(only)
+	} else {
+		tables, err = source.GetUserTables(ctx)
+		tables = filter.Filter(tables)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	flagged := false
+	for _, t := range tables {
+		result := checksumTable(ctx, source, target, t)
+		if ctx.Err() != nil {
+			logger.Warn().Msg("checksum interrupted, the report stops at the last complete table")
+			break
+		}
+		if len(result.Diffs) > 0 || len(result.Error) > 0 {
+			flagged = true
+		}
+		logger.Info().Str("table", result.Table).Int("chunks", result.Chunks).Int("diffs", len(result.Diffs)).Str("error", result.Error).Msg("compared")
+		report.Tables = append(report.Tables, result)
+	}
+
+	switch outputFormat {
+	case output.JSON:
+		return flagged, printReport(report, nil, nil)
+	case output.CSV:
+		header := []string{"TABLE", "CHUNK", "LOWER", "UPPER", "SOURCE_ROWS", "TARGET_ROWS", "SOURCE_CRC", "TARGET_CRC", "ERROR"}
+		return flagged, printReport(report, header, chunkDiffRows(report, true))
+	}
+
+	header := []string{"TABLE", "CHUNKS", "SOURCE_ROWS", "TARGET_ROWS", "DIFF_CHUNKS", "ERROR"}
+	rows := make([][]string, 0, len(report.Tables))
+	for _, t := range report.Tables {
+		rows = append(rows, []string{t.Table, strconv.Itoa(t.Chunks), strconv.FormatInt(t.SourceRows, 10), strconv.FormatInt(t.TargetRows, 10),
+			strconv.Itoa(len(t.Diffs)), t.Error})
+	}
+	fmt.Printf("Source %s, target %s:\n", report.Source, report.Target)
+	if err = printReport(report, header, rows); err != nil {
+		return flagged, err
+	}
+	if diffs := chunkDiffRows(report, false); len(diffs) > 0 {
+		fmt.Println("\nDiffering chunks:")
+		header = []string{"TABLE", "CHUNK", "LOWER", "UPPER", "SOURCE_ROWS", "TARGET_ROWS", "SOURCE_CRC", "TARGET_CRC"}
+		return flagged, printReport(report, header, diffs)
+	}
+	return flagged, nil
+}
+
+// checksumEndpoint connects to hostPort and sets name to its host:port
+func checksumEndpoint(hostPort string, name *string) (*mysql.Instance, error) {
+	host, port, err := splitHostPort(hostPort, cfg.DSN.Port)
+	if err != nil {
+		return nil, err
+	}
+	*name = net.JoinHostPort(host, strconv.Itoa(port))
+	epCfg := cfg
+	epCfg.DSN.Host = host
+	epCfg.DSN.Port = port
+	epCfg.MaxOpenConns = 2
+	epCfg.MaxIdleConns = 2
+	return mysql.NewInstance(epCfg)
+}
+
+// checksumTable walks the primary key of the source chunk by chunk and compares each chunk on both endpoints
+func checksumTable(ctx context.Context, source, target *mysql.Instance, t engine.Table) tableChecksum {
+	result := tableChecksum{Table: t.String(), Diffs: []chunkDiff{}}
+	ct, err := source.ChecksumTable(ctx, t)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if len(ct.Key) == 0 {
+		logger.Warn().Str("table", result.Table).Msg("no primary key, the whole table is a single chunk")
+	}
+
+	var lower []interface{}
+	for index := 0; ctx.Err() == nil; index++ {
+		chunk := mysql.Chunk{Index: index, Lower: lower}
+		if len(ct.Key) > 0 {
+			if chunk.Upper, err = source.NextChunkBound(ctx, ct, lower, checksumChunkSize); err != nil {
+				result.Error = err.Error()
+				return result
+			}
+		}
+
+		diff, err := compareChunk(ctx, source, target, ct, chunk)
+		for retry := 0; err == nil && diff.Source != diff.Target && retry < checksumRetries; retry++ {
+			logger.Debug().Str("table", result.Table).Int("chunk", index).Msg("chunk differs, checking again")
+			throttle(ctx)
+			diff, err = compareChunk(ctx, source, target, ct, chunk)
+		}
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		result.Chunks++
+		result.SourceRows += diff.Source.Rows
+		result.TargetRows += diff.Target.Rows
+		if diff.Source != diff.Target {
+			result.Diffs = append(result.Diffs, diff)
+		}
+
+		if chunk.Upper == nil {
+			break
+		}
+		lower = chunk.Upper
+		throttle(ctx)
+	}
+	return result
+}
+
+// compareChunk checksums the chunk on both endpoints at the same time
+func compareChunk(ctx context.Context, source, target *mysql.Instance, ct mysql.ChecksumTable, chunk mysql.Chunk) (chunkDiff, error) {
+	diff := chunkDiff{Chunk: chunk}
+	var sourceErr, targetErr error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		diff.Target, targetErr = target.ChecksumChunk(ctx, ct, chunk)
+	}()
+	diff.Source, sourceErr = source.ChecksumChunk(ctx, ct, chunk)
+	wg.Wait()
+	if sourceErr != nil {
+		return diff, fmt.Errorf("source: %w", sourceErr)
+	}
+	if targetErr != nil {
+		return diff, fmt.Errorf("target: %w", targetErr)
+	}
+	return diff, nil
+}
+
+// chunkDiffRows are TABLE, CHUNK, LOWER, UPPER, SOURCE_ROWS, TARGET_ROWS, SOURCE_CRC and TARGET_CRC, with ERROR and
+// a row per failed table when withErrors is set
+func chunkDiffRows(report checksumReport, withErrors bool) [][]string {
+	bound := func(values []interface{}, open string) string {
+		if values == nil {
+			return open
+		}
+		text := make([]string, len(values))
+		for index, value := range values {
+			text[index] = fmt.Sprint(value)
+		}
+		return "(" + strings.Join(text, ",") + ")"
+	}
+	var rows [][]string
+	for _, t := range report.Tables {
+		for _, d := range t.Diffs {
+			row := []string{t.Table, strconv.Itoa(d.Chunk.Index), bound(d.Chunk.Lower, "min"), bound(d.Chunk.Upper, "max"),
+				strconv.FormatInt(d.Source.Rows, 10), strconv.FormatInt(d.Target.Rows, 10),
+				strconv.FormatUint(d.Source.CRC, 10), strconv.FormatUint(d.Target.CRC, 10)}
+			if withErrors {
+				row = append(row, "")
+			}
+			rows = append(rows, row)
+		}
+		if withErrors && len(t.Error) > 0 {
+			rows = append(rows, []string{t.Table, "", "", "", "", "", "", "", t.Error})
+		}
+	}
+	return rows
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"rdsdba/internal/config"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const envPrefix = "RDSDBA_"
+
+var (
+	configFile   string
+	profile      string
+	defaultsFile string
+	// fromConfig are the flags set by applyConfig rather than on command line
+	fromConfig = make(map[string]bool)
+
+	// mysqlEnv are the environment variables the mysql client reads, RDSDBA_ ones win over them
+	mysqlEnv = map[string]string{
+		"MYSQL_HOST":     "host",
+		"MYSQL_TCP_PORT": "port",
+		"MYSQL_PWD":      "password",
+	}
+	// configFlags are never taken from config sources
+	configFlags = map[string]bool{"config": true, "profile": true, "defaults-file": true, "help": true, "version": true}
+)
+
+// applyConfig fills the flags not given on command line, precedence from high to low:
+// command line flags, environment variables, config file profile, MySQL option file, flag defaults
+func applyConfig(cmd *cobra.Command) error {
+	values := make(map[string]string)
+
+	// MySQL option file, [client] group shared with mysql client then [rdsdba] group
+	optionFile := defaultsFile
+	if len(optionFile) == 0 {
+		optionFile = config.DefaultOptionFile()
+	}
+	if len(optionFile) > 0 {
+		options, err := config.ReadOptionFile(optionFile, "client", "rdsdba")
+		if err != nil && (len(defaultsFile) > 0 || !errors.Is(err, fs.ErrNotExist)) {
+			return err
+		}
+		for key, value := range options {
+			values[key] = value
+		}
+	}
+
+	// config file profile
+	path := configFile
+	if len(path) == 0 {
+		path = config.DefaultPath()
+	}
+	if len(path) > 0 {
+		f, err := config.Load(path)
+		switch {
+		case err == nil:
+			name := profile
+			if !cmd.Flags().Changed("profile") {
+				name = envOr(envPrefix+"PROFILE", f.DefaultProfile)
+			}
+			if len(name) > 0 {
+				profileValues, err := f.Profile(name)
+				if err != nil {
+					return err
+				}
+				for key, value := range profileValues {
+					values[key] = value
+				}
+			}
+		case len(configFile) > 0 || !errors.Is(err, fs.ErrNotExist):
+			return err
+		case cmd.Flags().Changed("profile"):
+			return fmt.Errorf("%w: %s, config file %s does not exist", config.ErrProfileNotFound, profile, path)
+		}
+	}
+
+	// environment variables
+	for env, name := range mysqlEnv {
+		if value, ok := os.LookupEnv(env); ok {
+			values[name] = value
+		}
+	}
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		if value, ok := os.LookupEnv(envName(flag.Name)); ok {
+			values[flag.Name] = value
+		}
+	})
+
+	// command line flags always win
+	for name, value := range values {
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil || flag.Changed || configFlags[name] {
+			continue
+		}
+		// option files turn bool options on with a bare name
+		if len(value) == 0 && flag.Value.Type() == "bool" {
+			value = "true"
+		}
+		if err := cmd.Flags().Set(name, value); err != nil {
+			return fmt.Errorf("invalid value for %s from config: %w", name, err)
+		}
+		fromConfig[name] = true
+	}
+	return nil
+}
+
+// envName maps flag max-connection to RDSDBA_MAX_CONNECTION
+func envName(flag string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+func envOr(env string, fallback string) string {
+	if value, ok := os.LookupEnv(env); ok {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"rdsdba/pkg/engine"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	askPass         bool
+	passwordFile    string
+	passwordCommand string
+
+	// passwordSources from the most to the least preferred when several are given at the same level
+	passwordSources = []string{"ask-pass", "password-command", "password-file", "password"}
+	ErrNoTerminal   = errors.New("needs a terminal on stdin")
+)
+
+// setPasswordProvider picks one password source, a source given on command line wins over one from config
+func setPasswordProvider(cmd *cobra.Command) error {
+	source := ""
+	for _, name := range passwordSources {
+		if cmd.Flags().Changed(name) && !fromConfig[name] {
+			source = name
+			break
+		}
+	}
+	if len(source) == 0 {
+		for _, name := range passwordSources {
+			if cmd.Flags().Changed(name) {
+				source = name
+				break
+			}
+		}
+	}
+
+	switch source {
+	case "ask-pass":
+		if !askPass {
+			return nil
+		}
+		password, err := promptPassword()
+		if err != nil {
+			return err
+		}
+		cfg.Password = engine.StaticPassword(password)
+	case "password-command":
+		cfg.Password = engine.CommandPassword(passwordCommand)
+	case "password-file":
+		cfg.Password = engine.FilePassword(passwordFile)
+	}
+	return nil
+}
+
+func promptPassword() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("--ask-pass %w", ErrNoTerminal)
+	}
+	fmt.Fprint(os.Stderr, "Enter password: ")
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	return string(password), err
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"rdsdba/internal"
+	"rdsdba/pkg/mysql"
+	"rdsdba/pkg/postgres"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/spf13/cobra"
+)
+
+const (
+	engineMySQL    = "mysql"
+	enginePostgres = "postgres"
+	// enginesAnnotation lists the engines a command supports, commands without it are MySQL only
+	enginesAnnotation = "engines"
+)
+
+var (
+	dbEngine string
+
+	ErrUnknownEngine     = errors.New("unknown engine, use mysql or postgres")
+	ErrEngineUnsupported = errors.New("command does not support engine")
+)
+
+// checkEngine validates --engine against the command and sets the engine default port
+func checkEngine(cmd *cobra.Command) error {
+	if dbEngine != engineMySQL && dbEngine != enginePostgres {
+		return fmt.Errorf("%w: %s", ErrUnknownEngine, dbEngine)
+	}
+
+	supported := engineMySQL
+	if engines, ok := cmd.Annotations[enginesAnnotation]; ok {
+		supported = engines
+	}
+	if !strings.Contains(","+supported+",", ","+dbEngine+",") {
+		return fmt.Errorf("%w %s: %s", ErrEngineUnsupported, dbEngine, cmd.CommandPath())
+	}
+
+	if dbEngine != enginePostgres {
+		return nil
+	}
+	if !cmd.Flags().Changed("port") {
+		cfg.DSN.Port = postgres.DefaultPort
+	}
+	for _, name := range []string{"iam-auth", "ssh-host"} {
+		if cmd.Flags().Changed(name) {
+			return fmt.Errorf("--%s is only supported with engine mysql", name)
+		}
+	}
+	if cfg.TLS.CA == mysql.RDSCABundle {
+		return fmt.Errorf("--ssl-ca %s is only supported with engine mysql, give the CA bundle file path", mysql.RDSCABundle)
+	}
+	return nil
+}
+
+// newRDS connects to one endpoint with the selected engine
+func newRDS(c mysql.Config) (internal.RDS, error) {
+	if dbEngine == enginePostgres {
+		return postgres.NewInstance(postgresConfig(c))
+	}
+	return mysql.NewInstance(c)
+}
+
+// warmupSessionVars are the warmup defaults of the selected engine
+func warmupSessionVars() map[string]string {
+	if dbEngine == enginePostgres {
+		return postgres.WarmupSessionVars
+	}
+	return mysql.WarmupSessionVars
+}
+
+// postgresConfig maps the shared connection settings to the postgres ones, ssl modes take the libpq names
+func postgresConfig(c mysql.Config) postgres.Config {
+	sslModes := map[string]string{
+		mysql.SSLDisabled:       "disable",
+		mysql.SSLPreferred:      "prefer",
+		mysql.SSLRequired:       "require",
+		mysql.SSLVerifyCA:       "verify-ca",
+		mysql.SSLVerifyIdentity: "verify-full",
+	}
+
+	p := postgres.Config{
+		MaxOpenConns:    c.MaxOpenConns,
+		MaxIdleConns:    c.MaxIdleConns,
+		ConnMaxLifeTime: c.ConnMaxLifeTime,
+		Logger:          c.Logger,
+		Password:        c.Password,
+		SSLMode:         sslModes[mysql.NormalizeSSLMode(c.TLS.Mode)],
+		SSLCA:           c.TLS.CA,
+		SSLCert:         c.TLS.Cert,
+		SSLKey:          c.TLS.Key,
+		RawDSN:          c.RawDSN,
+		SessionVars:     c.SessionVars,
+		Params:          c.Params,
+	}
+	p.DSN.Host = c.DSN.Host
+	p.DSN.Port = c.DSN.Port
+	p.DSN.User = c.DSN.User
+	p.DSN.Passwd = c.DSN.Passwd
+	p.DSN.Database = c.DSN.Database
+	// libpq takes the socket directory as host
+	if len(c.Socket) > 0 {
+		p.DSN.Host = c.Socket
+	}
+	return p
+}
+
+// usePostgresDSN takes host, port, user and database from a libpq --dsn so logs see the real endpoint
+func usePostgresDSN(dsn string) error {
+	parsed, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return err
+	}
+	cfg.DSN.User = parsed.User
+	cfg.DSN.Host = parsed.Host
+	cfg.DSN.Port = int(parsed.Port)
+	cfg.DSN.Database = parsed.Database
+	hosts = []string{parsed.Host}
+	return nil
+}
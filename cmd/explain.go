@@ -0,0 +1,205 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"rdsdba/internal/output"
+	"rdsdba/pkg/mysql"
+	"rdsdba/pkg/slowlog"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// ExplainCmd reviews the plans of stress queries
+	ExplainCmd = &cobra.Command{
+		Use:   "explain",
+		Short: "Review the query plans of stress queries",
+		Long: `Run EXPLAIN FORMAT=JSON for the --query or each statement of the weighted stress --file, print the chosen indexes
+and flag full table and index scans, filesort, temporary tables, tables without usable index and large row estimates(--max-rows).
+--analyze also runs EXPLAIN ANALYZE on MySQL 8.0.18 or later for SELECT statements without locking or INTO clause, it executes them.
+Exit status is 2 when a plan is flagged.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			flagged, err := explainRun()
+			if err != nil {
+				logger.Error().Err(err).Msg("")
+				os.Exit(1)
+			}
+			if flagged {
+				os.Exit(2)
+			}
+		},
+	}
+	explainAnalyze bool
+	explainMaxRows int64
+
+	ErrAnalyzeUnsupported = errors.New("EXPLAIN ANALYZE needs MySQL 8.0.18 or later")
+)
+
+// explainResult is a statement with its plan review
+type explainResult struct {
+	Statement string      `json:"statement"`
+	Weight    int         `json:"weight"`
+	Plan      *mysql.Plan `json:"plan,omitempty"`
+	Issues    []string    `json:"issues"`
+	Analyze   string      `json:"analyze,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+func init() {
+	RootCmd.AddCommand(ExplainCmd)
+
+	ExplainCmd.Flags().StringVarP(&query, "query", "q", "", "single query to explain")
+	ExplainCmd.Flags().StringVarP(&file, "file", "f", "", "stress query file to explain, a query and its weight separated by ';' per line")
+	ExplainCmd.Flags().BoolVar(&explainAnalyze, "analyze", false, "also run EXPLAIN ANALYZE(8.0.18+) on SELECT statements, this executes them")
+	ExplainCmd.Flags().Int64Var(&explainMaxRows, "max-rows", 100000, "flag tables examined with more rows per scan than this, 0 disables it")
+	ExplainCmd.Flags().BoolVar(&processFull, "full", false, "don't truncate statements in table output")
+	addFormatFlag(ExplainCmd)
+	ExplainCmd.MarkFlagsMutuallyExclusive("query", "file")
+}
+
+// explainRun reports whether a plan was flagged
+func explainRun() (bool, error) {
+	if len(file) == 0 && len(query) == 0 {
+		return false, ErrFlagMissing
+	}
+	if err := output.CheckFormat(outputFormat); err != nil {
+		return false, err
+	}
+
+	statements := map[string]int{query: 1}
+	if len(file) > 0 {
+		var err error
+		if statements, err = processStmsFromFile(file); err != nil {
+			return false, err
+		}
+	}
+
+	cfg.HealthCheckInterval = 0
+	i, err := mysql.NewInstance(cfg)
+	if err != nil {
+		return false, err
+	}
+	defer i.Close()
+
+	ctx := context.Background()
+	if explainAnalyze {
+		version, err := i.Version(ctx)
+		if err != nil {
+			return false, err
+		}
+		if !version.AtLeast(8, 0, 18) {
+			return false, fmt.Errorf("%w, server is %s", ErrAnalyzeUnsupported, version)
+		}
+	}
+
+	results := make([]explainResult, 0, len(statements))
+	for stmt, weight := range statements {
+		results = append(results, explainResult{Statement: strings.TrimSpace(stmt), Weight: weight, Issues: []string{}})
+	}
+	// the heaviest statements of the mix first
+	sort.SliceStable(results, func(a, b int) bool {
+		if results[a].Weight != results[b].Weight {
+			return results[a].Weight > results[b].Weight
+		}
+		return results[a].Statement < results[b].Statement
+	})
+
+	flagged := false
+	for index := range results {
+		r := &results[index]
+		r.Plan, _, err = i.Explain(ctx, r.Statement)
+		if err != nil {
+			r.Error = err.Error()
+			flagged = true
+			continue
+		}
+		r.Issues = append(r.Issues, r.Plan.Issues(explainMaxRows)...)
+		if len(r.Issues) > 0 {
+			flagged = true
+		}
+		if explainAnalyze && isSelect(r.Statement) {
+			if r.Analyze, err = i.ExplainAnalyze(ctx, r.Statement); err != nil {
+				r.Error = err.Error()
+			}
+		}
+	}
+
+	if outputFormat != output.Table {
+		header := []string{"STATEMENT", "WEIGHT", "COST", "TABLES", "KEYS", "ISSUES", "ERROR"}
+		rows := make([][]string, 0, len(results))
+		for _, r := range results {
+			var cost string
+			var tables, keys []string
+			if r.Plan != nil {
+				cost = fmt.Sprintf("%.2f", r.Plan.Cost)
+				for _, t := range r.Plan.Tables {
+					tables = append(tables, t.Table)
+					keys = append(keys, t.Key)
+				}
+			}
+			rows = append(rows, []string{r.Statement, strconv.Itoa(r.Weight), cost, strings.Join(tables, ","), strings.Join(keys, ","),
+				strings.Join(r.Issues, "; "), r.Error})
+		}
+		return flagged, printReport(results, header, rows)
+	}
+	printExplainResults(results)
+	return flagged, nil
+}
+
+func printExplainResults(results []explainResult) {
+	for index, r := range results {
+		if index > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("# weight %d: %s\n", r.Weight, statement(r.Statement))
+		if len(r.Error) > 0 {
+			fmt.Printf("  error: %s\n", r.Error)
+		}
+		if r.Plan == nil {
+			continue
+		}
+		fmt.Printf("  cost %.2f\n", r.Plan.Cost)
+		for _, t := range r.Plan.Tables {
+			key := t.Key
+			if len(key) == 0 {
+				key = "-"
+			} else if len(t.UsedKeyParts) > 0 {
+				key += "(" + strings.Join(t.UsedKeyParts, ",") + ")"
+			}
+			extra := ""
+			if t.UsingIndex {
+				extra = ", covering"
+			}
+			fmt.Printf("  %-30s %-8s key %s rows %d filtered %.0f%%%s\n", t.Table, t.AccessType, key, t.Rows, t.Filtered, extra)
+		}
+		for _, message := range r.Plan.Messages {
+			fmt.Printf("  note: %s\n", message)
+		}
+		for _, issue := range r.Issues {
+			fmt.Printf("  ! %s\n", issue)
+		}
+		if len(r.Analyze) > 0 {
+			fmt.Println("  analyze:")
+			for _, line := range strings.Split(strings.TrimRight(r.Analyze, "\n"), "\n") {
+				fmt.Printf("    %s\n", line)
+			}
+		}
+	}
+}
+
+// lockingSelect matches the clauses which make a SELECT take row locks or write, on the fingerprint so literals don't match
+var lockingSelect = regexp.MustCompile(`\b(for update|for share|lock in share mode|into)\b`)
+
+// isSelect tells the statements EXPLAIN ANALYZE may run without changing data or locking rows
+func isSelect(stmt string) bool {
+	stmt = strings.TrimLeft(strings.TrimSpace(stmt), "( ")
+	return len(stmt) >= 6 && strings.EqualFold(stmt[:6], "select") && !lockingSelect.MatchString(slowlog.Fingerprint(stmt))
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import "testing"
+
+func TestIsSelect(t *testing.T) {
+	tests := []struct {
+		stmt string
+		want bool
+	}{
+		{"select * from orders where id = 1", true},
+		{"  (SELECT id FROM orders) union (select id from archive)", true},
+		{"select * from orders where note = 'for update'", true},
+		{"select * from orders where id = 1 for update", false},
+		{"SELECT * FROM orders WHERE id = 1 FOR UPDATE SKIP LOCKED", false},
+		{"select * from orders for share nowait", false},
+		{"select * from orders lock in share mode", false},
+		{"select count(*) into @n from orders", false},
+		{"select * from orders into outfile '/tmp/orders'", false},
+		{"update orders set paid = 1", false},
+		{"delete from orders", false},
+	}
+	for _, test := range tests {
+		if got := isSelect(test.stmt); got != test.want {
+			t.Errorf("isSelect(%q) = %v, want %v", test.stmt, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"rdsdba/internal/output"
+	"rdsdba/pkg/gtid"
+	"rdsdba/pkg/mysql"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// GTIDCmd groups the GTID commands
+	GTIDCmd = &cobra.Command{
+		Use:   "gtid",
+		Short: "GTID consistency helpers",
+	}
+
+	// GTIDCheckCmd compares gtid_executed of a primary and its replicas
+	GTIDCheckCmd = &cobra.Command{
+		Use:   "check",
+		Short: "Find errant transactions and GTID lag of replicas",
+		Long: `Compare @@gtid_executed of the first --host, the primary, with the other --host endpoints, its replicas.
+Errant transactions are GTIDs a replica executed which the primary doesn't have, usually writes on the replica itself.
+They break a failover to that replica: replicas request them from it once promoted and fail when they were purged.
+Behind is what the primary executed the replica didn't yet. Replicas are read before the primary so transactions
+committed meanwhile don't look errant. Exit status is 2 when a replica has errant transactions or couldn't be checked.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			flagged, err := gtidCheckRun()
+			if err != nil {
+				logger.Error().Err(err).Msg("")
+				os.Exit(1)
+			}
+			if flagged {
+				os.Exit(2)
+			}
+		},
+	}
+
+	ErrNoReplicas = errors.New("give the primary then at least a replica with --host")
+	ErrGTIDOff    = errors.New("gtid_mode isn't ON")
+)
+
+// gtidServer is the GTID state of an endpoint, Behind and Errant are empty on the primary
+type gtidServer struct {
+	Host         string `json:"host"`
+	ServerUUID   string `json:"server_uuid"`
+	Executed     string `json:"executed"`
+	Transactions uint64 `json:"transactions"`
+	Behind       string `json:"behind"`
+	BehindCount  uint64 `json:"behind_count"`
+	Errant       string `json:"errant"`
+	ErrantCount  uint64 `json:"errant_count"`
+	// ErrantLocal tells errant transactions were written on the replica itself
+	ErrantLocal bool   `json:"errant_local"`
+	Error       string `json:"error,omitempty"`
+
+	executed gtid.Set
+}
+
+// gtidReport is the json output
+type gtidReport struct {
+	Primary  gtidServer   `json:"primary"`
+	Replicas []gtidServer `json:"replicas"`
+}
+
+func init() {
+	RootCmd.AddCommand(GTIDCmd)
+	GTIDCmd.AddCommand(GTIDCheckCmd)
+
+	addFormatFlag(GTIDCheckCmd)
+}
+
+// gtidCheckRun reports whether a replica has errant transactions or failed
+func gtidCheckRun() (bool, error) {
+	if len(hosts) < 2 {
+		return false, ErrNoReplicas
+	}
+	if err := output.CheckFormat(outputFormat); err != nil {
+		return false, err
+	}
+
+	ctx := context.Background()
+	replicas := make([]gtidServer, len(hosts)-1)
+	var wg sync.WaitGroup
+	for index, host := range hosts[1:] {
+		wg.Add(1)
+		go func(r *gtidServer, host string) {
+			defer wg.Done()
+			if err := readGTIDState(ctx, r, host); err != nil {
+				r.Error = err.Error()
+			}
+		}(&replicas[index], host)
+	}
+	wg.Wait()
+
+	report := gtidReport{Replicas: replicas}
+	if err := readGTIDState(ctx, &report.Primary, hosts[0]); err != nil {
+		return false, fmt.Errorf("primary %s: %w", hosts[0], err)
+	}
+
+	flagged := false
+	for index := range report.Replicas {
+		r := &report.Replicas[index]
+		if len(r.Error) > 0 {
+			flagged = true
+			continue
+		}
+		errant := r.executed.Subtract(report.Primary.executed)
+		behind := report.Primary.executed.Subtract(r.executed)
+		r.Errant, r.ErrantCount = errant.String(), errant.Count()
+		r.Behind, r.BehindCount = behind.String(), behind.Count()
+		for _, sid := range errant.SIDs() {
+			if sid.UUID == strings.ToLower(r.ServerUUID) {
+				r.ErrantLocal = true
+			}
+		}
+		if r.ErrantCount > 0 {
+			flagged = true
+		}
+	}
+
+	switch outputFormat {
+	case output.JSON:
+		return flagged, printReport(report, nil, nil)
+	case output.CSV:
+		header := []string{"HOST", "ROLE", "SERVER_UUID", "TRANSACTIONS", "BEHIND", "BEHIND_GTIDS", "ERRANT", "ERRANT_GTIDS", "ERROR"}
+		return flagged, printReport(report, header, gtidRows(report))
+	}
+
+	header := []string{"HOST", "ROLE", "SERVER_UUID", "TRANSACTIONS", "BEHIND", "ERRANT", "ERROR"}
+	var rows [][]string
+	for _, row := range gtidRows(report) {
+		rows = append(rows, []string{row[0], row[1], row[2], row[3], row[4], row[6], row[8]})
+	}
+	if err := printReport(report, header, rows); err != nil {
+		return flagged, err
+	}
+	for _, r := range report.Replicas {
+		if r.ErrantCount == 0 {
+			continue
+		}
+		origin := "from other servers"
+		if r.ErrantLocal {
+			origin = "written on the replica itself"
+		}
+		fmt.Printf("\nErrant on %s, %d transactions %s:\n%s\n", r.Host, r.ErrantCount, origin, r.Errant)
+	}
+	return flagged, nil
+}
+
+// gtidRows are HOST, ROLE, SERVER_UUID, TRANSACTIONS, BEHIND, BEHIND_GTIDS, ERRANT, ERRANT_GTIDS and ERROR
+func gtidRows(report gtidReport) [][]string {
+	p := report.Primary
+	rows := [][]string{{p.Host, "primary", p.ServerUUID, strconv.FormatUint(p.Transactions, 10), "", "", "", "", ""}}
+	for _, r := range report.Replicas {
+		if len(r.Error) > 0 {
+			rows = append(rows, []string{r.Host, "replica", r.ServerUUID, "", "", "", "", "", r.Error})
+			continue
+		}
+		rows = append(rows, []string{r.Host, "replica", r.ServerUUID, strconv.FormatUint(r.Transactions, 10),
+			strconv.FormatUint(r.BehindCount, 10), r.Behind, strconv.FormatUint(r.ErrantCount, 10), r.Errant, ""})
+	}
+	return rows
+}
+
+// readGTIDState connects to host and parses its gtid_executed
+func readGTIDState(ctx context.Context, s *gtidServer, hostPort string) error {
+	host, port, err := splitHostPort(hostPort, cfg.DSN.Port)
+	if err != nil {
+		return err
+	}
+	s.Host = net.JoinHostPort(host, strconv.Itoa(port))
+	epCfg := cfg
+	epCfg.DSN.Host = host
+	epCfg.DSN.Port = port
+	epCfg.HealthCheckInterval = 0
+	epCfg.MaxOpenConns = 1
+	epCfg.MaxIdleConns = 1
+	i, err := mysql.NewInstance(epCfg)
+	if err != nil {
+		return err
+	}
+	defer i.Close()
+
+	state, err := i.GTIDState(ctx)
+	if err != nil {
+		return err
+	}
+	s.ServerUUID = state.ServerUUID
+	if state.Mode != "ON" {
+		return fmt.Errorf("%w: %s", ErrGTIDOff, state.Mode)
+	}
+	if s.executed, err = gtid.Parse(state.Executed); err != nil {
+		return err
+	}
+	s.Executed = s.executed.String()
+	s.Transactions = s.executed.Count()
+	return nil
+}
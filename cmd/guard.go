@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"rdsdba/internal/config"
+	"rdsdba/pkg/mysql"
+
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// GuardCmd kills long running queries and idle transactions by rules
+	GuardCmd = &cobra.Command{
+		Use:   "guard",
+		Short: "Kill long running queries and idle transactions by rules",
+		Long: `Watch the processlist and kill threads matching the rules of a YAML file, e.g. SELECTs of a user running longer than 300s
+or sleeping sessions holding a transaction open for more than 10 minutes. Rules not enforcing only log what they would kill.
+Replication users, rdsadmin and the protected users of the rules file are never killed. Every action goes to the audit log.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := guardRun()
+			if err != nil {
+				logger.Error().Err(err).Msg("")
+				os.Exit(1)
+			}
+		},
+	}
+	guardRules    string
+	guardAuditLog string
+	guardDryRun   bool
+	guardInterval time.Duration
+)
+
+type guardRule struct {
+	config.GuardRule
+	filter mysql.ProcessFilter
+}
+
+func init() {
+	RootCmd.AddCommand(GuardCmd)
+
+	GuardCmd.Flags().StringVarP(&guardRules, "rules", "r", "", "YAML rules file")
+	GuardCmd.Flags().StringVar(&guardAuditLog, "audit-log", "", "append audit records as JSON lines to this file instead of stdout")
+	GuardCmd.Flags().BoolVar(&guardDryRun, "dry-run", false, "only log, even for enforcing rules")
+	GuardCmd.Flags().DurationVarP(&guardInterval, "interval", "I", 0, "check interval, overrides interval of the rules file(default 5s)")
+	GuardCmd.MarkFlagRequired("rules")
+}
+
+func guardRun() error {
+	g, err := config.LoadGuard(guardRules)
+	if err != nil {
+		return err
+	}
+	if guardInterval > 0 {
+		g.Interval = guardInterval
+	}
+	rules, err := compileGuardRules(g.Rules)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if len(guardAuditLog) > 0 {
+		f, err := os.OpenFile(guardAuditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o640)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	audit := zerolog.New(w).With().Timestamp().Str("run_id", runID).Str("host", cfg.DSN.Host).Logger()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	i, err := mysql.NewInstance(cfg)
+	if err != nil {
+		return err
+	}
+	defer i.Close()
+
+	protected := make(map[string]bool)
+	for user := range protectedUsers {
+		protected[user] = true
+	}
+	for _, user := range g.ProtectedUsers {
+		protected[strings.ToLower(user)] = true
+	}
+	// without them replication threads could be killed, refuse to run rather than guess
+	replUsers, err := i.ReplicationUsers(ctx)
+	if err != nil {
+		return err
+	}
+	for _, user := range replUsers {
+		protected[strings.ToLower(user)] = true
+	}
+	rdsKill, err := i.HasRDSKill(ctx)
+	if err != nil {
+		return err
+	}
+	logger.Info().Int("rules", len(rules)).Dur("interval", g.Interval).Strs("replication_users", replUsers).Bool("rds_kill", rdsKill).Bool("dry_run", guardDryRun).Msg("guard started")
+	audit.Info().Str("event", "start").Str("rules_file", guardRules).Bool("dry_run", guardDryRun).Msg("")
+
+	// reported remembers threads already logged by a log only rule so they are logged once, not every interval
+	reported := make(map[string]bool)
+	ticker := time.NewTicker(g.Interval)
+	defer ticker.Stop()
+	for {
+		processes, err := i.Processlist(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.Warn().Err(err).Msg("processlist failed")
+		}
+
+		seen := make(map[string]bool, len(processes))
+		for _, p := range processes {
+			if protected[strings.ToLower(p.User)] || strings.HasPrefix(p.Command, "Binlog Dump") {
+				continue
+			}
+			rule := matchGuardRule(rules, p)
+			if rule == nil {
+				continue
+			}
+
+			enforce := rule.Enforce && !guardDryRun
+			key := fmt.Sprintf("%d/%s", p.ID, rule.Name)
+			seen[key] = true
+			if !enforce && reported[key] {
+				continue
+			}
+			reported[key] = true
+
+			record := audit.Info().Str("event", "match").Str("rule", rule.Name).Str("action", rule.Action).Bool("enforced", enforce).
+				Uint64("id", p.ID).Str("user", p.User).Str("client", p.Host).Str("db", p.DB).Str("command", p.Command).
+				Int64("time", p.Time).Str("state", p.State).Str("info", p.Info)
+			if p.TrxTime != nil {
+				record = record.Int64("trx_time", *p.TrxTime)
+			}
+			if !enforce {
+				record.Str("result", "logged").Msg("")
+				continue
+			}
+			if err = i.Kill(ctx, p.ID, rule.Action == config.ActionKillQuery, rdsKill); err != nil {
+				record.Str("result", "failed").Err(err).Msg("")
+				continue
+			}
+			record.Str("result", "killed").Msg("")
+		}
+		for key := range reported {
+			if !seen[key] {
+				delete(reported, key)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			audit.Info().Str("event", "stop").Msg("")
+			logger.Info().Msg("guard stopped")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func compileGuardRules(rules []config.GuardRule) ([]guardRule, error) {
+	compiled := make([]guardRule, 0, len(rules))
+	for _, rule := range rules {
+		m := rule.Match
+		filter := mysql.ProcessFilter{
+			User:       m.User,
+			Host:       m.Host,
+			DB:         m.DB,
+			Command:    m.Command,
+			State:      m.State,
+			MinTime:    m.MinTime,
+			MinTrxTime: m.MinTrxTime,
+		}
+		if len(m.Info) > 0 {
+			re, err := regexp.Compile("(?i)" + m.Info)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: %w", rule.Name, err)
+			}
+			filter.Info = re
+		}
+		compiled = append(compiled, guardRule{GuardRule: rule, filter: filter})
+	}
+	return compiled, nil
+}
+
+// matchGuardRule returns the first rule matching the thread
+func matchGuardRule(rules []guardRule, p mysql.Process) *guardRule {
+	for index := range rules {
+		if rules[index].filter.Match(p) {
+			return &rules[index]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"rdsdba/internal/output"
+	"rdsdba/pkg/engine"
+	"rdsdba/pkg/mysql"
+
+	"github.com/spf13/cobra"
+)
+
+// shortUptime is the uptime under which unused index stats are likely to miss periodic jobs
+const shortUptime = 7 * 24 * time.Hour
+
+var (
+	// IndexesCmd groups the index commands
+	IndexesCmd = &cobra.Command{
+		Use:   "indexes",
+		Short: "Index maintenance helpers",
+	}
+
+	// IndexesAuditCmd finds unused and redundant indexes
+	IndexesAuditCmd = &cobra.Command{
+		Use:   "audit",
+		Short: "Find unused and redundant indexes and suggest the DROP INDEX",
+		Long: `List the secondary indexes without any use since startup according to performance_schema.table_io_waits_summary_by_index_usage,
+and the redundant ones: duplicates, left prefixes of another index, and indexes ending with the primary key columns InnoDB appends anyway.
+Sizes come from mysql.innodb_index_stats. Usage is per instance, an index unused on the writer may serve queries on readers,
+check them too and mind monthly jobs when the uptime is short. Tables are selected with the --only/--skip patterns of warmup.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := indexesAuditRun()
+			if err != nil {
+				logger.Error().Err(err).Msg("")
+				os.Exit(1)
+			}
+		},
+	}
+)
+
+// indexAudit is the json output
+type indexAudit struct {
+	Uptime    int64                  `json:"uptime"`
+	Unused    []mysql.Index          `json:"unused"`
+	Redundant []mysql.RedundantIndex `json:"redundant"`
+}
+
+func init() {
+	RootCmd.AddCommand(IndexesCmd)
+	IndexesCmd.AddCommand(IndexesAuditCmd)
+
+	IndexesAuditCmd.Flags().StringSliceVarP(&only, "only", "o", nil, "only audit tables matching these patterns, comma separated format:schema_name.table_name, globs allowed")
+	IndexesAuditCmd.Flags().StringSliceVarP(&skip, "skip", "s", nil, "skip tables matching these patterns, comma separated format:schema_name.table_name, globs allowed")
+	addFormatFlag(IndexesAuditCmd)
+}
+
+func indexesAuditRun() error {
+	if err := output.CheckFormat(outputFormat); err != nil {
+		return err
+	}
+	filter, err := engine.NewTableFilter(only, skip)
+	if err != nil {
+		return err
+	}
+
+	cfg.HealthCheckInterval = 0
+	i, err := mysql.NewInstance(cfg)
+	if err != nil {
+		return err
+	}
+	defer i.Close()
+
+	ctx := context.Background()
+	all, err := i.Indexes(ctx)
+	if err != nil {
+		// the index list is still good for redundancy without sizes or usage
+		if all == nil {
+			return err
+		}
+		logger.Warn().Err(err).Msg("")
+	}
+	var indexes []mysql.Index
+	for _, x := range all {
+		if filter.Match(x.Table) {
+			indexes = append(indexes, x)
+		}
+	}
+
+	audit := indexAudit{Unused: mysql.UnusedIndexes(indexes), Redundant: mysql.RedundantIndexes(indexes)}
+	if audit.Uptime, err = i.Uptime(ctx); err != nil {
+		return err
+	}
+	if uptime := time.Duration(audit.Uptime) * time.Second; uptime < shortUptime {
+		logger.Warn().Dur("uptime", uptime).Msg("short uptime, indexes used by weekly or monthly jobs may show as unused")
+	}
+	if audit.Unused == nil {
+		audit.Unused = []mysql.Index{}
+	}
+	if audit.Redundant == nil {
+		audit.Redundant = []mysql.RedundantIndex{}
+	}
+
+	switch outputFormat {
+	case output.JSON:
+		return printReport(audit, nil, nil)
+	case output.CSV:
+		header := []string{"KIND", "TABLE", "INDEX", "COLUMNS", "SIZE", "REASON", "SUGGESTION"}
+		return printReport(audit, header, indexAuditRows(audit, true))
+	}
+
+	fmt.Printf("Unused indexes since startup %s ago:\n", time.Duration(audit.Uptime)*time.Second)
+	if err = printIndexRows(audit, false); err != nil {
+		return err
+	}
+	fmt.Println()
+	fmt.Println("Redundant indexes:")
+	if err = printIndexRows(audit, true); err != nil {
+		return err
+	}
+
+	var suggestions []string
+	for _, x := range audit.Unused {
+		suggestions = append(suggestions, x.DropStatement())
+	}
+	for _, r := range audit.Redundant {
+		suggestions = append(suggestions, r.Suggestion)
+	}
+	if len(suggestions) > 0 {
+		fmt.Println()
+		fmt.Println("-- Suggested, check readers and the application before running:")
+		fmt.Println(strings.Join(suggestions, "\n"))
+	}
+	return nil
+}
+
+// printIndexRows prints the unused or the redundant indexes as table
+func printIndexRows(audit indexAudit, redundant bool) error {
+	header := []string{"TABLE", "INDEX", "COLUMNS", "SIZE", "REASON"}
+	rows := indexAuditRows(audit, false)
+	var selected [][]string
+	for _, row := range rows {
+		if (row[0] == "redundant") == redundant {
+			selected = append(selected, row[1:6])
+		}
+	}
+	return output.Print(os.Stdout, output.Table, nil, header, selected)
+}
+
+// indexAuditRows are KIND, TABLE, INDEX, COLUMNS, SIZE, REASON and SUGGESTION with suggestion
+func indexAuditRows(audit indexAudit, suggestion bool) [][]string {
+	var rows [][]string
+	for _, x := range audit.Unused {
+		row := []string{"unused", x.Table.String(), x.Name, x.Definition(), sizeCell(x.Size), "no use since startup"}
+		if suggestion {
+			row = append(row, x.DropStatement())
+		}
+		rows = append(rows, row)
+	}
+	for _, r := range audit.Redundant {
+		row := []string{"redundant", r.Index.Table.String(), r.Index.Name, r.Index.Definition(), sizeCell(r.Index.Size), r.Reason}
+		if suggestion {
+			row = append(row, r.Suggestion)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
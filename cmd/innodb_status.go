@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"rdsdba/internal/output"
+	"rdsdba/pkg/innodb"
+	"rdsdba/pkg/mysql"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// InnoDBStatusCmd parses SHOW ENGINE INNODB STATUS
+	InnoDBStatusCmd = &cobra.Command{
+		Use:   "innodb-status",
+		Short: "Parse SHOW ENGINE INNODB STATUS into a structured snapshot",
+		Long: `Parse SHOW ENGINE INNODB STATUS into semaphores, transactions, file I/O, insert buffer, log, buffer pool and row operations.
+Checkpoint age is shown against the redo log capacity(innodb_redo_log_capacity, or innodb_log_file_size * innodb_log_files_in_group before 8.0.30),
+InnoDB flushes aggressively from about 75% and stalls writes near 90%. A growing history list length means purge lags behind, usually
+because of a long running transaction. --file parses saved output instead of connecting, it needs the PROCESS privilege otherwise.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := innodbStatusRun()
+			if err != nil {
+				logger.Error().Err(err).Msg("")
+				os.Exit(1)
+			}
+		},
+	}
+	innodbStatusJSON bool
+	innodbStatusFile string
+)
+
+func init() {
+	RootCmd.AddCommand(InnoDBStatusCmd)
+
+	InnoDBStatusCmd.Flags().BoolVar(&innodbStatusJSON, "json", false, "print the whole snapshot as JSON")
+	InnoDBStatusCmd.Flags().StringVarP(&innodbStatusFile, "file", "f", "", "parse SHOW ENGINE INNODB STATUS output saved to this file, log capacity is then unknown")
+}
+
+func innodbStatusRun() error {
+	var text string
+	var capacity uint64
+	if len(innodbStatusFile) > 0 {
+		content, err := os.ReadFile(innodbStatusFile)
+		if err != nil {
+			return err
+		}
+		text = string(content)
+	} else {
+		cfg.HealthCheckInterval = 0
+		i, err := mysql.NewInstance(cfg)
+		if err != nil {
+			return err
+		}
+		defer i.Close()
+
+		ctx := context.Background()
+		if text, err = i.InnoDBStatus(ctx); err != nil {
+			return err
+		}
+		if capacity, err = i.RedoLogCapacity(ctx); err != nil {
+			logger.Warn().Err(err).Msg("redo log capacity unknown")
+		}
+	}
+
+	status, err := innodb.ParseStatus(text)
+	if err != nil {
+		return err
+	}
+	status.Log.SetCapacity(capacity)
+
+	if innodbStatusJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(status)
+	}
+	printInnoDBStatus(status)
+	return nil
+}
+
+func printInnoDBStatus(s *innodb.Status) {
+	fmt.Printf("InnoDB status at %s, rates averaged over %ds\n\n", s.Time, s.AveragesSeconds)
+
+	l := s.Log
+	if l.Capacity > 0 {
+		fmt.Printf("Checkpoint age:       %s of %s redo log capacity (%.1f%%)\n", output.Bytes(int64(l.CheckpointAge)), output.Bytes(int64(l.Capacity)), l.CheckpointAgePct)
+	} else {
+		fmt.Printf("Checkpoint age:       %s, redo log capacity unknown\n", output.Bytes(int64(l.CheckpointAge)))
+	}
+	t := s.Transactions
+	fmt.Printf("History list length:  %d, purge %s\n", t.HistoryListLen, t.PurgeState)
+	fmt.Printf("Transactions:         %d active, longest %ds, %d in lock wait, %d read views\n", t.Active, t.LongestActive, t.LockWaits, t.ReadViews)
+	fmt.Printf("Queries:              %d inside InnoDB, %d in queue, main thread %s\n", t.QueriesInside, t.QueriesInQueue, t.MainThreadState)
+
+	sem := s.Semaphores
+	fmt.Printf("Semaphore waits:      %d, longest %.0fs, OS waits %d RW-shared, %d RW-excl, %d RW-sx\n", sem.Waits, sem.LongestWait,
+		sem.RWSharedOSWaits, sem.RWExclOSWaits, sem.RWSXOSWaits)
+
+	p := s.BufferPool
+	fmt.Printf("Buffer pool:          %d pages, %d free, %d dirty, hit rate %.0f / 1000\n", p.Size, p.FreeBuffers, p.ModifiedPages, p.HitRate)
+
+	f := s.FileIO
+	fmt.Printf("File I/O:             %.2f reads/s, %.2f writes/s, %.2f fsyncs/s, pending %d reads %d writes %d fsyncs\n", f.ReadsPerSec,
+		f.WritesPerSec, f.FsyncsPerSec, f.PendingReads, f.PendingWrites, f.PendingLogFsync+f.PendingBufferFsync)
+
+	b := s.InsertBuffer
+	fmt.Printf("Insert buffer:        size %d, %d merges, %.2f hash searches/s, %.2f non-hash searches/s\n", b.Size, b.Merges,
+		b.HashSearchesPerSec, b.NonHashSearchesPerSec)
+
+	r := s.RowOperations
+	fmt.Printf("Rows:                 %.2f reads/s, %.2f inserts/s, %.2f updates/s, %.2f deletes/s\n", r.ReadsPerSec, r.InsertsPerSec,
+		r.UpdatesPerSec, r.DeletesPerSec)
+
+	if s.LatestDeadlock != nil {
+		fmt.Printf("Latest deadlock:      %s, see rdsdba locks\n", s.LatestDeadlock.Time)
+	}
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"rdsdba/internal/output"
+	"rdsdba/pkg/innodb"
+	"rdsdba/pkg/mysql"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// LocksCmd shows who blocks whom and the latest deadlock
+	LocksCmd = &cobra.Command{
+		Use:   "locks",
+		Short: "Show the lock blocking tree and the latest deadlock",
+		Long: `Show InnoDB lock waits as a blocking tree: each root blocker with the threads waiting for it, their wait time
+and the statement each side is running, from performance_schema.data_lock_waits on 8.0 or information_schema.innodb_lock_waits on 5.7.
+A root blocker without statement is idle in transaction, committing or killing it releases the waiters.
+The LATEST DETECTED DEADLOCK section of SHOW ENGINE INNODB STATUS follows, it needs the PROCESS privilege.
+--format csv prints the lock waits only, one per line.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := locksRun()
+			if err != nil {
+				logger.Error().Err(err).Msg("")
+				os.Exit(1)
+			}
+		},
+	}
+	locksNoDeadlock bool
+)
+
+// locksReport is the json output
+type locksReport struct {
+	BlockingTree []*mysql.BlockNode `json:"blocking_tree"`
+	Deadlock     *innodb.Deadlock   `json:"deadlock"`
+}
+
+func init() {
+	RootCmd.AddCommand(LocksCmd)
+
+	LocksCmd.Flags().BoolVar(&locksNoDeadlock, "no-deadlock", false, "don't read the latest deadlock from SHOW ENGINE INNODB STATUS")
+	LocksCmd.Flags().BoolVar(&processFull, "full", false, "don't truncate statements in table output")
+	addFormatFlag(LocksCmd)
+}
+
+func locksRun() error {
+	if err := output.CheckFormat(outputFormat); err != nil {
+		return err
+	}
+
+	cfg.HealthCheckInterval = 0
+	i, err := mysql.NewInstance(cfg)
+	if err != nil {
+		return err
+	}
+	defer i.Close()
+
+	ctx := context.Background()
+	waits, err := i.LockWaits(ctx)
+	if err != nil {
+		return err
+	}
+	if outputFormat == output.CSV {
+		return printLockWaits(waits)
+	}
+
+	report := locksReport{BlockingTree: mysql.BlockingTree(waits)}
+	if !locksNoDeadlock {
+		status, err := i.InnoDBStatus(ctx)
+		if err != nil {
+			return err
+		}
+		report.Deadlock, err = innodb.ParseDeadlock(status)
+		if err != nil && !errors.Is(err, innodb.ErrNoDeadlock) {
+			return err
+		}
+	}
+
+	if outputFormat == output.JSON {
+		return printReport(report, nil, nil)
+	}
+	printBlockingTree(report.BlockingTree)
+	if !locksNoDeadlock {
+		fmt.Println()
+		printDeadlock(report.Deadlock)
+	}
+	return nil
+}
+
+func printLockWaits(waits []mysql.LockWait) error {
+	header := []string{"WAITING_THREAD", "WAITING_TRX", "WAIT_TIME", "WAITING_MODE", "WAITING_QUERY",
+		"BLOCKING_THREAD", "BLOCKING_TRX", "BLOCKING_TRX_AGE", "BLOCKING_MODE", "BLOCKING_COMMAND", "BLOCKING_QUERY", "TABLE", "INDEX", "LOCK_TYPE"}
+	rows := make([][]string, 0, len(waits))
+	for _, w := range waits {
+		rows = append(rows, []string{
+			strconv.FormatUint(w.WaitingThread, 10), w.WaitingTrx, strconv.FormatInt(w.WaitTime, 10), w.WaitingMode, w.WaitingQuery,
+			strconv.FormatUint(w.BlockingThread, 10), w.BlockingTrx, strconv.FormatInt(w.BlockingTrxAge, 10), w.BlockingMode,
+			w.BlockingCommand, w.BlockingQuery, w.Table, w.Index, w.LockType,
+		})
+	}
+	return printReport(waits, header, rows)
+}
+
+func printBlockingTree(roots []*mysql.BlockNode) {
+	if len(roots) == 0 {
+		fmt.Println("No lock waits")
+		return
+	}
+	fmt.Printf("Blocking tree, %d root blockers:\n", len(roots))
+	for _, root := range roots {
+		query := statement(root.Query)
+		if root.IdleInTrx() {
+			query = "idle in transaction"
+		}
+		fmt.Printf("\nthread %d %s@%s trx %s open %ds, %s %ds: %s\n", root.Thread, root.User, root.Host, root.Trx, root.TrxAge, root.Command, root.Time, query)
+		printWaiters(root.Waiters, "  ")
+	}
+}
+
+func printWaiters(waiters []*mysql.BlockNode, indent string) {
+	for _, n := range waiters {
+		fmt.Printf("%s└─ thread %d %s@%s trx %s waits %ds for %s %s lock on %s %s: %s\n", indent, n.Thread, n.User, n.Host, n.Trx,
+			n.Wait.WaitTime, n.Wait.WaitingMode, n.Wait.LockType, n.Wait.Table, n.Wait.Index, statement(n.Query))
+		printWaiters(n.Waiters, indent+"   ")
+	}
+}
+
+func printDeadlock(d *innodb.Deadlock) {
+	if d == nil {
+		fmt.Println("No deadlock detected since server start")
+		return
+	}
+	fmt.Printf("Latest deadlock at %s, transaction (%d) rolled back:\n", d.Time, d.Victim)
+	for _, trx := range d.Transactions {
+		fmt.Printf("\n(%d) trx %d thread %d %s, active %ds, %d row locks: %s\n", trx.Number, trx.ID, trx.ThreadID, trx.Client,
+			trx.ActiveSeconds, trx.RowLocks, statement(trx.Query))
+		for _, lock := range trx.Holds {
+			fmt.Printf("  holds        %s\n", lockText(lock))
+		}
+		for _, lock := range trx.WaitingFor {
+			fmt.Printf("  waiting for  %s\n", lockText(lock))
+		}
+	}
+}
+
+func lockText(lock innodb.Lock) string {
+	if lock.Kind == "TABLE" {
+		return fmt.Sprintf("%s TABLE lock on %s", lock.Mode, lock.Table)
+	}
+	return fmt.Sprintf("%s RECORD lock on %s index %s", lock.Mode, lock.Table, lock.Index)
+}
+
+// statement puts a statement on one line, truncated unless --full
+func statement(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	if !processFull && len(query) > infoWidth {
+		query = query[:infoWidth-3] + "..."
+	}
+	return query
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mattn/go-isatty"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	logFormat string
+	logFile   string
+	logLevel  string
+	debug     bool
+	runID     string
+)
+
+// initLogger sets up the global logger from log flags, every line carries the run ID of this execution
+func initLogger(cmd *cobra.Command) error {
+	if debug && !cmd.Flags().Changed("log-level") {
+		logLevel = zerolog.LevelDebugValue
+	}
+	level, err := zerolog.ParseLevel(logLevel)
+	if err != nil {
+		return fmt.Errorf("invalid log level %s", logLevel)
+	}
+	zerolog.SetGlobalLevel(level)
+
+	var w io.Writer = os.Stderr
+	if len(logFile) > 0 {
+		f, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+		if err != nil {
+			return err
+		}
+		w = f
+	}
+
+	switch logFormat {
+	case "json":
+	case "console":
+		f, ok := w.(*os.File)
+		w = zerolog.ConsoleWriter{Out: w, NoColor: !ok || !isatty.IsTerminal(f.Fd())}
+	default:
+		return fmt.Errorf("invalid log format %s, json or console", logFormat)
+	}
+
+	runID = newRunID()
+	log.Logger = zerolog.New(w).With().Timestamp().Str("run_id", runID).Logger()
+	logger = log.Logger
+	cfg.Logger = &logger
+	return nil
+}
+
+func newRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"os"
+	"strconv"
+
+	"rdsdba/internal/output"
+
+	"github.com/spf13/cobra"
+)
+
+var outputFormat string
+
+// addFormatFlag adds --format to commands printing reports
+func addFormatFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&outputFormat, "format", output.Table, "output format: table, json or csv")
+}
+
+// printReport prints to stdout in --format
+func printReport(data interface{}, header []string, rows [][]string) error {
+	return output.Print(os.Stdout, outputFormat, data, header, rows)
+}
+
+// sizeCell shows sizes in binary units in tables and in bytes in csv
+func sizeCell(n int64) string {
+	if outputFormat == output.Table {
+		return output.Bytes(n)
+	}
+	return strconv.FormatInt(n, 10)
+}
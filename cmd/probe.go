@@ -0,0 +1,262 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"rdsdba/pkg/engine"
+	"rdsdba/pkg/mysql"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// ProbeCmd measures downtime with a light heartbeat
+	ProbeCmd = &cobra.Command{
+		Use:   "probe",
+		Short: "Measure failover downtime with a high frequency heartbeat",
+		Long: `Run a light heartbeat(a write to a probe table plus a read) at high frequency against a writer and/or reader endpoint,
+record every failure, outage length, which server answered and read only transitions, then print an outage timeline.
+Stop with Ctrl-C or --time.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := probeRun()
+			if err != nil {
+				logger.Error().Err(err).Msg("")
+				os.Exit(1)
+			}
+		},
+	}
+	probeWriter    string
+	probeReader    string
+	probeInterval  time.Duration
+	probeTimeout   time.Duration
+	probeTime      time.Duration
+	probeTable     string
+	probeReconnect bool
+)
+
+type probeEvent struct {
+	at       time.Time
+	endpoint string
+	msg      string
+}
+
+type probeTarget struct {
+	role     string
+	name     string
+	instance *mysql.Instance
+	write    bool
+	aurora   bool
+	table    engine.Table
+
+	beats     int
+	failures  int
+	outages   []engine.Outage
+	downSince time.Time
+	failedRun int
+	lastID    *mysql.ServerIdentity
+}
+
+type probeTimeline struct {
+	mu     sync.Mutex
+	events []probeEvent
+}
+
+func (t *probeTimeline) add(at time.Time, endpoint string, format string, args ...interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events = append(t.events, probeEvent{at: at, endpoint: endpoint, msg: fmt.Sprintf(format, args...)})
+}
+
+func init() {
+	RootCmd.AddCommand(ProbeCmd)
+
+	ProbeCmd.Flags().StringVar(&probeWriter, "writer", "", "writer endpoint host[:port], default --host when no endpoint given")
+	ProbeCmd.Flags().StringVar(&probeReader, "reader", "", "reader endpoint host[:port]")
+	ProbeCmd.Flags().DurationVarP(&probeInterval, "interval", "I", 100*time.Millisecond, "heartbeat interval")
+	ProbeCmd.Flags().DurationVar(&probeTimeout, "timeout", time.Second, "heartbeat timeout, a slower heartbeat counts as failed")
+	ProbeCmd.Flags().DurationVarP(&probeTime, "time", "T", 0, "how long to probe, 0 means until Ctrl-C")
+	ProbeCmd.Flags().StringVar(&probeTable, "probe-table", mysql.DefaultProbeTable, "heartbeat table written on writer, format schema_name.table_name, created when missing")
+	ProbeCmd.Flags().BoolVar(&probeReconnect, "reconnect", false, "use a new connection for every heartbeat, like applications without connection pool")
+}
+
+func probeRun() error {
+
+	if len(probeWriter) == 0 && len(probeReader) == 0 {
+		probeWriter = net.JoinHostPort(cfg.DSN.Host, strconv.Itoa(cfg.DSN.Port))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if probeTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, probeTime)
+		defer cancel()
+	}
+
+	tables, err := engine.TabStrToTabStruct([]string{probeTable})
+	if err != nil {
+		return fmt.Errorf("probe table %s: %w, want schema_name.table_name", probeTable, err)
+	}
+
+	var targets []*probeTarget
+	for _, ep := range []struct {
+		role string
+		host string
+	}{{"writer", probeWriter}, {"reader", probeReader}} {
+		if len(ep.host) == 0 {
+			continue
+		}
+		t, err := newProbeTarget(ctx, ep.role, ep.host, tables[0])
+		if err != nil {
+			return err
+		}
+		defer t.instance.Close()
+		targets = append(targets, t)
+	}
+
+	timeline := &probeTimeline{}
+	start := time.Now()
+	logger.Info().Msg("Probe started, Ctrl-C to stop")
+
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		wg.Add(1)
+		go func(t *probeTarget) {
+			defer wg.Done()
+			probeLoop(ctx, t, timeline)
+		}(t)
+	}
+	wg.Wait()
+	end := time.Now()
+	logger.Info().Msg("Probe stopped")
+
+	fmt.Println(formatProbeReport(targets, timeline, end.Sub(start)))
+	return nil
+}
+
+func newProbeTarget(ctx context.Context, role string, hostPort string, table engine.Table) (*probeTarget, error) {
+	host, port, err := splitHostPort(hostPort, cfg.DSN.Port)
+	if err != nil {
+		return nil, err
+	}
+	epCfg := cfg
+	epCfg.DSN.Host = host
+	epCfg.DSN.Port = port
+	// probe measures raw failures, no retry or pool reset behind its back
+	epCfg.RetryTimeout = 0
+	epCfg.HealthCheckInterval = 0
+	epCfg.MaxOpenConns = 2
+	epCfg.MaxIdleConns = 2
+
+	i, err := mysql.NewInstance(epCfg)
+	if err != nil {
+		return nil, err
+	}
+	t := &probeTarget{role: role, name: net.JoinHostPort(host, strconv.Itoa(port)), instance: i, write: role == "writer"}
+	// readers only read the probe table when the writer creates it
+	if len(probeWriter) > 0 {
+		t.table = table
+	}
+	t.aurora = i.IsAurora(ctx)
+	if t.write {
+		if err := i.CreateProbeTable(ctx, table); err != nil {
+			i.Close()
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func probeLoop(ctx context.Context, t *probeTarget, timeline *probeTimeline) {
+	ticker := time.NewTicker(probeInterval)
+	defer ticker.Stop()
+	label := t.role + " " + t.name
+
+	for {
+		select {
+		case <-ctx.Done():
+			if !t.downSince.IsZero() {
+				now := time.Now()
+				t.outages = append(t.outages, engine.Outage{Start: t.downSince, End: now})
+				timeline.add(now, label, "still down at end of probe after %s, %d failed heartbeats", now.Sub(t.downSince), t.failedRun)
+			}
+			return
+		case <-ticker.C:
+		}
+
+		if probeReconnect {
+			t.instance.ResetPool()
+		}
+		beatCtx, cancel := context.WithTimeout(ctx, probeTimeout)
+		at := time.Now()
+		id, err := t.instance.Heartbeat(beatCtx, t.table, t.write, t.aurora)
+		cancel()
+		if ctx.Err() != nil {
+			continue
+		}
+		t.beats++
+
+		if err != nil {
+			t.failures++
+			t.failedRun++
+			if t.downSince.IsZero() {
+				t.downSince = at
+				timeline.add(at, label, "outage started: %s", err)
+			}
+			continue
+		}
+
+		if !t.downSince.IsZero() {
+			t.outages = append(t.outages, engine.Outage{Start: t.downSince, End: at})
+			timeline.add(at, label, "outage ended after %s, %d failed heartbeats", at.Sub(t.downSince), t.failedRun)
+			t.downSince = time.Time{}
+			t.failedRun = 0
+		}
+
+		switch {
+		case t.lastID == nil:
+			timeline.add(at, label, "answered by %s, read_only=%t", id, id.ReadOnly)
+		case t.lastID.Hostname != id.Hostname || t.lastID.ServerID != id.ServerID || t.lastID.AuroraServerID != id.AuroraServerID:
+			timeline.add(at, label, "server changed from %s to %s, read_only=%t", t.lastID, id, id.ReadOnly)
+		case t.lastID.ReadOnly != id.ReadOnly:
+			timeline.add(at, label, "read_only changed from %t to %t on %s", t.lastID.ReadOnly, id.ReadOnly, id)
+		}
+		t.lastID = &id
+	}
+}
+
+func formatProbeReport(targets []*probeTarget, timeline *probeTimeline, elapsed time.Duration) string {
+	sort.SliceStable(timeline.events, func(a, b int) bool { return timeline.events[a].at.Before(timeline.events[b].at) })
+
+	result := "\n\tTimeline:\n"
+	for _, e := range timeline.events {
+		result += fmt.Sprintf("\t\t%s  %-30s %s\n", e.at.Format("2006-01-02T15:04:05.000Z07:00"), e.endpoint, e.msg)
+	}
+
+	result += fmt.Sprintf("\n\tSummary(%s):\n", elapsed.Round(time.Millisecond))
+	for _, t := range targets {
+		var total, longest time.Duration
+		for _, o := range t.outages {
+			total += o.Duration()
+			if o.Duration() > longest {
+				longest = o.Duration()
+			}
+		}
+		result += fmt.Sprintf(`		%s %s:
+			heartbeats:         %d
+			failed heartbeats:  %d
+			outages:            %d
+			total downtime:     %s
+			longest outage:     %s
+`, t.role, t.name, t.beats, t.failures, len(t.outages), total, longest)
+	}
+	return result
+}
@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"rdsdba/internal/output"
+	"rdsdba/pkg/mysql"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+const infoWidth = 80
+
+var (
+	// ProcesslistCmd shows and kills client threads
+	ProcesslistCmd = &cobra.Command{
+		Use:   "processlist",
+		Short: "Show client threads, refresh like top and kill them",
+		Long: `Show client threads from performance_schema.threads(information_schema.processlist when performance_schema is off)
+filtered by user, host, db, command, state, minimum time and query pattern, longest running first.
+--watch refreshes the list like top. --kill or --kill-query first prints the matching threads, then asks for confirmation,
+mysql.rds_kill/rds_kill_query are used when available as the RDS master user can't KILL threads of other users.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := processlistRun()
+			if err != nil {
+				logger.Error().Err(err).Msg("")
+				os.Exit(1)
+			}
+		},
+	}
+	processFilter  mysql.ProcessFilter
+	processInfo    string
+	processWatch   time.Duration
+	processKill    bool
+	processKillQry bool
+	processDryRun  bool
+	processFull    bool
+
+	// protectedUsers are never killed
+	protectedUsers = map[string]bool{"system user": true, "event_scheduler": true, "rdsadmin": true}
+
+	ErrNotConfirmed = errors.New("kill not confirmed")
+)
+
+func init() {
+	RootCmd.AddCommand(ProcesslistCmd)
+
+	addProcessFilterFlags(ProcesslistCmd)
+	ProcesslistCmd.Flags().DurationVarP(&processWatch, "watch", "w", 0, "refresh the list at this interval like top, stop with Ctrl-C")
+	ProcesslistCmd.Flags().BoolVar(&processKill, "kill", false, "kill the connections of the matching threads after confirmation")
+	ProcesslistCmd.Flags().BoolVar(&processKillQry, "kill-query", false, "kill the running statement of the matching threads after confirmation, connections stay")
+	ProcesslistCmd.Flags().BoolVar(&processDryRun, "dry-run", false, "only show what --kill or --kill-query would kill")
+	ProcesslistCmd.Flags().BoolVar(&processFull, "full", false, "don't truncate statements in table output")
+	addFormatFlag(ProcesslistCmd)
+	ProcesslistCmd.MarkFlagsMutuallyExclusive("kill", "kill-query")
+	ProcesslistCmd.MarkFlagsMutuallyExclusive("watch", "kill")
+	ProcesslistCmd.MarkFlagsMutuallyExclusive("watch", "kill-query")
+}
+
+// addProcessFilterFlags adds the --match-* thread filters
+func addProcessFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&processFilter.User, "match-user", "", "only threads of this user")
+	cmd.Flags().StringVar(&processFilter.Host, "match-host", "", "only threads from this client host, with or without port")
+	cmd.Flags().StringVar(&processFilter.DB, "match-db", "", "only threads using this database")
+	cmd.Flags().StringVar(&processFilter.Command, "match-command", "", "only threads running this command e.g. Query, Sleep")
+	cmd.Flags().StringVar(&processFilter.State, "match-state", "", "only threads whose state contains this text")
+	cmd.Flags().Int64Var(&processFilter.MinTime, "min-time", 0, "only threads in their current state for at least this many seconds")
+	cmd.Flags().Int64Var(&processFilter.MinTrxTime, "min-trx-time", 0, "only threads with an open transaction started at least this many seconds ago")
+	cmd.Flags().StringVar(&processInfo, "match-info", "", "only threads whose statement matches this regexp(case insensitive)")
+}
+
+func processlistRun() error {
+	if err := output.CheckFormat(outputFormat); err != nil {
+		return err
+	}
+	if len(processInfo) > 0 {
+		re, err := regexp.Compile("(?i)" + processInfo)
+		if err != nil {
+			return err
+		}
+		processFilter.Info = re
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cfg.HealthCheckInterval = 0
+	i, err := mysql.NewInstance(cfg)
+	if err != nil {
+		return err
+	}
+	defer i.Close()
+
+	if processWatch > 0 {
+		return watchProcesslist(ctx, i)
+	}
+
+	processes, err := matchingProcesses(ctx, i)
+	if err != nil {
+		return err
+	}
+	if !processKill && !processKillQry {
+		return printProcesslist(processes)
+	}
+	return killProcesses(ctx, i, processes)
+}
+
+// matchingProcesses returns the filtered threads, longest running first
+func matchingProcesses(ctx context.Context, i *mysql.Instance) ([]mysql.Process, error) {
+	all, err := i.Processlist(ctx)
+	if err != nil {
+		return nil, err
+	}
+	processes := []mysql.Process{}
+	for _, p := range all {
+		if processFilter.Match(p) {
+			processes = append(processes, p)
+		}
+	}
+	sort.SliceStable(processes, func(a, b int) bool { return processes[a].Time > processes[b].Time })
+	return processes, nil
+}
+
+func watchProcesslist(ctx context.Context, i *mysql.Instance) error {
+	ticker := time.NewTicker(processWatch)
+	defer ticker.Stop()
+	for {
+		processes, err := matchingProcesses(ctx, i)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		// clear screen and move to top left
+		fmt.Print("\033[H\033[2J")
+		fmt.Printf("%s  %s:%d  threads: %d  refresh: %s\n\n", time.Now().Format(time.RFC3339), cfg.DSN.Host, cfg.DSN.Port, len(processes), processWatch)
+		if err = printProcesslist(processes); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func printProcesslist(processes []mysql.Process) error {
+	header := []string{"ID", "USER", "HOST", "DB", "COMMAND", "TIME", "TRX_TIME", "STATE", "INFO"}
+	rows := make([][]string, 0, len(processes))
+	for _, p := range processes {
+		trxTime := ""
+		if p.TrxTime != nil {
+			trxTime = strconv.FormatInt(*p.TrxTime, 10)
+		}
+		info := p.Info
+		if outputFormat == output.Table {
+			info = strings.Join(strings.Fields(info), " ")
+			if !processFull && len(info) > infoWidth {
+				info = info[:infoWidth-3] + "..."
+			}
+		}
+		rows = append(rows, []string{
+			strconv.FormatUint(p.ID, 10), p.User, p.Host, p.DB, p.Command, strconv.FormatInt(p.Time, 10), trxTime, p.State, info,
+		})
+	}
+	return printReport(processes, header, rows)
+}
+
+// killProcesses always previews the threads first, then kills them when confirmed on the terminal
+func killProcesses(ctx context.Context, i *mysql.Instance, processes []mysql.Process) error {
+	targets := []mysql.Process{}
+	for _, p := range processes {
+		if protectedUsers[strings.ToLower(p.User)] || strings.HasPrefix(p.Command, "Binlog Dump") {
+			logger.Debug().Uint64("id", p.ID).Str("user", p.User).Str("command", p.Command).Msg("protected thread skipped")
+			continue
+		}
+		targets = append(targets, p)
+	}
+
+	action := "kill connection of"
+	if processKillQry {
+		action = "kill running statement of"
+	}
+	fmt.Fprintf(os.Stderr, "Would %s %d threads:\n", action, len(targets))
+	if err := printProcesslist(targets); err != nil {
+		return err
+	}
+	if processDryRun || len(targets) == 0 {
+		return nil
+	}
+
+	confirmed, err := confirm(fmt.Sprintf("Confirm to %s %d threads on %s:%d? [y/N] ", action, len(targets), cfg.DSN.Host, cfg.DSN.Port))
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		return ErrNotConfirmed
+	}
+
+	rdsKill, err := i.HasRDSKill(ctx)
+	if err != nil {
+		return err
+	}
+	// thread ids are reused and statements move on while the preview waits for confirmation
+	now, err := i.Processlist(ctx)
+	if err != nil {
+		return err
+	}
+	current := make(map[uint64]mysql.Process, len(now))
+	for _, p := range now {
+		current[p.ID] = p
+	}
+
+	killed, changed := 0, 0
+	for _, p := range targets {
+		if !stillPreviewed(p, current) {
+			logger.Warn().Uint64("id", p.ID).Str("user", p.User).Str("info", p.Info).Msg("thread gone or running another statement since the preview, skipped")
+			changed++
+			continue
+		}
+		err = i.Kill(ctx, p.ID, processKillQry, rdsKill)
+		if err != nil {
+			logger.Warn().Uint64("id", p.ID).Str("user", p.User).Err(err).Msg("kill failed")
+			continue
+		}
+		logger.Info().Uint64("id", p.ID).Str("user", p.User).Str("host", p.Host).Bool("query_only", processKillQry).Bool("rds_kill", rdsKill).Msg("killed")
+		killed++
+	}
+	logger.Info().Int("killed", killed).Int("skipped", changed).Int("failed", len(targets)-killed-changed).Msg("kill completed")
+	return nil
+}
+
+// stillPreviewed reports whether thread p.ID of the current processlist still has the user and statement of the preview
+func stillPreviewed(p mysql.Process, current map[uint64]mysql.Process) bool {
+	now, ok := current[p.ID]
+	return ok && now.User == p.User && now.Info == p.Info
+}
+
+// confirm asks a yes/no question on the terminal, it fails without one so scripts can't kill by accident
+func confirm(prompt string) (bool, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, fmt.Errorf("kill confirmation %w, --dry-run only previews", ErrNoTerminal)
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"testing"
+
+	"rdsdba/pkg/mysql"
+)
+
+func TestStillPreviewed(t *testing.T) {
+	preview := mysql.Process{ID: 42, User: "report", Host: "10.0.3.17:51234", Time: 300, Info: "select * from orders"}
+	tests := []struct {
+		name    string
+		current []mysql.Process
+		want    bool
+	}{
+		{"same statement running longer", []mysql.Process{{ID: 42, User: "report", Host: "10.0.3.17:51234", Time: 310, Info: "select * from orders"}}, true},
+		{"thread gone", []mysql.Process{{ID: 7, User: "report", Info: "select * from orders"}}, false},
+		{"next statement", []mysql.Process{{ID: 42, User: "report", Info: "select * from customers"}}, false},
+		{"idle now", []mysql.Process{{ID: 42, User: "report", Command: "Sleep"}}, false},
+		{"id reused by another user", []mysql.Process{{ID: 42, User: "app", Info: "select * from orders"}}, false},
+	}
+	for _, test := range tests {
+		current := make(map[uint64]mysql.Process)
+		for _, p := range test.current {
+			current[p.ID] = p
+		}
+		if got := stillPreviewed(preview, current); got != test.want {
+			t.Errorf("%s: stillPreviewed = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
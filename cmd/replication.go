@@ -0,0 +1,403 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"time"
+
+	"rdsdba/internal/output"
+	"rdsdba/pkg/engine"
+	"rdsdba/pkg/mysql"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// ReplicationCmd shows the replica status and tracks the lag
+	ReplicationCmd = &cobra.Command{
+		Use:   "replication",
+		Short: "Show replica status and measure replication lag",
+		Long: `Show SHOW REPLICA STATUS(SHOW SLAVE STATUS before 8.0.22) of the --host replica in a normalized form, a block per channel:
+IO/SQL thread state, Seconds_Behind_Source, source and relay log positions, retrieved and executed GTID sets and the last errors.
+--watch samples it at an interval, a line per channel and sample, and prints min/avg/max lag at Ctrl-C.
+Seconds_Behind_Source only compares the relay log with the SQL thread, it reads 0 while the IO thread is behind and jumps on
+large transactions. --heartbeat-writer writes the time to a heartbeat table on the primary every --heartbeat-interval(like pt-heartbeat)
+and the lag is read on the replica as the age of the replicated row, up to one interval more than the real lag. Without a writer,
+--heartbeat-table reads the row of the source server_id written by another rdsdba replication run.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := replicationRun(cmd.Flags().Changed("heartbeat-table"))
+			if err != nil {
+				logger.Error().Err(err).Msg("")
+				os.Exit(1)
+			}
+		},
+	}
+	replWatch             time.Duration
+	replHeartbeatWriter   string
+	replHeartbeatTable    string
+	replHeartbeatInterval time.Duration
+
+	ErrNotReplica = errors.New("no replication channel, the server isn't a replica")
+)
+
+// heartbeatLag is the heartbeat lag behind the writer with server id ServerID
+type heartbeatLag struct {
+	ServerID uint64   `json:"server_id"`
+	Lag      *float64 `json:"lag"`
+
+	table engine.Table
+}
+
+// replicationReport is the json output
+type replicationReport struct {
+	Channels  []mysql.ReplicaStatus `json:"channels"`
+	Heartbeat *heartbeatLag         `json:"heartbeat,omitempty"`
+}
+
+// replicationSample is a channel at a --watch sample
+type replicationSample struct {
+	Time          time.Time `json:"time"`
+	Channel       string    `json:"channel"`
+	IORunning     string    `json:"io_running"`
+	SQLRunning    string    `json:"sql_running"`
+	SecondsBehind *int64    `json:"seconds_behind"`
+	HeartbeatLag  *float64  `json:"heartbeat_lag"`
+	RelayLogSpace int64     `json:"relay_log_space"`
+	LastError     string    `json:"last_error,omitempty"`
+}
+
+// lagTracker keeps min, max and sum of a lag over the --watch samples
+type lagTracker struct {
+	count    int
+	min, max float64
+	sum      float64
+}
+
+func (t *lagTracker) add(lag float64) {
+	if t.count == 0 || lag < t.min {
+		t.min = lag
+	}
+	if t.count == 0 || lag > t.max {
+		t.max = lag
+	}
+	t.count++
+	t.sum += lag
+}
+
+func (t *lagTracker) String() string {
+	if t.count == 0 {
+		return "no sample"
+	}
+	return fmt.Sprintf("min %.3fs avg %.3fs max %.3fs", t.min, t.sum/float64(t.count), t.max)
+}
+
+func init() {
+	RootCmd.AddCommand(ReplicationCmd)
+
+	ReplicationCmd.Flags().DurationVarP(&replWatch, "watch", "w", 0, "sample the status at this interval and track the lag, stop with Ctrl-C")
+	ReplicationCmd.Flags().StringVar(&replHeartbeatWriter, "heartbeat-writer", "", "primary endpoint host[:port] to write the heartbeat on, enables heartbeat lag")
+	ReplicationCmd.Flags().StringVar(&replHeartbeatTable, "heartbeat-table", mysql.DefaultHeartbeatTable, "heartbeat table, format schema_name.table_name, created on the writer when missing")
+	ReplicationCmd.Flags().DurationVar(&replHeartbeatInterval, "heartbeat-interval", time.Second, "how often the writer updates the heartbeat, the lag resolution")
+	addFormatFlag(ReplicationCmd)
+}
+
+func replicationRun(heartbeatTable bool) error {
+	if err := output.CheckFormat(outputFormat); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cfg.HealthCheckInterval = 0
+	i, err := mysql.NewInstance(cfg)
+	if err != nil {
+		return err
+	}
+	defer i.Close()
+
+	channels, err := i.ReplicaStatus(ctx)
+	if err != nil {
+		return err
+	}
+	if len(channels) == 0 {
+		return ErrNotReplica
+	}
+
+	// the heartbeat row read on the replica is the writer's, or the source's when another run writes it
+	var heartbeat *heartbeatLag
+	if len(replHeartbeatWriter) > 0 || heartbeatTable {
+		tables, err := engine.TabStrToTabStruct([]string{replHeartbeatTable})
+		if err != nil {
+			return fmt.Errorf("heartbeat table %s: %w, want schema_name.table_name", replHeartbeatTable, err)
+		}
+		heartbeat = &heartbeatLag{ServerID: channels[0].SourceServerID, table: tables[0]}
+	}
+	if len(replHeartbeatWriter) > 0 {
+		stopHeartbeat, serverID, err := startHeartbeat(ctx, heartbeat.table)
+		if err != nil {
+			return err
+		}
+		defer stopHeartbeat()
+		heartbeat.ServerID = serverID
+	}
+
+	if replWatch > 0 {
+		return watchReplication(ctx, i, heartbeat)
+	}
+
+	report := replicationReport{Channels: channels, Heartbeat: heartbeat}
+	if heartbeat != nil {
+		if heartbeat.Lag, err = i.HeartbeatLag(ctx, heartbeat.table, heartbeat.ServerID); err != nil {
+			return err
+		}
+	}
+	switch outputFormat {
+	case output.JSON:
+		return printReport(report, nil, nil)
+	case output.CSV:
+		header := []string{"CHANNEL", "SOURCE", "SOURCE_SERVER_ID", "IO_RUNNING", "SQL_RUNNING", "SECONDS_BEHIND", "HEARTBEAT_LAG", "SQL_DELAY",
+			"SOURCE_LOG_FILE", "READ_SOURCE_LOG_POS", "RELAY_SOURCE_LOG_FILE", "EXEC_SOURCE_LOG_POS", "RELAY_LOG_SPACE",
+			"RETRIEVED_GTID_SET", "EXECUTED_GTID_SET", "LAST_IO_ERRNO", "LAST_IO_ERROR", "LAST_SQL_ERRNO", "LAST_SQL_ERROR"}
+		rows := make([][]string, 0, len(channels))
+		for index, r := range channels {
+			rows = append(rows, []string{
+				r.Channel, net.JoinHostPort(r.SourceHost, strconv.Itoa(r.SourcePort)), strconv.FormatUint(r.SourceServerID, 10),
+				r.IORunning, r.SQLRunning, secondsCell(r.SecondsBehind), lagCell(channelHeartbeat(channels, index, heartbeat)),
+				strconv.FormatInt(r.SQLDelay, 10), r.SourceLogFile, strconv.FormatUint(r.ReadSourceLogPos, 10),
+				r.RelaySourceLogFile, strconv.FormatUint(r.ExecSourceLogPos, 10), strconv.FormatInt(r.RelayLogSpace, 10),
+				r.RetrievedGTIDSet, r.ExecutedGTIDSet, strconv.Itoa(r.LastIOErrno), r.LastIOError, strconv.Itoa(r.LastSQLErrno), r.LastSQLError,
+			})
+		}
+		return printReport(channels, header, rows)
+	}
+	printReplicaStatus(report)
+	return nil
+}
+
+// startHeartbeat creates the heartbeat table on the writer, writes it once and keeps writing it in the background,
+// then waits an interval so the first read isn't a row left by an earlier run. The returned func stops the writes.
+func startHeartbeat(ctx context.Context, table engine.Table) (func(), uint64, error) {
+	host, port, err := splitHostPort(replHeartbeatWriter, cfg.DSN.Port)
+	if err != nil {
+		return nil, 0, err
+	}
+	writerCfg := cfg
+	writerCfg.DSN.Host = host
+	writerCfg.DSN.Port = port
+	writerCfg.MaxOpenConns = 1
+	writerCfg.MaxIdleConns = 1
+	writer, err := mysql.NewInstance(writerCfg)
+	if err != nil {
+		return nil, 0, err
+	}
+	serverID, err := writer.ServerID(ctx)
+	if err == nil {
+		err = writer.CreateHeartbeatTable(ctx, table)
+	}
+	if err == nil {
+		err = writer.WriteHeartbeat(ctx, table)
+	}
+	if err != nil {
+		writer.Close()
+		return nil, 0, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	stop := func() {
+		cancel()
+		writer.Close()
+	}
+	go func() {
+		ticker := time.NewTicker(replHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := writer.WriteHeartbeat(ctx, table); err != nil && ctx.Err() == nil {
+				logger.Warn().Err(err).Str("writer", replHeartbeatWriter).Msg("heartbeat write failed")
+			}
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		stop()
+		return nil, 0, ctx.Err()
+	case <-time.After(replHeartbeatInterval):
+	}
+	return stop, serverID, nil
+}
+
+// channelHeartbeat is the heartbeat lag of the channel replicating from the heartbeat writer,
+// the single channel of a chained replica included
+func channelHeartbeat(channels []mysql.ReplicaStatus, index int, heartbeat *heartbeatLag) *float64 {
+	if heartbeat == nil {
+		return nil
+	}
+	if len(channels) == 1 || channels[index].SourceServerID == heartbeat.ServerID {
+		return heartbeat.Lag
+	}
+	return nil
+}
+
+func secondsCell(seconds *int64) string {
+	if seconds == nil {
+		return "NULL"
+	}
+	return strconv.FormatInt(*seconds, 10)
+}
+
+func lagCell(lag *float64) string {
+	if lag == nil {
+		return ""
+	}
+	return fmt.Sprintf("%.3f", *lag)
+}
+
+func printReplicaStatus(report replicationReport) {
+	for index, r := range report.Channels {
+		if index > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("Channel '%s' from %s (server_id %d)\n", r.Channel, net.JoinHostPort(r.SourceHost, strconv.Itoa(r.SourcePort)), r.SourceServerID)
+		fmt.Printf("  IO thread        %-10s %s\n", r.IORunning, r.IOState)
+		fmt.Printf("  SQL thread       %-10s %s\n", r.SQLRunning, r.SQLState)
+		fmt.Printf("  Seconds behind   %s", secondsCell(r.SecondsBehind))
+		if r.SQLDelay > 0 {
+			fmt.Printf(" (delayed by %ds)", r.SQLDelay)
+		}
+		fmt.Println()
+		if lag := channelHeartbeat(report.Channels, index, report.Heartbeat); lag != nil {
+			fmt.Printf("  Heartbeat lag    %.3fs behind server_id %d\n", *lag, report.Heartbeat.ServerID)
+		} else if report.Heartbeat != nil && (len(report.Channels) == 1 || r.SourceServerID == report.Heartbeat.ServerID) {
+			fmt.Printf("  Heartbeat lag    unknown, no heartbeat of server_id %d yet\n", report.Heartbeat.ServerID)
+		}
+		fmt.Printf("  Source log       read %s:%d, executed %s:%d\n", r.SourceLogFile, r.ReadSourceLogPos, r.RelaySourceLogFile, r.ExecSourceLogPos)
+		fmt.Printf("  Relay log        %s:%d, %s\n", r.RelayLogFile, r.RelayLogPos, output.Bytes(r.RelayLogSpace))
+		if len(r.RetrievedGTIDSet) > 0 || len(r.ExecutedGTIDSet) > 0 {
+			fmt.Printf("  Retrieved GTIDs  %s\n", r.RetrievedGTIDSet)
+			fmt.Printf("  Executed GTIDs   %s\n", r.ExecutedGTIDSet)
+			fmt.Printf("  Auto position    %t\n", r.AutoPosition)
+		}
+		if r.LastIOErrno != 0 {
+			fmt.Printf("  Last IO error    %d at %s: %s\n", r.LastIOErrno, r.LastIOErrorTime, r.LastIOError)
+		}
+		if r.LastSQLErrno != 0 {
+			fmt.Printf("  Last SQL error   %d at %s: %s\n", r.LastSQLErrno, r.LastSQLErrorTime, r.LastSQLError)
+		}
+	}
+}
+
+// lagHistory is the lag of each channel over the --watch samples
+type lagHistory struct {
+	channels   []string
+	behind     map[string]*lagTracker
+	heartbeats map[string]*lagTracker
+	stopped    map[string]int
+}
+
+func (h *lagHistory) add(s replicationSample, running bool) {
+	if _, ok := h.behind[s.Channel]; !ok {
+		h.channels = append(h.channels, s.Channel)
+		h.behind[s.Channel] = &lagTracker{}
+		h.heartbeats[s.Channel] = &lagTracker{}
+	}
+	if s.SecondsBehind != nil {
+		h.behind[s.Channel].add(float64(*s.SecondsBehind))
+	}
+	if s.HeartbeatLag != nil {
+		h.heartbeats[s.Channel].add(*s.HeartbeatLag)
+	}
+	if !running {
+		h.stopped[s.Channel]++
+	}
+}
+
+func (h *lagHistory) print() {
+	fmt.Println("\nLag over the run:")
+	for _, channel := range h.channels {
+		fmt.Printf("  channel '%s': seconds behind %s, heartbeat %s, threads stopped in %d samples\n",
+			channel, h.behind[channel], h.heartbeats[channel], h.stopped[channel])
+	}
+}
+
+// watchReplication prints a line per channel and sample, then the lag over the run at Ctrl-C in table format
+func watchReplication(ctx context.Context, i *mysql.Instance, heartbeat *heartbeatLag) error {
+	ticker := time.NewTicker(replWatch)
+	defer ticker.Stop()
+
+	line := "%-19s  %-12s %-10s %-4s %8s %11s %11s  %s\n"
+	cw := csv.NewWriter(os.Stdout)
+	enc := json.NewEncoder(os.Stdout)
+	switch outputFormat {
+	case output.CSV:
+		cw.Write([]string{"TIME", "CHANNEL", "IO", "SQL", "BEHIND_S", "HEARTBEAT_S", "RELAY_LOG_SPACE", "LAST_ERROR"})
+	case output.Table:
+		fmt.Printf(line, "TIME", "CHANNEL", "IO", "SQL", "BEHIND_S", "HEARTBEAT_S", "RELAY_SPACE", "LAST_ERROR")
+	}
+
+	history := &lagHistory{behind: make(map[string]*lagTracker), heartbeats: make(map[string]*lagTracker), stopped: make(map[string]int)}
+	for {
+		channels, err := i.ReplicaStatus(ctx)
+		if err == nil && heartbeat != nil {
+			heartbeat.Lag, err = i.HeartbeatLag(ctx, heartbeat.table, heartbeat.ServerID)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for index, r := range channels {
+			s := replicationSample{Time: now, Channel: r.Channel, IORunning: r.IORunning, SQLRunning: r.SQLRunning, SecondsBehind: r.SecondsBehind,
+				HeartbeatLag: channelHeartbeat(channels, index, heartbeat), RelayLogSpace: r.RelayLogSpace}
+			if r.LastSQLErrno != 0 {
+				s.LastError = fmt.Sprintf("SQL %d: %s", r.LastSQLErrno, r.LastSQLError)
+			} else if r.LastIOErrno != 0 {
+				s.LastError = fmt.Sprintf("IO %d: %s", r.LastIOErrno, r.LastIOError)
+			}
+			history.add(s, r.Running())
+
+			switch outputFormat {
+			case output.JSON:
+				err = enc.Encode(s)
+			case output.CSV:
+				cw.Write([]string{s.Time.Format(time.RFC3339), s.Channel, s.IORunning, s.SQLRunning, secondsCell(s.SecondsBehind),
+					lagCell(s.HeartbeatLag), strconv.FormatInt(s.RelayLogSpace, 10), s.LastError})
+				cw.Flush()
+				err = cw.Error()
+			default:
+				fmt.Printf(line, s.Time.Format("2006-01-02T15:04:05"), s.Channel, s.IORunning, s.SQLRunning,
+					secondsCell(s.SecondsBehind), lagCell(s.HeartbeatLag), output.Bytes(s.RelayLogSpace), s.LastError)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+			continue
+		}
+		break
+	}
+
+	if outputFormat == output.Table {
+		history.print()
+	}
+	return nil
+}
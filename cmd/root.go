@@ -17,9 +17,15 @@ limitations under the License.
 */
 import (
 	"fmt"
+	"net"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"rdsdba/pkg/mysql"
+
+	gomysql "github.com/go-sql-driver/mysql"
 	"github.com/spf13/cobra"
 )
 
@@ -33,7 +39,55 @@ var (
 		Version: version,
 		Long: `RDS DBA CLI plan to provide rich features to support general
 database operations, troubleshooting, performance diagnose, etc`,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyConfig(cmd); err != nil {
+				return err
+			}
+			if err := initLogger(cmd); err != nil {
+				return err
+			}
+			if err := setPasswordProvider(cmd); err != nil {
+				return err
+			}
+			if err := checkEngine(cmd); err != nil {
+				return err
+			}
+			host, port, err := splitHostPort(hosts[0], cfg.DSN.Port)
+			if err != nil {
+				return err
+			}
+			cfg.DSN.Host = host
+			cfg.DSN.Port = port
+
+			if len(cfg.RawDSN) > 0 {
+				useDSN := useRawDSN
+				if dbEngine == enginePostgres {
+					useDSN = usePostgresDSN
+				}
+				if err := useDSN(cfg.RawDSN); err != nil {
+					return err
+				}
+			}
+			if cfg.SessionVars, err = parseNameValues(sessionVars); err != nil {
+				return err
+			}
+			if cfg.Params, err = parseNameValues(dsnParams); err != nil {
+				return err
+			}
+
+			if cfg.IAM.Enabled {
+				iam, err := mysql.LoadIAMCredentials(cmd.Context(), cfg.IAM.Region, cfg.DSN.Host)
+				if err != nil {
+					return err
+				}
+				cfg.IAM = iam
+			}
+			return nil
+		},
 	}
+	hosts       []string
+	sessionVars []string
+	dsnParams   []string
 )
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -48,14 +102,104 @@ func Execute() {
 }
 
 func init() {
-	RootCmd.PersistentFlags().StringVarP(&cfg.DSN.Host, "host", "H", "localhost", "RDS host")
-	RootCmd.PersistentFlags().IntVarP(&cfg.DSN.Port, "port", "P", 3306, "RDS port")
+	RootCmd.PersistentFlags().StringVar(&dbEngine, "engine", engineMySQL, "database engine: mysql or postgres")
+	RootCmd.PersistentFlags().StringSliceVarP(&hosts, "host", "H", []string{"localhost"}, "RDS host, format host[:port], repeat the flag or comma separate to target several endpoints, only stress, gtid check and checksum use more than the first one")
+	RootCmd.PersistentFlags().IntVarP(&cfg.DSN.Port, "port", "P", 3306, "RDS port, 5432 by default with engine postgres")
 	RootCmd.PersistentFlags().StringVarP(&cfg.DSN.User, "user", "u", "root", "RDS user")
-	RootCmd.PersistentFlags().StringVarP(&cfg.DSN.Passwd, "password", "p", "", "RDS password")
+	RootCmd.PersistentFlags().StringVarP(&cfg.DSN.Database, "database", "d", "", "default database of connections, postgres connects to database postgres when not given")
+	RootCmd.PersistentFlags().StringVarP(&cfg.DSN.Passwd, "password", "p", "", "RDS password, visible in process list and shell history, prefer --ask-pass, --password-file, --password-command or env MYSQL_PWD")
+	RootCmd.PersistentFlags().BoolVar(&askPass, "ask-pass", false, "prompt for the password without echo")
+	RootCmd.PersistentFlags().StringVar(&passwordFile, "password-file", "", "read the password from the first line of this file")
+	RootCmd.PersistentFlags().StringVar(&passwordCommand, "password-command", "", "shell command printing the password on stdout(e.g. secrets manager CLI), run again when the password is refused")
 	RootCmd.PersistentFlags().IntVarP(&cfg.MaxOpenConns, "max-connection", "c", 50, "max number of open connections to RDS")
 	RootCmd.PersistentFlags().IntVarP(&cfg.MaxIdleConns, "max-idle-connection", "i", 50, "max number of idle connections to RDS")
 	RootCmd.PersistentFlags().DurationVarP(&cfg.ConnMaxLifeTime, "connection-max-lifetime", "l", -1*time.Minute, "the maximum amount of time a connection may be reused, less than 0 means never timeout, support time duration [s|m|h], suggest keep default") // by default never timeout, for long-running queries
-	RootCmd.PersistentFlags().BoolVarP(&cfg.Debug, "debug", "D", false, "show debug level log")
-	RootCmd.MarkFlagsRequiredTogether("host", "user", "password")
+	RootCmd.PersistentFlags().BoolVarP(&debug, "debug", "D", false, "show debug level log")
+	RootCmd.PersistentFlags().MarkDeprecated("debug", "use --log-level debug")
+	RootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "log level: trace, debug, info, warn or error")
+	RootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "json", "log format: json or console(coloured on terminal)")
+	RootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "append logs to this file instead of stderr")
+	RootCmd.PersistentFlags().StringVar(&cfg.TLS.Mode, "ssl-mode", mysql.SSLPreferred, "TLS mode: disabled, preferred, required, verify-ca or verify-identity")
+	RootCmd.PersistentFlags().StringVar(&cfg.TLS.CA, "ssl-ca", "", "CA certificate file to verify the server, 'rds' for the built-in RDS global CA bundle")
+	RootCmd.PersistentFlags().StringVar(&cfg.TLS.Cert, "ssl-cert", "", "client certificate file")
+	RootCmd.PersistentFlags().StringVar(&cfg.TLS.Key, "ssl-key", "", "client private key file")
+	RootCmd.PersistentFlags().BoolVar(&cfg.IAM.Enabled, "iam-auth", false, "authenticate with an RDS IAM token from the standard AWS credential chain instead of a password, needs TLS")
+	RootCmd.PersistentFlags().StringVar(&cfg.IAM.Region, "iam-region", "", "AWS region of the instance for IAM authentication, default from AWS config or the RDS host name")
+	RootCmd.PersistentFlags().StringVar(&cfg.SSH.Host, "ssh-host", "", "bastion host[:port] to tunnel connections through over SSH")
+	RootCmd.PersistentFlags().StringVar(&cfg.SSH.User, "ssh-user", "", "SSH user on the bastion (default current user)")
+	RootCmd.PersistentFlags().StringVar(&cfg.SSH.KeyFile, "ssh-key", "", "SSH private key file, ssh-agent is used when not given")
+	RootCmd.PersistentFlags().BoolVar(&cfg.SSH.Agent, "ssh-agent", false, "use ssh-agent keys as well as --ssh-key")
+	RootCmd.PersistentFlags().StringVar(&cfg.SSH.KnownHosts, "ssh-known-hosts", "", "known hosts file to verify the bastion (default ~/.ssh/known_hosts)")
+	RootCmd.PersistentFlags().DurationVar(&cfg.RetryTimeout, "retry-timeout", time.Minute, "how long statements are retried with backoff on connection errors(e.g. during failover), 0 disables retry")
+	RootCmd.PersistentFlags().DurationVar(&cfg.HealthCheckInterval, "health-check-interval", time.Second, "how often read only state is checked to detect failover and reset the connection pool, 0 disables it")
+	RootCmd.PersistentFlags().StringVarP(&cfg.Socket, "socket", "S", "", "unix socket file to connect through instead of tcp")
+	RootCmd.PersistentFlags().StringVar(&cfg.RawDSN, "dsn", "", "full DSN replacing host, port, user and socket: go-sql-driver user[:password]@net(address)/[db][?params], or a libpq URL or keyword/value string with engine postgres")
+	RootCmd.PersistentFlags().StringArrayVar(&sessionVars, "session-var", nil, "session variable name=value set on every connection, repeatable, overrides the command defaults, quote MySQL string values e.g. sql_mode='ANSI'")
+	RootCmd.PersistentFlags().StringArrayVar(&dsnParams, "dsn-param", nil, "extra DSN parameter k=v, go-sql-driver parameters or libpq keywords with engine postgres, repeatable e.g. readTimeout=30s")
+	RootCmd.PersistentFlags().StringVar(&configFile, "config", "", "config file with named connection profiles (default ~/.rdsdba.yaml)")
+	RootCmd.PersistentFlags().StringVar(&profile, "profile", "", "connection profile in config file to use, env RDSDBA_PROFILE or default-profile in config file when not given")
+	RootCmd.PersistentFlags().StringVar(&defaultsFile, "defaults-file", "", "MySQL option file to read [client] and [rdsdba] groups from (default ~/.my.cnf)")
+
+}
+
+// splitHostPort accepts host, host:port and [ipv6]:port, falls back to defaultPort when no port given
+func splitHostPort(hostPort string, defaultPort int) (string, int, error) {
+	hostPort = strings.TrimSpace(hostPort)
+	host, portStr, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		// no port in address
+		return strings.Trim(hostPort, "[]"), defaultPort, nil
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port in host %s", hostPort)
+	}
+	return host, port, nil
+}
 
+// useRawDSN takes host, port and user from --dsn so logs, TLS and IAM see the real endpoint
+func useRawDSN(dsn string) error {
+	parsed, err := gomysql.ParseDSN(dsn)
+	if err != nil {
+		return err
+	}
+	cfg.DSN.User = parsed.User
+	if parsed.Net == "unix" {
+		cfg.Socket = parsed.Addr
+		return nil
+	}
+	host, port, err := splitHostPort(parsed.Addr, cfg.DSN.Port)
+	if err != nil {
+		return err
+	}
+	cfg.DSN.Host = host
+	cfg.DSN.Port = port
+	// the raw DSN has a single endpoint
+	hosts = []string{parsed.Addr}
+	return nil
+}
+
+// parseNameValues parses repeated name=value flags
+func parseNameValues(values []string) (map[string]string, error) {
+	m := make(map[string]string, len(values))
+	for _, v := range values {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok || len(strings.TrimSpace(name)) == 0 {
+			return nil, fmt.Errorf("invalid %s, format name=value", v)
+		}
+		m[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return m, nil
+}
+
+// withDefaults returns the command defaults overridden by the given values
+func withDefaults(defaults map[string]string, values map[string]string) map[string]string {
+	m := make(map[string]string, len(defaults)+len(values))
+	for name, value := range defaults {
+		m[name] = value
+	}
+	for name, value := range values {
+		m[name] = value
+	}
+	return m
 }
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"rdsdba/internal/output"
+	"rdsdba/pkg/slowlog"
+
+	"github.com/spf13/cobra"
+)
+
+// slowlogProgress is how many entries are read between progress logs
+const slowlogProgress = 1000000
+
+var (
+	// SlowlogCmd groups the slow query log commands
+	SlowlogCmd = &cobra.Command{
+		Use:   "slowlog",
+		Short: "Analyze MySQL slow query logs",
+	}
+
+	// SlowlogAnalyzeCmd ranks the queries of a slow log by fingerprint
+	SlowlogAnalyzeCmd = &cobra.Command{
+		Use:   "analyze <file>",
+		Short: "Rank the queries of a slow query log by fingerprint",
+		Long: `Parse a slow query log and group its queries by fingerprint: literals replaced by ?, IN lists and multi row VALUES collapsed.
+Each class reports count, total/avg/p95/max query time, lock time, rows examined and sent, and its slowest query as sample.
+The file is either the slow log file, RDS downloaded log files included, or mysql.slow_log exported with
+mysql --batch -e 'select * from mysql.slow_log' (tab separated with column names). Files are streamed so size doesn't matter,
+.gz files are decompressed and - reads stdin. No database connection is made.
+--format table prints a text report per class with the full fingerprint and sample, csv a line per class.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			err := slowlogAnalyzeRun(args[0])
+			if err != nil {
+				logger.Error().Err(err).Msg("")
+				os.Exit(1)
+			}
+		},
+	}
+	slowlogSort  string
+	slowlogLimit int
+)
+
+// classSorters order query classes by the --sort key, the most expensive first
+var classSorters = map[string]func(a, b *slowlog.Class) bool{
+	"total":    func(a, b *slowlog.Class) bool { return a.QueryTime.Total > b.QueryTime.Total },
+	"avg":      func(a, b *slowlog.Class) bool { return a.QueryTime.Avg > b.QueryTime.Avg },
+	"p95":      func(a, b *slowlog.Class) bool { return a.QueryTime.P95 > b.QueryTime.P95 },
+	"max":      func(a, b *slowlog.Class) bool { return a.QueryTime.Max > b.QueryTime.Max },
+	"count":    func(a, b *slowlog.Class) bool { return a.Count > b.Count },
+	"lock":     func(a, b *slowlog.Class) bool { return a.LockTime.Total > b.LockTime.Total },
+	"examined": func(a, b *slowlog.Class) bool { return a.RowsExamined.Total > b.RowsExamined.Total },
+}
+
+// slowlogReport is the json output
+type slowlogReport struct {
+	Entries   uint64           `json:"entries"`
+	QueryTime float64          `json:"query_time"`
+	Classes   int              `json:"classes"`
+	Ranked    []*slowlog.Class `json:"ranked"`
+}
+
+func init() {
+	RootCmd.AddCommand(SlowlogCmd)
+	SlowlogCmd.AddCommand(SlowlogAnalyzeCmd)
+
+	SlowlogAnalyzeCmd.Flags().StringVar(&slowlogSort, "sort", "total", "rank by query time total, avg, p95 or max, or by count, lock(total lock time) or examined(total rows examined)")
+	SlowlogAnalyzeCmd.Flags().IntVar(&slowlogLimit, "limit", 20, "only show the first n query classes, 0 shows all")
+	addFormatFlag(SlowlogAnalyzeCmd)
+}
+
+func slowlogAnalyzeRun(file string) error {
+	less, ok := classSorters[slowlogSort]
+	if !ok {
+		return fmt.Errorf("unknown sort key %s", slowlogSort)
+	}
+	if err := output.CheckFormat(outputFormat); err != nil {
+		return err
+	}
+
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	if strings.HasSuffix(file, ".gz") {
+		gz, err := gzip.NewReader(bufio.NewReader(r))
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	aggregator := slowlog.NewAggregator()
+	parser := slowlog.NewParser(r)
+	for {
+		e, err := parser.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		aggregator.Add(e)
+		if aggregator.Entries%slowlogProgress == 0 {
+			logger.Info().Uint64("entries", aggregator.Entries).Msg("reading slow log")
+		}
+	}
+
+	classes := aggregator.Classes()
+	sort.SliceStable(classes, func(a, b int) bool { return less(classes[a], classes[b]) })
+	report := slowlogReport{Entries: aggregator.Entries, QueryTime: aggregator.QueryTime, Classes: len(classes), Ranked: classes}
+	if slowlogLimit > 0 && len(classes) > slowlogLimit {
+		report.Ranked = classes[:slowlogLimit]
+	}
+
+	switch outputFormat {
+	case output.JSON:
+		return printReport(report, nil, nil)
+	case output.CSV:
+		header := []string{"RANK", "ID", "COUNT", "SHARE", "TOTAL_S", "AVG_S", "P95_S", "MAX_S", "LOCK_S", "ROWS_EXAMINED_AVG", "ROWS_SENT_AVG", "SCHEMAS", "FINGERPRINT"}
+		rows := make([][]string, 0, len(report.Ranked))
+		for index, c := range report.Ranked {
+			rows = append(rows, []string{
+				strconv.Itoa(index + 1), c.ID, strconv.FormatUint(c.Count, 10), fmt.Sprintf("%.4f", c.Share),
+				fmt.Sprintf("%.6f", c.QueryTime.Total), fmt.Sprintf("%.6f", c.QueryTime.Avg), fmt.Sprintf("%.6f", c.QueryTime.P95),
+				fmt.Sprintf("%.6f", c.QueryTime.Max), fmt.Sprintf("%.6f", c.LockTime.Total),
+				fmt.Sprintf("%.0f", c.RowsExamined.Avg), fmt.Sprintf("%.0f", c.RowsSent.Avg), strings.Join(c.Schemas, ","), c.Fingerprint,
+			})
+		}
+		return printReport(report.Ranked, header, rows)
+	}
+	printSlowlogReport(report)
+	return nil
+}
+
+// printSlowlogReport prints a block per query class, fingerprint and sample query unabridged
+func printSlowlogReport(report slowlogReport) {
+	fmt.Printf("# %d queries, %d classes, %.3fs query time, top %d by %s\n", report.Entries, report.Classes, report.QueryTime, len(report.Ranked), slowlogSort)
+	for index, c := range report.Ranked {
+		fmt.Printf("\n# Rank %d  ID %s  %.1f%% of query time  count %d  schemas %s\n", index+1, c.ID, c.Share*100, c.Count, strings.Join(c.Schemas, ","))
+		fmt.Printf("# Query time     total %.3fs  avg %.3fs  p95 %.3fs  max %.3fs\n", c.QueryTime.Total, c.QueryTime.Avg, c.QueryTime.P95, c.QueryTime.Max)
+		fmt.Printf("# Lock time      total %.3fs  avg %.6fs  max %.6fs\n", c.LockTime.Total, c.LockTime.Avg, c.LockTime.Max)
+		fmt.Printf("# Rows examined  avg %.0f  p95 %.0f  max %.0f\n", c.RowsExamined.Avg, c.RowsExamined.P95, c.RowsExamined.Max)
+		fmt.Printf("# Rows sent      avg %.0f  p95 %.0f  max %.0f\n", c.RowsSent.Avg, c.RowsSent.P95, c.RowsSent.Max)
+		fmt.Printf("# Seen           %s to %s\n", c.FirstSeen, c.LastSeen)
+		fmt.Printf("# Fingerprint\n%s\n", c.Fingerprint)
+		fmt.Printf("# Sample %.3fs %s@%s thread %d at %s\n%s\n", c.Sample.QueryTime, c.Sample.User, c.Sample.Host, c.Sample.ThreadID, c.Sample.Time, c.Sample.Query)
+	}
+}
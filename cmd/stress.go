@@ -21,13 +21,14 @@ import (
 	"errors"
 	"fmt"
 	WeightedRandomChoice "github.com/kontoulis/go-weighted-random-choice"
-	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
+	"net"
 	"rdsdba/internal"
+	"rdsdba/internal/cluster"
 	"rdsdba/internal/utils"
-	"rdsdba/pkg/mysql"
+	"rdsdba/pkg/engine"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gammazero/workerpool"
@@ -44,10 +45,11 @@ var (
 	// StressCmd represents the stress command
 	StressCmd = &cobra.Command{
 		Use:   "stress",
-		Short: "Run stress test on MySQL",
-		Long: `Run stress test on MySQL,
+		Short: "Run stress test on MySQL or PostgreSQL",
+		Long: `Run stress test on MySQL or PostgreSQL,
 specified queries instead of random queries.
 `,
+		Annotations: map[string]string{enginesAnnotation: engineMySQL + "," + enginePostgres},
 
 		Run: func(cmd *cobra.Command, args []string) {
 			err := stressRun()
@@ -63,22 +65,76 @@ specified queries instead of random queries.
 	}
 	query          string
 	file           string
+	hostFile       string
+	route          string
 	duration       time.Duration
-	statement      string
-	errNum         []int
-	rtRes          []int
-	start          time.Time
+	stats          map[string]*endpointStats
 	ErrFlagMissing = errors.New("flag missing")
 )
 
+type endpoint struct {
+	host   string
+	port   int
+	weight int
+	role   string
+}
+
+type endpointStats struct {
+	mu      sync.Mutex
+	role    string
+	errNum  int
+	latency *utils.Histogram
+}
+
+// scenario is the workload of one stress run, the coordinator sends it to agents as json
+type scenario struct {
+	Query      string         `json:"query,omitempty"`
+	Statements map[string]int `json:"statements,omitempty"`
+	Threads    int            `json:"threads"`
+	Duration   time.Duration  `json:"duration"`
+	Route      string         `json:"route"`
+	StartAt    time.Time      `json:"start_at"`
+}
+
+type endpointResult struct {
+	Name    string           `json:"name"`
+	Role    string           `json:"role"`
+	Errors  int              `json:"errors"`
+	Latency *utils.Histogram `json:"latency"`
+	Outages []engine.Outage  `json:"outages,omitempty"`
+}
+
+type stressResult struct {
+	Agent     string           `json:"agent,omitempty"`
+	TotalTime float64          `json:"total_time"`
+	Endpoints []endpointResult `json:"endpoints"`
+}
+
+func (s *endpointStats) add(rt int64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.errNum++
+		return
+	}
+	s.latency.Record(int(rt))
+}
+
 func init() {
 	RootCmd.AddCommand(StressCmd)
 
-	StressCmd.Flags().IntVarP(&cfg.Concurrency, "thread", "t", 1, "number of threads(connections)")
-	StressCmd.Flags().DurationVarP(&duration, "time", "T", 30*time.Second, "stress test time, support time duration [s|m|h]")
-	StressCmd.Flags().StringVarP(&query, "query", "q", "", "single query used for stress test, accepted in command line")
-	StressCmd.Flags().StringVarP(&file, "file", "f", "", "the file which contains multiple queries used for stress test, for each query must provide a weighted, separated by ';'")
-	StressCmd.MarkFlagsMutuallyExclusive("query", "file")
+	addScenarioFlags(StressCmd)
+	StressCmd.Flags().StringVar(&hostFile, "host-file", "", "file listing the endpoints to stress, one per line in format: host[:port] [weight] [writer|reader]")
+}
+
+// addScenarioFlags adds the workload flags shared by stress and stress coordinate
+func addScenarioFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVarP(&cfg.Concurrency, "thread", "t", 1, "number of threads(connections)")
+	cmd.Flags().DurationVarP(&duration, "time", "T", 30*time.Second, "stress test time, support time duration [s|m|h]")
+	cmd.Flags().StringVarP(&query, "query", "q", "", "single query used for stress test, accepted in command line")
+	cmd.Flags().StringVarP(&file, "file", "f", "", "the file which contains multiple queries used for stress test, for each query must provide a weighted, separated by ';'")
+	cmd.Flags().StringVar(&route, "route", "", "how queries are spread across endpoints: round-robin, weighted or read-write(reads to readers, writes to writer), read-write when there are writer and readers, round-robin otherwise")
+	cmd.MarkFlagsMutuallyExclusive("query", "file")
 }
 
 func stressRun() error {
@@ -86,33 +142,66 @@ func stressRun() error {
 		return ErrFlagMissing
 	}
 
+	logger.Debug().Msg("stress test started...")
+
+	sc, err := newScenario()
+	if err != nil {
+		return err
+	}
+
+	res, err := runScenario(context.Background(), sc)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("")
+	}
+	fmt.Println(formatResult(res))
+
+	return nil
+}
+
+func newScenario() (scenario, error) {
+	sc := scenario{
+		Query:    query,
+		Threads:  cfg.Concurrency,
+		Duration: duration,
+		Route:    route,
+	}
+	if len(file) > 0 {
+		stmts, err := processStmsFromFile(file)
+		if err != nil {
+			return sc, err
+		}
+		sc.Statements = stmts
+	}
+	return sc, nil
+}
+
+// runScenario runs the workload against the endpoints from flags, it waits until StartAt when set
+func runScenario(ctx context.Context, sc scenario) (*stressResult, error) {
+	cfg.Concurrency = sc.Threads
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
 	// increase max connections to RDS if thread is higher
 	if cfg.Concurrency > cfg.MaxOpenConns {
 		cfg.MaxOpenConns = cfg.Concurrency
 	}
 
-	logger := initLogger()
-	logger.Debug().Msg("stress test started...")
-
-	ctx := context.Background()
-
-	i, err := mysql.NewInstance(cfg)
+	c, instances, err := newCluster(ctx, sc)
+	for _, i := range instances {
+		defer i.Close()
+	}
 	if err != nil {
-		logger.Fatal().Err(err).Msg("")
+		return nil, err
 	}
-	defer i.DB.Close()
-	logger.Debug().Msg("initialised")
+	logger.Debug().Int("endpoints", len(instances)).Msg("initialised")
 
 	wp := workerpool.New(cfg.Concurrency)
+	var start time.Time
 
 	switch {
-	case len(file) > 0:
+	case len(sc.Statements) > 0:
 		wrc := WeightedRandomChoice.New()
-		stmts, err := processStmsFromFile(file)
-		if err != nil {
-			return err
-		}
-		wrc.AddElements(stmts)
+		wrc.AddElements(sc.Statements)
 
 		sqlChan := make(chan string, sqlChanCap)
 		ctxCancel, cancelWorker := context.WithCancel(ctx)
@@ -121,36 +210,56 @@ func stressRun() error {
 		// prepare sql queue based on weight to be run
 		logger.Info().Msg("prepare")
 		go stmtGen(ctxCancel, wrc, sqlChan)
-		time.Sleep(prepareDuration)
+		startAt := sc.StartAt
+		if startAt.IsZero() {
+			startAt = time.Now().Add(prepareDuration)
+		}
+		time.Sleep(time.Until(startAt))
 
 		logger.Info().Msg("start")
 		start = time.Now()
-		ctxTimeout, timeoutCancel := context.WithTimeout(ctx, duration)
+		ctxTimeout, timeoutCancel := context.WithTimeout(ctx, sc.Duration)
 		defer timeoutCancel()
-		errNum, rtRes = multiple(ctxTimeout, wp, i, sqlChan)
-	case len(query) > 0:
-		statement = query
+		multiple(ctxTimeout, wp, c, sqlChan)
+	case len(sc.Query) > 0:
+		time.Sleep(time.Until(sc.StartAt))
 		logger.Info().Msg("start")
 		start = time.Now()
-		ctxTimeout, timeoutCancel := context.WithTimeout(ctx, duration)
+		ctxTimeout, timeoutCancel := context.WithTimeout(ctx, sc.Duration)
 		defer timeoutCancel()
-		errNum, rtRes = single(ctxTimeout, wp, i, statement)
+		single(ctxTimeout, wp, c, sc.Query)
 	default:
-		return err
+		return nil, ErrFlagMissing
 	}
 
 	wp.StopWait()
 	logger.Info().Msg("end")
 	end := time.Now()
 
-	ignoredErr := len(errNum)
-	totalQueries := len(rtRes)
-	totalTime := end.Sub(start).Seconds()
+	res := &stressResult{TotalTime: end.Sub(start).Seconds()}
+	for index, m := range c.Members {
+		s := stats[m.Name]
+		res.Endpoints = append(res.Endpoints, endpointResult{Name: m.Name, Role: s.role, Errors: s.errNum, Latency: s.latency, Outages: instances[index].Outages()})
+	}
+	return res, nil
+}
+
+func formatResult(res *stressResult) string {
+	latency := utils.NewHistogram()
+	var errNum int
+	for _, ep := range res.Endpoints {
+		latency.Merge(ep.Latency)
+		errNum += ep.Errors
+	}
+
+	ignoredErr := errNum
+	totalQueries := latency.Count()
+	totalTime := res.TotalTime
 	qps := float64(totalQueries) / totalTime
 
-	latencyMin, latencyMax := utils.FindMinAndMax(rtRes)
-	latencyAvg := utils.Avg(rtRes)
-	latency95 := utils.NinetyFifth(rtRes)
+	latencyMin, latencyMax := latency.MinAndMax()
+	latencyAvg := latency.Avg()
+	latency95 := latency.Percentile(0.95)
 
 	result := fmt.Sprintf(`
 	Latency(ms):
@@ -167,43 +276,216 @@ func stressRun() error {
 		qps:                %f
 		ignored errors:     %d
 `, latencyMin, latencyAvg, latencyMax, latency95, totalTime, totalQueries, qps, ignoredErr)
-	fmt.Println(result)
 
-	return nil
+	if len(res.Endpoints) > 1 {
+		result += "\n\tEndpoint statistics:\n"
+		for _, ep := range res.Endpoints {
+			epMin, epMax := ep.Latency.MinAndMax()
+			result += fmt.Sprintf(`		%s (%s):
+			latency(ms):        min %d, avg %d, max %d, 95th %d
+			total queries:      %d
+			qps:                %f
+			ignored errors:     %d
+`, ep.Name, ep.Role, epMin, ep.Latency.Avg(), epMax, ep.Latency.Percentile(0.95), ep.Latency.Count(), float64(ep.Latency.Count())/totalTime, ep.Errors)
+		}
+	}
+	result += formatOutages(res.Endpoints)
+	return result
 }
 
-func initLogger() zerolog.Logger {
-	if cfg.Debug {
-		zerolog.SetGlobalLevel(zerolog.DebugLevel)
-	} else {
-		zerolog.SetGlobalLevel(zerolog.InfoLevel)
+// formatOutages shows the windows each endpoint only returned connection errors, i.e. the failover downtime
+func formatOutages(endpoints []endpointResult) string {
+	result := ""
+	for _, ep := range endpoints {
+		outages := engine.MergeOutages(ep.Outages)
+		if len(outages) == 0 {
+			continue
+		}
+		var total, longest time.Duration
+		for _, o := range outages {
+			total += o.Duration()
+			if o.Duration() > longest {
+				longest = o.Duration()
+			}
+		}
+		result += fmt.Sprintf("\t\t%s: %d outages, downtime %s, longest %s\n", ep.Name, len(outages), total, longest)
+		for _, o := range outages {
+			result += fmt.Sprintf("\t\t\t%s - %s    %s\n", o.Start.Format(time.RFC3339Nano), o.End.Format(time.RFC3339Nano), o.Duration())
+		}
+	}
+	if len(result) == 0 {
+		return ""
 	}
-	logger = log.With().Logger()
-	return logger
+	return "\n\tFailover statistics:\n" + result
 }
 
-func single(ctx context.Context, wp *workerpool.WorkerPool, rds internal.RDS, query string) ([]int, []int) {
-	var errNum []int
-	var rtRes []int
+// newCluster connects to every stress endpoint, roles not given in host file are detected from read_only
+func newCluster(ctx context.Context, sc scenario) (*cluster.Cluster, []internal.RDS, error) {
+	endpoints, err := stressEndpoints()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var instances []internal.RDS
+	var members []*cluster.Member
+	stats = make(map[string]*endpointStats, len(endpoints))
+	for _, ep := range endpoints {
+		epCfg := cfg
+		epCfg.DSN.Host = ep.host
+		epCfg.DSN.Port = ep.port
+		i, err := newRDS(epCfg)
+		if err != nil {
+			return nil, instances, err
+		}
+		instances = append(instances, i)
+
+		var readOnly bool
+		switch ep.role {
+		case "writer":
+		case "reader":
+			readOnly = true
+		default:
+			readOnly, err = i.ReadOnly(ctx)
+			if err != nil {
+				return nil, instances, err
+			}
+		}
+
+		name := net.JoinHostPort(ep.host, strconv.Itoa(ep.port))
+		role := "writer"
+		if readOnly {
+			role = "reader"
+		}
+		logger.Debug().Str("endpoint", name).Str("role", role).Int("weight", ep.weight).Msg("endpoint added")
+		members = append(members, &cluster.Member{Name: name, Weight: ep.weight, ReadOnly: readOnly, RDS: i})
+		stats[name] = &endpointStats{role: role, latency: utils.NewHistogram()}
+	}
+
+	policy := stressPolicy(sc, members)
+	c, err := cluster.New(members, policy)
+	return c, instances, err
+}
+
+// stressPolicy is the --route of the scenario, read-write by default when endpoints have both roles since
+// readers fail every write they get. Writes spread over both roles by another policy are warned about.
+func stressPolicy(sc scenario, members []*cluster.Member) cluster.Policy {
+	var writers, readers int
+	for _, m := range members {
+		if m.ReadOnly {
+			readers++
+		} else {
+			writers++
+		}
+	}
+	mixed := writers > 0 && readers > 0
+	if len(sc.Route) == 0 {
+		if mixed {
+			logger.Info().Int("writers", writers).Int("readers", readers).Msg("writer and readers given, read-write routing")
+			return cluster.ReadWrite
+		}
+		return cluster.RoundRobin
+	}
+
+	policy := cluster.Policy(sc.Route)
+	if mixed && policy != cluster.ReadWrite && hasWrites(sc) {
+		logger.Warn().Str("route", sc.Route).Msg("writes are sent to readers too and fail there, use --route read-write")
+	}
+	return policy
+}
+
+// hasWrites reports whether the scenario runs statements a reader can't serve
+func hasWrites(sc scenario) bool {
+	if len(sc.Query) > 0 && !cluster.IsRead(sc.Query) {
+		return true
+	}
+	for stmt := range sc.Statements {
+		if !cluster.IsRead(stmt) {
+			return true
+		}
+	}
+	return false
+}
+
+// stressEndpoints are the --host endpoints, or the host file ones when given. An endpoint given twice is refused,
+// its results would be reported as one, a weight gives it more queries.
+func stressEndpoints() ([]endpoint, error) {
+	endpoints, err := listStressEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(endpoints))
+	for _, ep := range endpoints {
+		name := net.JoinHostPort(ep.host, strconv.Itoa(ep.port))
+		if seen[name] {
+			return nil, fmt.Errorf("endpoint %s given twice, give it a weight with --host-file and --route weighted instead", name)
+		}
+		seen[name] = true
+	}
+	return endpoints, nil
+}
+
+func listStressEndpoints() ([]endpoint, error) {
+	if len(hostFile) == 0 {
+		endpoints := make([]endpoint, 0, len(hosts))
+		for _, h := range hosts {
+			host, port, err := splitHostPort(h, cfg.DSN.Port)
+			if err != nil {
+				return nil, err
+			}
+			endpoints = append(endpoints, endpoint{host: host, port: port, weight: 1})
+		}
+		return endpoints, nil
+	}
+
+	lines, err := utils.FileLineByLine(hostFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoints []endpoint
+	for index, line := range lines {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		host, port, err := splitHostPort(fields[0], cfg.DSN.Port)
+		if err != nil {
+			return nil, err
+		}
+		ep := endpoint{host: host, port: port, weight: 1}
+		for _, field := range fields[1:] {
+			switch field {
+			case "writer", "reader":
+				ep.role = field
+			default:
+				ep.weight, err = strconv.Atoi(field)
+				if err != nil {
+					return nil, fmt.Errorf("host file line %d: invalid weight %s", index+1, field)
+				}
+			}
+		}
+		endpoints = append(endpoints, ep)
+	}
+	return endpoints, nil
+}
 
+func single(ctx context.Context, wp *workerpool.WorkerPool, c *cluster.Cluster, query string) {
 	for {
 		select {
 		case <-ctx.Done():
 			wp.Stop()
-			return errNum, rtRes
+			return
 		default:
 			// when waiting queue too long, do nothing
 			if wp.WaitingQueueSize() > waitQueueCap {
 				logger.Debug().Msg("worker pool waiting queue too long")
 				continue
 			}
+			m := c.Pick(query)
 			wp.Submit(func() {
-				rt, err := rds.Stress(ctx, query)
-				if err != nil {
-					errNum = append(errNum, 1)
-				} else {
-					rtRes = append(rtRes, int(rt))
-				}
+				rt, err := m.RDS.Stress(ctx, query)
+				stats[m.Name].add(rt, err)
 			})
 		}
 	}
@@ -229,15 +511,12 @@ func stmtGen(ctx context.Context, wrc WeightedRandomChoice.WeightedRandomChoice,
 	}
 }
 
-func multiple(ctx context.Context, wp *workerpool.WorkerPool, rds internal.RDS, sqlChan chan string) ([]int, []int) {
-	var errNum []int
-	var rtRes []int
-
+func multiple(ctx context.Context, wp *workerpool.WorkerPool, c *cluster.Cluster, sqlChan chan string) {
 	for {
 		select {
 		case <-ctx.Done():
 			wp.Stop()
-			return errNum, rtRes
+			return
 		default:
 			// when waiting queue too long, do nothing
 			if wp.WaitingQueueSize() > waitQueueCap {
@@ -246,14 +525,10 @@ func multiple(ctx context.Context, wp *workerpool.WorkerPool, rds internal.RDS,
 			}
 			select {
 			case query := <-sqlChan:
+				m := c.Pick(query)
 				wp.Submit(func() {
-					rt, err := rds.Stress(ctx, query)
-					if err != nil {
-						errNum = append(errNum, 1)
-					} else {
-						rtRes = append(rtRes, int(rt))
-					}
-
+					rt, err := m.RDS.Stress(ctx, query)
+					stats[m.Name].add(rt, err)
 				})
 			default:
 				// when sql channel empty, do nothing, won't block
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// StressAgentCmd runs stress scenarios sent by a coordinator
+	StressAgentCmd = &cobra.Command{
+		Use:   "agent",
+		Short: "Wait for stress jobs from a coordinator",
+		Long: `Listen over HTTP for stress scenarios sent by 'rdsdba stress coordinate',
+run them against the endpoints given by this agent's own connection flags and send back the latency histograms.
+Every request must carry the shared --token(or env RDSDBA_AGENT_TOKEN) given to the coordinator too, as the agent runs
+any statement it receives with its own credentials. Listen on another address than localhost only on a trusted network.`,
+		Annotations: map[string]string{enginesAnnotation: engineMySQL + "," + enginePostgres},
+		Run: func(cmd *cobra.Command, args []string) {
+			err := agentRun()
+			if err != nil {
+				os.Exit(1)
+			}
+		},
+	}
+	listenAddr string
+	agentToken string
+	agentMu    sync.Mutex
+
+	// agentRunScenario runs the jobs, tests replace it to run without database
+	agentRunScenario = runScenario
+
+	ErrAgentToken = errors.New("agent token missing, give --token or env " + agentTokenEnv)
+)
+
+const agentTokenEnv = "RDSDBA_AGENT_TOKEN"
+
+func init() {
+	StressCmd.AddCommand(StressAgentCmd)
+
+	StressAgentCmd.Flags().StringVar(&listenAddr, "listen", "127.0.0.1:7070", "address the agent listens on, e.g. :7070 for every interface")
+	StressAgentCmd.Flags().StringVar(&agentToken, "token", "", "shared token coordinators must send, env "+agentTokenEnv+" when not given")
+	StressAgentCmd.Flags().StringVar(&hostFile, "host-file", "", "file listing the endpoints to stress, one per line in format: host[:port] [weight] [writer|reader]")
+}
+
+func agentRun() error {
+	token, err := resolveAgentToken()
+	if err != nil {
+		logger.Error().Err(err).Msg("")
+		return err
+	}
+
+	logger.Info().Str("listen", listenAddr).Msg("Agent started")
+	err = http.ListenAndServe(listenAddr, agentHandler(token))
+	logger.Error().Err(err).Msg("")
+	return err
+}
+
+// resolveAgentToken is --token, or the env when not given
+func resolveAgentToken() (string, error) {
+	token := agentToken
+	if len(token) == 0 {
+		token = os.Getenv(agentTokenEnv)
+	}
+	if len(token) == 0 {
+		return "", ErrAgentToken
+	}
+	return token, nil
+}
+
+// agentHandler serves the agent API to requests with the bearer token
+func agentHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/health", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/v1/run", agentHandleRun)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+			logger.Warn().Str("remote", r.RemoteAddr).Str("path", r.URL.Path).Msg("request without valid token refused")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func agentHandleRun(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// one scenario at a time, a second coordinator would skew both results
+	if !agentMu.TryLock() {
+		http.Error(w, "agent busy", http.StatusConflict)
+		return
+	}
+	defer agentMu.Unlock()
+
+	var sc scenario
+	if err := json.NewDecoder(r.Body).Decode(&sc); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	logger.Info().Int("threads", sc.Threads).Dur("duration", sc.Duration).Time("start_at", sc.StartAt).Msg("Job received")
+
+	res, err := agentRunScenario(r.Context(), sc)
+	if err != nil {
+		logger.Error().Err(err).Msg("Job failed")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res.Agent, _ = os.Hostname()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		logger.Error().Err(err).Msg("")
+	}
+}
@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"rdsdba/internal/utils"
+)
+
+// fakeAgent serves the agent API on localhost, jobs run agentRunScenario
+func fakeAgent(t *testing.T, token string) *httptest.Server {
+	server := httptest.NewServer(agentHandler(token))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAgentRun(t *testing.T) {
+	run := agentRunScenario
+	t.Cleanup(func() { agentRunScenario = run })
+	var received scenario
+	agentRunScenario = func(ctx context.Context, sc scenario) (*stressResult, error) {
+		received = sc
+		latency := utils.NewHistogram()
+		latency.Record(sc.Threads)
+		latency.Record(10)
+		return &stressResult{TotalTime: float64(sc.Threads), Endpoints: []endpointResult{
+			{Name: "writer:3306", Role: "writer", Errors: 1, Latency: latency},
+		}}, nil
+	}
+
+	sc := scenario{Query: "select 1", Threads: 2, Duration: time.Second, Route: "rw"}
+	agent := fakeAgent(t, "secret")
+	res, err := sendScenario(agent.Client(), agent.URL, "secret", sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received.Query != sc.Query || received.Threads != sc.Threads || received.Duration != sc.Duration || received.Route != sc.Route {
+		t.Errorf("agent received %+v, want %+v", received, sc)
+	}
+	if len(res.Endpoints) != 1 || res.Endpoints[0].Latency.Count() != 2 || res.Endpoints[0].Errors != 1 || len(res.Agent) == 0 {
+		t.Errorf("result = %+v", res)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header string
+		body   string
+		want   int
+	}{
+		{"no token", http.MethodPost, "/v1/run", "", "{}", http.StatusUnauthorized},
+		{"wrong token", http.MethodPost, "/v1/run", "Bearer other", "{}", http.StatusUnauthorized},
+		{"not bearer", http.MethodPost, "/v1/run", "secret", "{}", http.StatusUnauthorized},
+		{"health without token", http.MethodGet, "/v1/health", "", "", http.StatusUnauthorized},
+		{"health", http.MethodGet, "/v1/health", "Bearer secret", "", http.StatusOK},
+		{"get run", http.MethodGet, "/v1/run", "Bearer secret", "", http.StatusMethodNotAllowed},
+		{"bad body", http.MethodPost, "/v1/run", "Bearer secret", "{", http.StatusBadRequest},
+		{"run", http.MethodPost, "/v1/run", "Bearer secret", `{"query":"select 1","threads":1}`, http.StatusOK},
+	}
+	for _, test := range tests {
+		req, err := http.NewRequest(test.method, agent.URL+test.path, strings.NewReader(test.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(test.header) > 0 {
+			req.Header.Set("Authorization", test.header)
+		}
+		resp, err := agent.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.want {
+			t.Errorf("%s: status %d, want %d", test.name, resp.StatusCode, test.want)
+		}
+	}
+
+	if _, err = sendScenario(agent.Client(), agent.URL, "other", sc); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("sendScenario with wrong token: err = %v, want 401", err)
+	}
+}
+
+func TestMergeResults(t *testing.T) {
+	run := agentRunScenario
+	t.Cleanup(func() { agentRunScenario = run })
+	// every agent reports its thread count as latency and time, a reader only from the second agent
+	agentRunScenario = func(ctx context.Context, sc scenario) (*stressResult, error) {
+		res := &stressResult{TotalTime: float64(sc.Threads)}
+		for _, name := range []string{"writer:3306", "reader:3306"}[:sc.Threads] {
+			latency := utils.NewHistogram()
+			latency.Record(sc.Threads)
+			res.Endpoints = append(res.Endpoints, endpointResult{Name: name, Errors: 1, Latency: latency})
+		}
+		return res, nil
+	}
+
+	var results []*stressResult
+	for threads := 1; threads <= 2; threads++ {
+		agent := fakeAgent(t, "secret")
+		res, err := sendScenario(agent.Client(), agent.URL, "secret", scenario{Query: "select 1", Threads: threads})
+		if err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, res)
+	}
+	// a failed agent leaves its result nil
+	results = append(results, nil)
+
+	merged, err := mergeResults(results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.TotalTime != 2 {
+		t.Errorf("total time = %f, want the longest agent 2", merged.TotalTime)
+	}
+	if len(merged.Endpoints) != 2 {
+		t.Fatalf("endpoints = %+v, want writer and reader", merged.Endpoints)
+	}
+	writer, reader := merged.Endpoints[0], merged.Endpoints[1]
+	if writer.Name != "writer:3306" || writer.Errors != 2 || writer.Latency.Count() != 2 || writer.Latency.Counts[1] != 1 || writer.Latency.Counts[2] != 1 {
+		t.Errorf("writer = %+v, latency %v", writer, writer.Latency.Counts)
+	}
+	if reader.Name != "reader:3306" || reader.Errors != 1 || reader.Latency.Count() != 1 {
+		t.Errorf("reader = %+v, latency %v", reader, reader.Latency.Counts)
+	}
+
+	if _, err = mergeResults([]*stressResult{nil, nil}); err == nil {
+		t.Error("mergeResults without result: no error")
+	}
+}
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"rdsdba/internal/utils"
+
+	"github.com/spf13/cobra"
+)
+
+const agentTimeoutMargin = time.Minute
+
+var (
+	// StressCoordinateCmd sends one scenario to several agents and merges their results
+	StressCoordinateCmd = &cobra.Command{
+		Use:   "coordinate",
+		Short: "Run the same stress scenario on several agents at once",
+		Long: `Send the same stress scenario to every agent started by 'rdsdba stress agent',
+start them together and merge their latency histograms into one report.
+Agents connect with their own connection flags, only the workload is sent with the agents' --token(or env RDSDBA_AGENT_TOKEN).`,
+		Annotations: map[string]string{enginesAnnotation: engineMySQL + "," + enginePostgres},
+		Run: func(cmd *cobra.Command, args []string) {
+			err := coordinateRun()
+			if err != nil {
+				if err == ErrFlagMissing {
+					cmd.Help()
+					fmt.Println("at lease one of flag [query file] needed!")
+				} else {
+					logger.Error().Err(err).Msg("")
+				}
+			}
+		},
+	}
+	agents     []string
+	startDelay time.Duration
+)
+
+func init() {
+	StressCmd.AddCommand(StressCoordinateCmd)
+
+	addScenarioFlags(StressCoordinateCmd)
+	StressCoordinateCmd.Flags().StringSliceVarP(&agents, "agent", "a", nil, "agent address host:port, repeat the flag or comma separate for several agents")
+	StressCoordinateCmd.Flags().StringVar(&agentToken, "token", "", "shared token of the agents, env "+agentTokenEnv+" when not given")
+	StressCoordinateCmd.Flags().DurationVar(&startDelay, "start-delay", 10*time.Second, "time given to agents to connect and prepare before all of them start, agent clocks should be in sync")
+	StressCoordinateCmd.MarkFlagRequired("agent")
+}
+
+func coordinateRun() error {
+	if len(file) == 0 && len(query) == 0 {
+		return ErrFlagMissing
+	}
+
+	token, err := resolveAgentToken()
+	if err != nil {
+		return err
+	}
+	sc, err := newScenario()
+	if err != nil {
+		return err
+	}
+	sc.StartAt = time.Now().Add(startDelay)
+
+	client := &http.Client{Timeout: startDelay + sc.Duration + agentTimeoutMargin}
+	results := make([]*stressResult, len(agents))
+	var wg sync.WaitGroup
+	for index := range agents {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			res, err := sendScenario(client, agents[index], token, sc)
+			if err != nil {
+				logger.Error().Str("agent", agents[index]).Err(err).Msg("Agent failed")
+				return
+			}
+			logger.Info().Str("agent", agents[index]).Msg("Agent finished")
+			results[index] = res
+		}(index)
+	}
+	logger.Info().Int("agents", len(agents)).Time("start_at", sc.StartAt).Msg("Scenario sent")
+	wg.Wait()
+
+	merged, err := mergeResults(results)
+	if err != nil {
+		return err
+	}
+	fmt.Println(formatResult(merged))
+
+	fmt.Println("\tAgent statistics:")
+	for index, res := range results {
+		if res == nil {
+			fmt.Printf("\t\t%s: failed\n", agents[index])
+			continue
+		}
+		var queries int64
+		var errNum int
+		for _, ep := range res.Endpoints {
+			queries += ep.Latency.Count()
+			errNum += ep.Errors
+		}
+		fmt.Printf("\t\t%s (%s): queries %d, qps %f, ignored errors %d\n", agents[index], res.Agent, queries, float64(queries)/res.TotalTime, errNum)
+	}
+
+	return nil
+}
+
+func sendScenario(client *http.Client, agent string, token string, sc scenario) (*stressResult, error) {
+	body, err := json.Marshal(sc)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.Contains(agent, "://") {
+		agent = "http://" + agent
+	}
+
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(agent, "/")+"/v1/run", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var res stressResult
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	return &res, err
+}
+
+// mergeResults adds up histograms of the same endpoint across agents, agents run in parallel so total time is the longest one
+func mergeResults(results []*stressResult) (*stressResult, error) {
+	merged := &stressResult{}
+	byName := make(map[string]int)
+	for _, res := range results {
+		if res == nil {
+			continue
+		}
+		if res.TotalTime > merged.TotalTime {
+			merged.TotalTime = res.TotalTime
+		}
+		for _, ep := range res.Endpoints {
+			index, ok := byName[ep.Name]
+			if !ok {
+				byName[ep.Name] = len(merged.Endpoints)
+				merged.Endpoints = append(merged.Endpoints, endpointResult{Name: ep.Name, Role: ep.Role, Latency: utils.NewHistogram()})
+				index = byName[ep.Name]
+			}
+			merged.Endpoints[index].Errors += ep.Errors
+			merged.Endpoints[index].Latency.Merge(ep.Latency)
+			merged.Endpoints[index].Outages = append(merged.Endpoints[index].Outages, ep.Outages...)
+		}
+	}
+	if len(merged.Endpoints) == 0 {
+		return nil, fmt.Errorf("no agent returned a result")
+	}
+	return merged, nil
+}
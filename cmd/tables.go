@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"rdsdba/internal/output"
+	"rdsdba/pkg/engine"
+	"rdsdba/pkg/mysql"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// TablesCmd lists user tables with their sizes
+	TablesCmd = &cobra.Command{
+		Use:     "tables",
+		Aliases: []string{"inventory"},
+		Short:   "List user tables with engine, rows, sizes and auto increment headroom",
+		Long: `List every user table with engine, row estimate, data/index/free size, row format, auto increment headroom,
+creation and update time and partitioning. Tables are selected with the same --only/--skip patterns as warmup,
+globs are allowed e.g. --only 'orders.*' --skip '*.tmp_*'.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := tablesRun()
+			if err != nil {
+				logger.Error().Err(err).Msg("")
+				os.Exit(1)
+			}
+		},
+	}
+	tablesSort  string
+	tablesLimit int
+)
+
+// tableSorters order tables by the --sort key, sizes and counts from the largest
+var tableSorters = map[string]func(a, b mysql.TableInfo) bool{
+	"name":    func(a, b mysql.TableInfo) bool { return a.String() < b.String() },
+	"rows":    func(a, b mysql.TableInfo) bool { return a.Rows > b.Rows },
+	"data":    func(a, b mysql.TableInfo) bool { return a.DataLength > b.DataLength },
+	"index":   func(a, b mysql.TableInfo) bool { return a.IndexLength > b.IndexLength },
+	"free":    func(a, b mysql.TableInfo) bool { return a.DataFree > b.DataFree },
+	"total":   func(a, b mysql.TableInfo) bool { return a.TotalLength() > b.TotalLength() },
+	"autoinc": func(a, b mysql.TableInfo) bool { return a.AutoIncUsed > b.AutoIncUsed },
+	"created": func(a, b mysql.TableInfo) bool { return a.CreateTime > b.CreateTime },
+	"updated": func(a, b mysql.TableInfo) bool { return a.UpdateTime > b.UpdateTime },
+}
+
+func init() {
+	RootCmd.AddCommand(TablesCmd)
+
+	TablesCmd.Flags().StringSliceVarP(&only, "only", "o", nil, "only list tables matching these patterns, comma separated format:schema_name.table_name, globs allowed")
+	TablesCmd.Flags().StringSliceVarP(&skip, "skip", "s", nil, "skip tables matching these patterns, comma separated format:schema_name.table_name, globs allowed")
+	TablesCmd.Flags().StringVar(&tablesSort, "sort", "total", "sort by name, rows, data, index, free, total, autoinc, created or updated")
+	TablesCmd.Flags().IntVar(&tablesLimit, "limit", 0, "only show the first n tables, 0 shows all")
+	addFormatFlag(TablesCmd)
+}
+
+func tablesRun() error {
+	less, ok := tableSorters[tablesSort]
+	if !ok {
+		return fmt.Errorf("unknown sort key %s", tablesSort)
+	}
+	if err := output.CheckFormat(outputFormat); err != nil {
+		return err
+	}
+	filter, err := engine.NewTableFilter(only, skip)
+	if err != nil {
+		return err
+	}
+
+	i, err := mysql.NewInstance(cfg)
+	if err != nil {
+		return err
+	}
+	defer i.Close()
+
+	all, err := i.TableInfos(context.Background())
+	if err != nil {
+		return err
+	}
+	var tables []mysql.TableInfo
+	for _, t := range all {
+		if filter.Match(t.Table) {
+			tables = append(tables, t)
+		}
+	}
+	sort.SliceStable(tables, func(a, b int) bool { return less(tables[a], tables[b]) })
+	if tablesLimit > 0 && len(tables) > tablesLimit {
+		tables = tables[:tablesLimit]
+	}
+
+	header := []string{"TABLE", "ENGINE", "ROW_FORMAT", "ROWS", "DATA", "INDEX", "FREE", "TOTAL", "AUTO_INC_USED", "PARTITIONS", "CREATED", "UPDATED"}
+	rows := make([][]string, 0, len(tables))
+	for _, t := range tables {
+		autoInc := ""
+		if t.AutoIncMax > 0 {
+			autoInc = fmt.Sprintf("%.2f%%", t.AutoIncUsed*100)
+		}
+		rows = append(rows, []string{
+			t.String(), t.Engine, t.RowFormat, strconv.FormatInt(t.Rows, 10),
+			sizeCell(t.DataLength), sizeCell(t.IndexLength), sizeCell(t.DataFree), sizeCell(t.TotalLength()),
+			autoInc, strconv.Itoa(t.Partitions), t.CreateTime, t.UpdateTime,
+		})
+	}
+	if tables == nil {
+		tables = []mysql.TableInfo{}
+	}
+	return printReport(tables, header, rows)
+}
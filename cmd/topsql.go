@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"time"
+
+	"rdsdba/internal/output"
+	"rdsdba/pkg/mysql"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	// TopSQLCmd ranks statement digests
+	TopSQLCmd = &cobra.Command{
+		Use:   "top-sql",
+		Short: "Rank statement digests by latency, rows examined, scans, tmp tables and errors",
+		Long: `Rank statement digests of performance_schema.events_statements_summary_by_digest by total or average latency,
+rows examined per row sent, full scans(no index used), tmp disk tables or errors. The counters are sums since the server
+started or the table was truncated, --interval takes two snapshots and ranks what ran in between instead, e.g. --interval 60s.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			err := topSQLRun()
+			if err != nil {
+				logger.Error().Err(err).Msg("")
+				os.Exit(1)
+			}
+		},
+	}
+	topSQLSort     string
+	topSQLLimit    int
+	topSQLInterval time.Duration
+)
+
+// digestSorters order digests by the --sort key, the most expensive first
+var digestSorters = map[string]func(a, b mysql.Digest) bool{
+	"total":    func(a, b mysql.Digest) bool { return a.TotalLatency > b.TotalLatency },
+	"avg":      func(a, b mysql.Digest) bool { return a.AvgLatency() > b.AvgLatency() },
+	"max":      func(a, b mysql.Digest) bool { return a.MaxLatency > b.MaxLatency },
+	"count":    func(a, b mysql.Digest) bool { return a.Count > b.Count },
+	"lock":     func(a, b mysql.Digest) bool { return a.LockTime > b.LockTime },
+	"examined": func(a, b mysql.Digest) bool { return a.ExaminedPerSent() > b.ExaminedPerSent() },
+	"scans":    func(a, b mysql.Digest) bool { return a.NoIndexUsed > b.NoIndexUsed },
+	"tmpdisk":  func(a, b mysql.Digest) bool { return a.TmpDiskTables > b.TmpDiskTables },
+	"errors":   func(a, b mysql.Digest) bool { return a.Errors > b.Errors },
+}
+
+func init() {
+	RootCmd.AddCommand(TopSQLCmd)
+
+	TopSQLCmd.Flags().StringVar(&topSQLSort, "sort", "total", "sort by total, avg, max, count, lock, examined(rows examined per row sent), scans, tmpdisk or errors")
+	TopSQLCmd.Flags().IntVar(&topSQLLimit, "limit", 20, "only show the first n digests, 0 shows all")
+	TopSQLCmd.Flags().DurationVar(&topSQLInterval, "interval", 0, "rank the delta between two snapshots taken this interval apart instead of the totals since server start")
+	TopSQLCmd.Flags().BoolVar(&processFull, "full", false, "don't truncate statements in table output")
+	addFormatFlag(TopSQLCmd)
+}
+
+func topSQLRun() error {
+	less, ok := digestSorters[topSQLSort]
+	if !ok {
+		return fmt.Errorf("unknown sort key %s", topSQLSort)
+	}
+	if err := output.CheckFormat(outputFormat); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cfg.HealthCheckInterval = 0
+	i, err := mysql.NewInstance(cfg)
+	if err != nil {
+		return err
+	}
+	defer i.Close()
+
+	digests, err := i.Digests(ctx)
+	if err != nil {
+		return err
+	}
+	if topSQLInterval > 0 {
+		logger.Info().Dur("interval", topSQLInterval).Int("digests", len(digests)).Msg("first snapshot taken, waiting for the second")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(topSQLInterval):
+		}
+		after, err := i.Digests(ctx)
+		if err != nil {
+			return err
+		}
+		digests = mysql.DigestDelta(digests, after)
+	}
+
+	sort.SliceStable(digests, func(a, b int) bool { return less(digests[a], digests[b]) })
+	if topSQLLimit > 0 && len(digests) > topSQLLimit {
+		digests = digests[:topSQLLimit]
+	}
+
+	header := []string{"SCHEMA", "COUNT", "TOTAL_S", "AVG_MS", "MAX_MS", "LOCK_S", "ROWS_EXAMINED", "ROWS_SENT", "EXAMINED/SENT",
+		"NO_INDEX", "TMP_DISK", "ERRORS", "DIGEST", "QUERY"}
+	rows := make([][]string, 0, len(digests))
+	for _, d := range digests {
+		query := d.Text
+		if outputFormat == output.Table {
+			query = statement(query)
+		}
+		rows = append(rows, []string{
+			d.Schema, strconv.FormatUint(d.Count, 10), fmt.Sprintf("%.3f", d.TotalLatency), fmt.Sprintf("%.3f", d.AvgLatency()*1000),
+			fmt.Sprintf("%.3f", d.MaxLatency*1000), fmt.Sprintf("%.3f", d.LockTime),
+			strconv.FormatUint(d.RowsExamined, 10), strconv.FormatUint(d.RowsSent, 10), fmt.Sprintf("%.1f", d.ExaminedPerSent()),
+			strconv.FormatUint(d.NoIndexUsed, 10), strconv.FormatUint(d.TmpDiskTables, 10), strconv.FormatUint(d.Errors, 10),
+			d.Digest, query,
+		})
+	}
+	if digests == nil {
+		digests = []mysql.Digest{}
+	}
+	return printReport(digests, header, rows)
+}
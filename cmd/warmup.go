@@ -19,15 +19,14 @@ import (
 	"context"
 	"os"
 	"rdsdba/internal"
+	"rdsdba/pkg/engine"
 	"rdsdba/pkg/mysql"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
-
-	mapset "github.com/deckarep/golang-set/v2"
 )
 
 var (
@@ -35,15 +34,17 @@ var (
 	logger       zerolog.Logger
 	skip         []string
 	only         []string
-	userTables   []mysql.Table
-	warmUpTables []mysql.Table
+	userTables   []engine.Table
+	warmUpTables []engine.Table
 	progress     string
 
 	WarmupCmd = &cobra.Command{
 		Use:   "warmup",
-		Short: "Warm up MySQL InnoDB buffer pool",
+		Short: "Warm up MySQL InnoDB buffer pool or PostgreSQL shared buffers",
 		Long: `Warm up RDS MySQL instance InnoDB buffer pool by reading data on disk and load into memory to
-speed up upcoming traffic.`,
+speed up upcoming traffic. With engine postgres tables and indexes are loaded with pg_prewarm,
+or read by sequential scans when the extension is not installed.`,
+		Annotations: map[string]string{enginesAnnotation: engineMySQL + "," + enginePostgres},
 		Run: func(cmd *cobra.Command, args []string) {
 			err := run()
 			if err != nil {
@@ -57,77 +58,64 @@ func init() {
 	RootCmd.AddCommand(WarmupCmd)
 
 	WarmupCmd.Flags().IntVarP(&cfg.Concurrency, "thread", "t", 1, "number of threads")
-	WarmupCmd.Flags().StringSliceVarP(&skip, "skip", "s", nil, "skip cold tables to let them stay on disk, comma separated format:schema_name1.table_name1,schema_name2.table_name2, whitespaces between comma is allowed ")
-	WarmupCmd.Flags().StringSliceVarP(&only, "only", "o", nil, "only load specific tables to memory, comma separated format:schema_name.table_name, schema_name2.table_name2, whitespaces between comma is allowed")
+	WarmupCmd.Flags().StringSliceVarP(&skip, "skip", "s", nil, "skip cold tables to let them stay on disk, comma separated format:schema_name1.table_name1,schema_name2.table_name2, whitespaces between comma is allowed, globs allowed e.g. archive.*")
+	WarmupCmd.Flags().StringSliceVarP(&only, "only", "o", nil, "only load specific tables to memory, comma separated format:schema_name.table_name, schema_name2.table_name2, whitespaces between comma is allowed, globs allowed e.g. orders.*")
+	WarmupCmd.Flags().DurationVar(&cfg.Sleep, "sleep", 0, "pause of each thread after every table to leave I/O to the application, e.g. 500ms")
 	WarmupCmd.MarkFlagsMutuallyExclusive("skip", "only")
 }
 
-func warmUp(ctx context.Context, rds internal.RDS, table mysql.Table) error {
+// throttle pauses --sleep between units of work, warmup tables or checksum chunks, and returns early when ctx is done
+func throttle(ctx context.Context) {
+	if cfg.Sleep <= 0 {
+		return
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(cfg.Sleep):
+	}
+}
+
+func warmUp(ctx context.Context, rds internal.RDS, table engine.Table) error {
 	err := rds.WarmUp(ctx, table)
 	return err
 }
 
-func getUserTables(ctx context.Context, rds internal.RDS) ([]mysql.Table, error) {
+func getUserTables(ctx context.Context, rds internal.RDS) ([]engine.Table, error) {
 	tables, err := rds.GetUserTables(ctx)
 	return tables, err
 }
 
-func removeSkipTables(allUserTables []mysql.Table, skipTablesStr []string) ([]mysql.Table, error) {
-	skipTables, err := mysql.TabStrToTabStruct(skipTablesStr)
-	if err != nil {
-		return nil, err
-	}
-
-	skipTableSet := mapset.NewSet[mysql.Table]()
-	for _, skipTable := range skipTables {
-		skipTableSet.Add(skipTable)
-	}
-
-	allUserTablesSet := mapset.NewSet[mysql.Table]()
-	for _, table := range allUserTables {
-		allUserTablesSet.Add(table)
-	}
-
-	warmUpTableSet := allUserTablesSet.Difference(skipTableSet)
-	warmUpTables := warmUpTableSet.ToSlice()
-
-	return warmUpTables, nil
-}
-
 func run() error {
 	if cfg.Concurrency <= 0 {
 		cfg.Concurrency = 1
 	}
+	cfg.SessionVars = withDefaults(warmupSessionVars(), cfg.SessionVars)
 
-	if cfg.Debug {
-		zerolog.SetGlobalLevel(zerolog.DebugLevel)
-	} else {
-		zerolog.SetGlobalLevel(zerolog.InfoLevel)
-	}
-	logger = log.With().Logger()
 	logger.Info().Msg("Warmup started")
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	i, err := mysql.NewInstance(cfg)
+	i, err := newRDS(cfg)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("")
 	}
-	defer i.DB.Close()
+	defer i.Close()
 	logger.Info().Msg("Instance initialised")
 
+	filter, err := engine.NewTableFilter(only, skip)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("")
+	}
 	switch {
-	case only != nil:
-		warmUpTables, err = mysql.TabStrToTabStruct(only)
-		if err != nil {
-
-		}
-	case skip != nil:
-		userTables, err = getUserTables(ctx, i)
-		warmUpTables, err = removeSkipTables(userTables, skip)
+	case only != nil && !engine.HasWildcard(only):
+		warmUpTables, err = engine.TabStrToTabStruct(only)
 	default:
-		warmUpTables, err = getUserTables(ctx, i)
+		userTables, err = getUserTables(ctx, i)
+		warmUpTables = filter.Filter(userTables)
+	}
+	if err != nil {
+		logger.Fatal().Err(err).Msg("")
 	}
 
 	chanSize := len(warmUpTables)
@@ -135,7 +123,7 @@ func run() error {
 		logger.Warn().Msg("No tables to warm up, complete!")
 		return nil
 	}
-	tableChan := make(chan mysql.Table, chanSize)
+	tableChan := make(chan engine.Table, chanSize)
 
 	go func() {
 		for index := range warmUpTables {
@@ -144,8 +132,8 @@ func run() error {
 		logger.Info().Msg("Load to queue finished")
 	}()
 
-	concurrency := i.Config.Concurrency
-	if chanSize < i.Config.Concurrency {
+	concurrency := cfg.Concurrency
+	if chanSize < cfg.Concurrency {
 		concurrency = chanSize
 		logger.Info().Int("concurrency", concurrency).Msg("Changed concurrency as number of table less than specified concurrency")
 	}
@@ -167,6 +155,7 @@ func run() error {
 					} else {
 						logger.Info().Int("Job", x).Str("Schema", table.SchemaName).Str("Table", table.TableName).Msg("Done")
 					}
+					throttle(ctx)
 					wg.Done()
 				}(x, n)
 				x += 1
@@ -0,0 +1,181 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"rdsdba/internal"
+)
+
+type Policy string
+
+const (
+	RoundRobin Policy = "round-robin"
+	Weighted   Policy = "weighted"
+	ReadWrite  Policy = "read-write"
+)
+
+var (
+	ErrNoMembers     = errors.New("no endpoints in cluster")
+	ErrUnknownPolicy = errors.New("unknown routing policy")
+	ErrNoWriter      = errors.New("read-write routing needs a writable endpoint")
+)
+
+var readVerbs = map[string]bool{
+	"SELECT":   true,
+	"SHOW":     true,
+	"DESC":     true,
+	"DESCRIBE": true,
+	"EXPLAIN":  true,
+	"TABLE":    true,
+}
+
+// cteVerbs are the statements a WITH clause can introduce
+var cteVerbs = map[string]bool{
+	"SELECT": true,
+	"TABLE":  true,
+	"VALUES": true,
+	"UPDATE": true,
+	"DELETE": true,
+	"INSERT": true,
+}
+
+// Member is one endpoint of the cluster, Name is used in reports
+type Member struct {
+	Name     string
+	Weight   int
+	ReadOnly bool
+	RDS      internal.RDS
+
+	current int
+}
+
+type Cluster struct {
+	Members []*Member
+	policy  Policy
+	readers []*Member
+	writers []*Member
+	mu      sync.Mutex
+	next    int
+	nextRd  int
+	nextWr  int
+}
+
+func New(members []*Member, policy Policy) (*Cluster, error) {
+	if len(members) == 0 {
+		return nil, ErrNoMembers
+	}
+
+	c := &Cluster{Members: members, policy: policy}
+	for _, m := range members {
+		if m.Weight <= 0 {
+			m.Weight = 1
+		}
+		if m.ReadOnly {
+			c.readers = append(c.readers, m)
+		} else {
+			c.writers = append(c.writers, m)
+		}
+	}
+
+	switch policy {
+	case RoundRobin, Weighted:
+	case ReadWrite:
+		if len(c.writers) == 0 {
+			return nil, ErrNoWriter
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPolicy, policy)
+	}
+	return c, nil
+}
+
+// Pick returns the member which should run the query according to the routing policy
+func (c *Cluster) Pick(query string) *Member {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch c.policy {
+	case Weighted:
+		return smoothWeighted(c.Members)
+	case ReadWrite:
+		if IsRead(query) && len(c.readers) > 0 {
+			m := c.readers[c.nextRd%len(c.readers)]
+			c.nextRd++
+			return m
+		}
+		m := c.writers[c.nextWr%len(c.writers)]
+		c.nextWr++
+		return m
+	default:
+		m := c.Members[c.next%len(c.Members)]
+		c.next++
+		return m
+	}
+}
+
+// smoothWeighted is the nginx smooth weighted round-robin, it spreads picks evenly instead of in bursts
+func smoothWeighted(members []*Member) *Member {
+	var best *Member
+	total := 0
+	for _, m := range members {
+		m.current += m.Weight
+		total += m.Weight
+		if best == nil || m.current > best.current {
+			best = m
+		}
+	}
+	best.current -= total
+	return best
+}
+
+// IsRead reports whether the statement can be served by a read only replica
+func IsRead(query string) bool {
+	if !readVerbs[mainVerb(query)] {
+		return false
+	}
+	upper := strings.ToUpper(query)
+	return !strings.Contains(upper, "FOR UPDATE") && !strings.Contains(upper, "FOR SHARE") && !strings.Contains(upper, "LOCK IN SHARE MODE")
+}
+
+// mainVerb is the first keyword of the statement in upper case, for WITH the statement after the common table
+// expressions: WITH x AS (...) DELETE ... is a DELETE. Parentheses and quoted text are skipped to find it.
+func mainVerb(query string) string {
+	fields := strings.Fields(strings.TrimLeft(query, "( \t\r\n"))
+	if len(fields) == 0 {
+		return ""
+	}
+	verb := strings.ToUpper(fields[0])
+	if verb != "WITH" {
+		return verb
+	}
+
+	depth := 0
+	var quote rune
+	word := strings.Builder{}
+	for _, r := range query + " " {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+			continue
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case depth == 0 && (r == '_' || r == '$' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'):
+			word.WriteRune(r)
+			continue
+		}
+		if upper := strings.ToUpper(word.String()); cteVerbs[upper] {
+			return upper
+		}
+		word.Reset()
+	}
+	return verb
+}
@@ -0,0 +1,126 @@
+package cluster
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestIsRead(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"select * from orders where id = 1", true},
+		{"  SELECT count(*) FROM orders", true},
+		{"(select id from a) union (select id from b)", true},
+		{"show processlist", true},
+		{"explain select * from orders", true},
+		{"table orders", true},
+		{"select * from orders where id = 1 for update", false},
+		{"select * from orders where id = 1 FOR SHARE", false},
+		{"select * from orders lock in share mode", false},
+		{"insert into orders values (1)", false},
+		{"update orders set paid = 1", false},
+		{"delete from orders", false},
+		{"replace into orders values (1)", false},
+		{"with recent as (select id from orders) select * from recent", true},
+		{"WITH RECURSIVE n (i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 10) SELECT * FROM n", true},
+		{"with a as (select 1), b as (select 2) select * from a, b", true},
+		{"with old as (select id from orders where created < now() - interval 1 year) delete from orders where id in (select id from old)", false},
+		{"WITH paid AS (SELECT id FROM payments) UPDATE orders o JOIN paid p ON p.id = o.id SET o.paid = 1", false},
+		{"with `update` as (select 1) select * from `update`", true},
+		{"with select1 as (select 1) delete from t", false},
+		{"with x as (select ')' as p) update t set a = 1", false},
+		{"with x as (select 1) select * from x for update", false},
+		{"with", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if got := IsRead(test.query); got != test.want {
+			t.Errorf("IsRead(%q) = %v, want %v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestPickRoundRobin(t *testing.T) {
+	c, err := New([]*Member{{Name: "a", Weight: 5}, {Name: "b"}, {Name: "c", ReadOnly: true}}, RoundRobin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := picks(c, "update t set a = 1", 6), []string{"a", "b", "c", "a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("round-robin picks %v, want %v", got, want)
+	}
+}
+
+func TestPickWeighted(t *testing.T) {
+	c, err := New([]*Member{{Name: "a", Weight: 5}, {Name: "b", Weight: 1}, {Name: "c"}}, Weighted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// smooth weighted round-robin spreads a between b and c instead of 5 in a row
+	if got, want := picks(c, "select 1", 7), []string{"a", "a", "b", "a", "c", "a", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("weighted picks %v, want %v", got, want)
+	}
+
+	counts := make(map[string]int)
+	for _, name := range picks(c, "select 1", 700) {
+		counts[name]++
+	}
+	if want := map[string]int{"a": 500, "b": 100, "c": 100}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("weighted counts %v, want %v", counts, want)
+	}
+}
+
+func TestPickReadWrite(t *testing.T) {
+	members := []*Member{{Name: "writer"}, {Name: "reader-1", ReadOnly: true}, {Name: "reader-2", ReadOnly: true}}
+	c, err := New(members, ReadWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"select 1", "reader-1"},
+		{"update t set a = 1", "writer"},
+		{"select * from t for update", "writer"},
+		{"with x as (select 1) delete from t", "writer"},
+		{"with x as (select 1) select * from x", "reader-2"},
+		{"select 2", "reader-1"},
+	}
+	for _, test := range tests {
+		if got := c.Pick(test.query).Name; got != test.want {
+			t.Errorf("Pick(%q) = %s, want %s", test.query, got, test.want)
+		}
+	}
+
+	// without readers everything goes to the writer
+	c, err = New(members[:1], ReadWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Pick("select 1").Name; got != "writer" {
+		t.Errorf("Pick without readers = %s, want writer", got)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(nil, RoundRobin); !errors.Is(err, ErrNoMembers) {
+		t.Errorf("New without members: %v, want %v", err, ErrNoMembers)
+	}
+	if _, err := New([]*Member{{Name: "reader", ReadOnly: true}}, ReadWrite); !errors.Is(err, ErrNoWriter) {
+		t.Errorf("New read-write without writer: %v, want %v", err, ErrNoWriter)
+	}
+	if _, err := New([]*Member{{Name: "a"}}, "random"); !errors.Is(err, ErrUnknownPolicy) {
+		t.Errorf("New with unknown policy: %v, want %v", err, ErrUnknownPolicy)
+	}
+}
+
+func picks(c *Cluster, query string, n int) []string {
+	var names []string
+	for index := 0; index < n; index++ {
+		names = append(names, c.Pick(query).Name)
+	}
+	return names
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ActionKill      = "kill"
+	ActionKillQuery = "kill-query"
+
+	defaultGuardInterval = 5 * time.Second
+)
+
+var ErrInvalidRule = errors.New("invalid guard rule")
+
+// Guard is the rules file of rdsdba guard, rules are checked in order and the first matching one applies
+type Guard struct {
+	Interval time.Duration `yaml:"interval"`
+	// ProtectedUsers are never killed, replication users are protected as well
+	ProtectedUsers []string    `yaml:"protected-users"`
+	Rules          []GuardRule `yaml:"rules"`
+}
+
+type GuardRule struct {
+	Name string `yaml:"name"`
+	// Action is kill or kill-query, Enforce false only logs what would be killed
+	Action  string     `yaml:"action"`
+	Enforce bool       `yaml:"enforce"`
+	Match   GuardMatch `yaml:"match"`
+}
+
+// GuardMatch uses the processlist filters, MinTime and MinTrxTime are seconds
+type GuardMatch struct {
+	User       string `yaml:"user"`
+	Host       string `yaml:"host"`
+	DB         string `yaml:"db"`
+	Command    string `yaml:"command"`
+	State      string `yaml:"state"`
+	Info       string `yaml:"info"`
+	MinTime    int64  `yaml:"min-time"`
+	MinTrxTime int64  `yaml:"min-trx-time"`
+}
+
+func LoadGuard(path string) (*Guard, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	g := Guard{Interval: defaultGuardInterval}
+	if err = yaml.Unmarshal(content, &g); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if g.Interval <= 0 {
+		return nil, fmt.Errorf("parse %s: interval must be positive", path)
+	}
+	for index, rule := range g.Rules {
+		if len(rule.Name) == 0 {
+			return nil, fmt.Errorf("%w: rule %d has no name", ErrInvalidRule, index+1)
+		}
+		if rule.Action != ActionKill && rule.Action != ActionKillQuery {
+			return nil, fmt.Errorf("%w %s: action must be %s or %s", ErrInvalidRule, rule.Name, ActionKill, ActionKillQuery)
+		}
+		// a rule without conditions would kill every thread
+		if rule.Match == (GuardMatch{}) {
+			return nil, fmt.Errorf("%w %s: match at least one condition", ErrInvalidRule, rule.Name)
+		}
+	}
+	return &g, nil
+}
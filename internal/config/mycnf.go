@@ -0,0 +1,106 @@
+package config
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultOptionFile is ~/.my.cnf, empty when home directory unknown
+func DefaultOptionFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".my.cnf")
+}
+
+// ReadOptionFile reads the given groups of a MySQL option file(my.cnf), later groups override earlier ones.
+// Option names are returned with '-' as mysql treats '-' and '_' the same.
+func ReadOptionFile(path string, groups ...string) (map[string]string, error) {
+	wanted := make(map[string]int, len(groups))
+	for index, group := range groups {
+		wanted[group] = index
+	}
+
+	found := make([]map[string]string, len(groups))
+	if err := readOptionFile(path, wanted, found, 0); err != nil {
+		return nil, err
+	}
+
+	options := make(map[string]string)
+	for _, groupOptions := range found {
+		for key, value := range groupOptions {
+			options[key] = value
+		}
+	}
+	return options, nil
+}
+
+func readOptionFile(path string, wanted map[string]int, found []map[string]string, depth int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	current := -1
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case len(line) == 0 || line[0] == '#' || line[0] == ';':
+			continue
+		case strings.HasPrefix(line, "!include "):
+			// include depth is limited to avoid include loops
+			if depth < 10 {
+				if err := readOptionFile(strings.TrimSpace(line[len("!include "):]), wanted, found, depth+1); err != nil {
+					return err
+				}
+			}
+			continue
+		case strings.HasPrefix(line, "!includedir "):
+			if depth < 10 {
+				files, _ := filepath.Glob(filepath.Join(strings.TrimSpace(line[len("!includedir "):]), "*.cnf"))
+				for _, file := range files {
+					if err := readOptionFile(file, wanted, found, depth+1); err != nil {
+						return err
+					}
+				}
+			}
+			continue
+		case line[0] == '[':
+			group := strings.TrimSpace(strings.Trim(line, "[]"))
+			index, ok := wanted[group]
+			if !ok {
+				current = -1
+				continue
+			}
+			current = index
+			if found[current] == nil {
+				found[current] = make(map[string]string)
+			}
+			continue
+		}
+		if current < 0 {
+			continue
+		}
+
+		key, value, _ := strings.Cut(line, "=")
+		key = strings.ReplaceAll(strings.TrimSpace(key), "_", "-")
+		found[current][key] = unquoteOption(strings.TrimSpace(value))
+	}
+	return scanner.Err()
+}
+
+// unquoteOption strips quotes, unquoted values may end with a # comment
+func unquoteOption(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	if index := strings.Index(value, " #"); index >= 0 {
+		value = strings.TrimSpace(value[:index])
+	}
+	return value
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const DefaultFileName = ".rdsdba.yaml"
+
+var ErrProfileNotFound = errors.New("profile not found")
+
+// File is the rdsdba config file, each profile maps long flag names to values
+type File struct {
+	DefaultProfile string                            `yaml:"default-profile"`
+	Profiles       map[string]map[string]interface{} `yaml:"profiles"`
+}
+
+// DefaultPath is ~/.rdsdba.yaml, empty when home directory unknown
+func DefaultPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, DefaultFileName)
+}
+
+func Load(path string) (*File, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var f File
+	if err = yaml.Unmarshal(content, &f); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// Profile returns the named profile as flag values, lists are comma joined as pflag slices expect
+func (f *File) Profile(name string) (map[string]string, error) {
+	profile, ok := f.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrProfileNotFound, name)
+	}
+
+	values := make(map[string]string, len(profile))
+	for key, value := range profile {
+		switch v := value.(type) {
+		case []interface{}:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				items = append(items, fmt.Sprint(item))
+			}
+			values[key] = strings.Join(items, ",")
+		case nil:
+		default:
+			values[key] = fmt.Sprint(v)
+		}
+	}
+	return values, nil
+}
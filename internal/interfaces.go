@@ -2,11 +2,16 @@ package internal
 
 import (
 	"context"
-	"rdsdba/pkg/mysql"
+	"rdsdba/pkg/engine"
 )
 
+// RDS is implemented by every database engine package
 type RDS interface {
-	GetUserTables(ctx context.Context) ([]mysql.Table, error)
-	WarmUp(ctx context.Context, table mysql.Table) error
+	GetUserTables(ctx context.Context) ([]engine.Table, error)
+	WarmUp(ctx context.Context, table engine.Table) error
 	Stress(ctx context.Context, query string) (int64, error)
+	ReadOnly(ctx context.Context) (bool, error)
+	// Outages are windows in which the endpoint only returned connection errors
+	Outages() []engine.Outage
+	Close() error
 }
@@ -0,0 +1,71 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	Table = "table"
+	JSON  = "json"
+	CSV   = "csv"
+)
+
+var ErrUnknownFormat = errors.New("unknown output format, use table, json or csv")
+
+// CheckFormat validates an output format flag
+func CheckFormat(format string) error {
+	switch format {
+	case Table, JSON, CSV:
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+}
+
+// Print writes data as indented JSON, or header and rows as an aligned table or CSV
+func Print(w io.Writer, format string, data interface{}, header []string, rows [][]string) error {
+	switch format {
+	case JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(data)
+	case CSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+		return cw.Error()
+	case Table:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+}
+
+// Bytes formats a size in bytes with binary units, e.g. 1.5G
+func Bytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	value := float64(n) / unit
+	for _, suffix := range "KMGTP" {
+		if value < unit {
+			return fmt.Sprintf("%.1f%c", value, suffix)
+		}
+		value /= unit
+	}
+	return fmt.Sprintf("%.1fE", value)
+}
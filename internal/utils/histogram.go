@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"math"
+	"sort"
+)
+
+// Histogram counts values(latency in ms) by exact value, histograms from several runs can be merged
+type Histogram struct {
+	Counts map[int]int64 `json:"counts"`
+}
+
+func NewHistogram() *Histogram {
+	return &Histogram{Counts: make(map[int]int64)}
+}
+
+func (h *Histogram) Record(v int) {
+	h.Counts[v]++
+}
+
+func (h *Histogram) Merge(o *Histogram) {
+	if o == nil {
+		return
+	}
+	for v, c := range o.Counts {
+		h.Counts[v] += c
+	}
+}
+
+func (h *Histogram) Count() int64 {
+	var total int64
+	for _, c := range h.Counts {
+		total += c
+	}
+	return total
+}
+
+func (h *Histogram) MinAndMax() (min int, max int) {
+	first := true
+	for v := range h.Counts {
+		if first || v < min {
+			min = v
+		}
+		if first || v > max {
+			max = v
+		}
+		first = false
+	}
+	return min, max
+}
+
+func (h *Histogram) Avg() int {
+	var total, count int64
+	for v, c := range h.Counts {
+		total += int64(v) * c
+		count += c
+	}
+	if count == 0 {
+		return 0
+	}
+	return int(total / count)
+}
+
+// Percentile returns the smallest value which has at least p(0-1) of all values less or equal to it
+func (h *Histogram) Percentile(p float64) int {
+	count := h.Count()
+	if count == 0 {
+		return 0
+	}
+	values := make([]int, 0, len(h.Counts))
+	for v := range h.Counts {
+		values = append(values, v)
+	}
+	sort.Ints(values)
+
+	rank := int64(math.Ceil(float64(count) * p))
+	var seen int64
+	for _, v := range values {
+		seen += h.Counts[v]
+		if seen >= rank {
+			return v
+		}
+	}
+	return values[len(values)-1]
+}
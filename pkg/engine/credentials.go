@@ -0,0 +1,94 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+var ErrEmptyPassword = errors.New("password source returned an empty password")
+
+// PasswordProvider gives the password for every new connection,
+// Invalidate drops a cached password after the server refused it
+type PasswordProvider interface {
+	Password(ctx context.Context) (string, error)
+	Invalidate()
+}
+
+type staticPassword string
+
+func StaticPassword(password string) PasswordProvider {
+	return staticPassword(password)
+}
+
+func (p staticPassword) Password(ctx context.Context) (string, error) {
+	return string(p), nil
+}
+
+func (p staticPassword) Invalidate() {}
+
+// cachedPassword loads the password once and keeps it until invalidated
+type cachedPassword struct {
+	mu       sync.Mutex
+	load     func(ctx context.Context) (string, error)
+	password string
+	loaded   bool
+}
+
+func (p *cachedPassword) Password(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.loaded {
+		return p.password, nil
+	}
+
+	password, err := p.load(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(password) == 0 {
+		return "", ErrEmptyPassword
+	}
+	p.password = password
+	p.loaded = true
+	return password, nil
+}
+
+func (p *cachedPassword) Invalidate() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.password = ""
+	p.loaded = false
+}
+
+// FilePassword reads the password from the first line of a file
+func FilePassword(path string) PasswordProvider {
+	return &cachedPassword{load: func(ctx context.Context) (string, error) {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		line, _, _ := strings.Cut(string(content), "\n")
+		return strings.TrimRight(line, "\r"), nil
+	}}
+}
+
+// CommandPassword runs a helper through the shell(e.g. a secrets manager CLI wrapper) and reads the password from its stdout
+func CommandPassword(command string) PasswordProvider {
+	return &cachedPassword{load: func(ctx context.Context) (string, error) {
+		var stderr bytes.Buffer
+		c := exec.CommandContext(ctx, "sh", "-c", command)
+		c.Stderr = &stderr
+		out, err := c.Output()
+		if err != nil {
+			// stderr of the helper never contains the password, stdout may
+			return "", fmt.Errorf("password command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return strings.TrimRight(string(out), "\r\n"), nil
+	}}
+}
@@ -0,0 +1,38 @@
+package engine
+
+import (
+	"sort"
+	"time"
+)
+
+// Outage is a window in which an endpoint only returned connection errors
+type Outage struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+func (o Outage) Duration() time.Duration {
+	return o.End.Sub(o.Start)
+}
+
+// MergeOutages returns the union of outage windows, e.g. seen by several agents, sorted by start
+func MergeOutages(outages []Outage) []Outage {
+	if len(outages) == 0 {
+		return nil
+	}
+	sorted := append([]Outage(nil), outages...)
+	sort.Slice(sorted, func(a, b int) bool { return sorted[a].Start.Before(sorted[b].Start) })
+
+	merged := []Outage{sorted[0]}
+	for _, o := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if o.Start.After(last.End) {
+			merged = append(merged, o)
+			continue
+		}
+		if o.End.After(last.End) {
+			last.End = o.End
+		}
+	}
+	return merged
+}
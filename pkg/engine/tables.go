@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+type Table struct {
+	SchemaName string `mapstructure:"table_schema" json:"schema"`
+	TableName  string `mapstructure:"table_name" json:"table"`
+}
+
+func (t Table) String() string {
+	return t.SchemaName + "." + t.TableName
+}
+
+func TabStrToTabStruct(tablesStr []string) ([]Table, error) {
+	//"schema_name.table_name"
+	var tables []Table
+	var tableName string
+	var schemaName string
+	err := fmt.Errorf("unexpected format")
+	for _, table := range tablesStr {
+		res := strings.Split(table, ".")
+		if len(res) != 2 {
+			return nil, err
+		}
+		schemaName = strings.TrimSpace(res[0])
+		tableName = strings.TrimSpace(res[1])
+		tables = append(tables, Table{SchemaName: schemaName, TableName: tableName})
+	}
+
+	return tables, nil
+}
+
+// HasWildcard reports whether any of the schema.table patterns is a glob
+func HasWildcard(patterns []string) bool {
+	for _, p := range patterns {
+		if strings.ContainsAny(p, "*?[") {
+			return true
+		}
+	}
+	return false
+}
+
+// TableFilter keeps the tables matching one of only(all when empty) and none of skip,
+// patterns have the schema_name.table_name format where both parts may be globs, e.g. orders.*, *.tmp_*
+type TableFilter struct {
+	only []Table
+	skip []Table
+}
+
+func NewTableFilter(only []string, skip []string) (*TableFilter, error) {
+	onlyTables, err := TabStrToTabStruct(only)
+	if err != nil {
+		return nil, err
+	}
+	skipTables, err := TabStrToTabStruct(skip)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range append(onlyTables, skipTables...) {
+		if _, err = path.Match(p.SchemaName, ""); err != nil {
+			return nil, fmt.Errorf("invalid table pattern %s: %w", p, err)
+		}
+		if _, err = path.Match(p.TableName, ""); err != nil {
+			return nil, fmt.Errorf("invalid table pattern %s: %w", p, err)
+		}
+	}
+	return &TableFilter{only: onlyTables, skip: skipTables}, nil
+}
+
+func (f *TableFilter) Match(table Table) bool {
+	if len(f.only) > 0 && !matchAny(f.only, table) {
+		return false
+	}
+	return !matchAny(f.skip, table)
+}
+
+// Filter returns the matching tables in their original order
+func (f *TableFilter) Filter(tables []Table) []Table {
+	var matched []Table
+	for _, table := range tables {
+		if f.Match(table) {
+			matched = append(matched, table)
+		}
+	}
+	return matched
+}
+
+func matchAny(patterns []Table, table Table) bool {
+	for _, p := range patterns {
+		schemaMatched, _ := path.Match(p.SchemaName, table.SchemaName)
+		tableMatched, _ := path.Match(p.TableName, table.TableName)
+		if schemaMatched && tableMatched {
+			return true
+		}
+	}
+	return false
+}
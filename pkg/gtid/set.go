@@ -0,0 +1,274 @@
+// Package gtid parses MySQL GTID sets like @@gtid_executed and does set arithmetic on them
+package gtid
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidSet = errors.New("invalid GTID set")
+
+// SID is the source of transactions: the server_uuid, with the tag of tagged GTIDs(8.3+)
+type SID struct {
+	UUID string
+	Tag  string
+}
+
+func (s SID) String() string {
+	if len(s.Tag) > 0 {
+		return s.UUID + ":" + s.Tag
+	}
+	return s.UUID
+}
+
+// Interval is a range of transaction numbers, both ends included
+type Interval struct {
+	Start uint64
+	End   uint64
+}
+
+func (i Interval) String() string {
+	if i.Start == i.End {
+		return strconv.FormatUint(i.Start, 10)
+	}
+	return fmt.Sprintf("%d-%d", i.Start, i.End)
+}
+
+// Set is a GTID set, the intervals of each SID sorted, neither overlapping nor adjacent
+type Set map[SID][]Interval
+
+// Parse reads the text format of @@gtid_executed: uuid:1-5:7,uuid:tag:1-3, case and whitespace insensitive.
+// An empty text is the empty set.
+func Parse(text string) (Set, error) {
+	s := make(Set)
+	for _, group := range strings.Split(text, ",") {
+		group = strings.Join(strings.Fields(group), "")
+		if len(group) == 0 {
+			continue
+		}
+		parts := strings.Split(strings.ToLower(group), ":")
+		if !validUUID(parts[0]) {
+			return nil, fmt.Errorf("%w: bad uuid in %s", ErrInvalidSet, group)
+		}
+		if len(parts) == 1 {
+			return nil, fmt.Errorf("%w: no interval in %s", ErrInvalidSet, group)
+		}
+		sid := SID{UUID: parts[0]}
+		tagged := false
+		for _, part := range parts[1:] {
+			if len(part) > 0 && part[0] >= '0' && part[0] <= '9' {
+				interval, err := parseInterval(part)
+				if err != nil {
+					return nil, fmt.Errorf("%w: %s in %s", ErrInvalidSet, err, group)
+				}
+				s.Add(sid, interval.Start, interval.End)
+				tagged = false
+				continue
+			}
+			if tagged || !validTag(part) {
+				return nil, fmt.Errorf("%w: bad tag %q in %s", ErrInvalidSet, part, group)
+			}
+			sid.Tag = part
+			tagged = true
+		}
+		if tagged {
+			return nil, fmt.Errorf("%w: no interval for tag %s in %s", ErrInvalidSet, sid.Tag, group)
+		}
+	}
+	return s, nil
+}
+
+func parseInterval(text string) (Interval, error) {
+	start, end, isRange := strings.Cut(text, "-")
+	var i Interval
+	var err error
+	if i.Start, err = strconv.ParseUint(start, 10, 63); err != nil || i.Start == 0 {
+		return i, fmt.Errorf("bad interval %s", text)
+	}
+	i.End = i.Start
+	if isRange {
+		if i.End, err = strconv.ParseUint(end, 10, 63); err != nil || i.End < i.Start {
+			return i, fmt.Errorf("bad interval %s", text)
+		}
+	}
+	return i, nil
+}
+
+func validUUID(uuid string) bool {
+	if len(uuid) != 36 {
+		return false
+	}
+	for index, c := range uuid {
+		switch index {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// validTag is a lowercased tag: a letter or underscore then up to 31 letters, digits or underscores
+func validTag(tag string) bool {
+	if len(tag) == 0 || len(tag) > 32 {
+		return false
+	}
+	for index, c := range tag {
+		if !(c >= 'a' && c <= 'z' || c == '_' || index > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Add adds the transactions start to end of sid
+func (s Set) Add(sid SID, start, end uint64) {
+	intervals := append(s[sid], Interval{Start: start, End: end})
+	sort.Slice(intervals, func(a, b int) bool { return intervals[a].Start < intervals[b].Start })
+	merged := intervals[:1]
+	for _, i := range intervals[1:] {
+		last := &merged[len(merged)-1]
+		if i.Start <= last.End+1 {
+			if i.End > last.End {
+				last.End = i.End
+			}
+			continue
+		}
+		merged = append(merged, i)
+	}
+	s[sid] = merged
+}
+
+// Union is the transactions in s or o
+func (s Set) Union(o Set) Set {
+	union := s.clone()
+	for sid, intervals := range o {
+		for _, i := range intervals {
+			union.Add(sid, i.Start, i.End)
+		}
+	}
+	return union
+}
+
+// Subtract is the transactions in s which aren't in o
+func (s Set) Subtract(o Set) Set {
+	diff := make(Set)
+	for sid, intervals := range s {
+		var left []Interval
+		for _, i := range intervals {
+			left = append(left, subtract(i, o[sid])...)
+		}
+		if len(left) > 0 {
+			diff[sid] = left
+		}
+	}
+	return diff
+}
+
+// subtract removes the sorted intervals of minus from i
+func subtract(i Interval, minus []Interval) []Interval {
+	var left []Interval
+	for _, m := range minus {
+		if m.End < i.Start {
+			continue
+		}
+		if m.Start > i.End {
+			break
+		}
+		if m.Start > i.Start {
+			left = append(left, Interval{Start: i.Start, End: m.Start - 1})
+		}
+		if m.End >= i.End {
+			return left
+		}
+		i.Start = m.End + 1
+	}
+	return append(left, i)
+}
+
+// Contains reports whether every transaction of o is in s
+func (s Set) Contains(o Set) bool {
+	return o.Subtract(s).IsEmpty()
+}
+
+func (s Set) Equal(o Set) bool {
+	return s.Contains(o) && o.Contains(s)
+}
+
+func (s Set) IsEmpty() bool {
+	for _, intervals := range s {
+		if len(intervals) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Count is the number of transactions
+func (s Set) Count() uint64 {
+	var count uint64
+	for _, intervals := range s {
+		for _, i := range intervals {
+			count += i.End - i.Start + 1
+		}
+	}
+	return count
+}
+
+// SIDs are the sources with transactions, sorted
+func (s Set) SIDs() []SID {
+	sids := make([]SID, 0, len(s))
+	for sid, intervals := range s {
+		if len(intervals) > 0 {
+			sids = append(sids, sid)
+		}
+	}
+	sort.Slice(sids, func(a, b int) bool {
+		if sids[a].UUID != sids[b].UUID {
+			return sids[a].UUID < sids[b].UUID
+		}
+		return sids[a].Tag < sids[b].Tag
+	})
+	return sids
+}
+
+// String is the set in the format of @@gtid_executed on one line, uuids sorted, untagged transactions before tagged ones
+func (s Set) String() string {
+	var groups []string
+	var group strings.Builder
+	for _, sid := range s.SIDs() {
+		if group.Len() > 0 && !strings.HasPrefix(group.String(), sid.UUID) {
+			groups = append(groups, group.String())
+			group.Reset()
+		}
+		if group.Len() == 0 {
+			group.WriteString(sid.UUID)
+		}
+		if len(sid.Tag) > 0 {
+			group.WriteString(":" + sid.Tag)
+		}
+		for _, i := range s[sid] {
+			group.WriteString(":" + i.String())
+		}
+	}
+	if group.Len() > 0 {
+		groups = append(groups, group.String())
+	}
+	return strings.Join(groups, ",")
+}
+
+func (s Set) clone() Set {
+	c := make(Set, len(s))
+	for sid, intervals := range s {
+		c[sid] = append([]Interval(nil), intervals...)
+	}
+	return c
+}
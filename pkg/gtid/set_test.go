@@ -0,0 +1,103 @@
+package gtid
+
+import (
+	"errors"
+	"testing"
+)
+
+const (
+	uuidA = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	uuidB = "8a94f357-aab4-11df-86ab-c80aa9429562"
+)
+
+func mustParse(t *testing.T, text string) Set {
+	t.Helper()
+	s, err := Parse(text)
+	if err != nil {
+		t.Fatalf("Parse(%q): %s", text, err)
+	}
+	return s
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text  string
+		want  string
+		count uint64
+	}{
+		{"", "", 0},
+		{uuidA + ":1-5", uuidA + ":1-5", 5},
+		{"3E11FA47-71CA-11E1-9E33-C80AA9429562:1-5:11-18", uuidA + ":1-5:11-18", 13},
+		{uuidB + ":1-3,\n" + uuidA + ":7:1-5:6", uuidA + ":1-7," + uuidB + ":1-3", 10},
+		{uuidA + ":10-20:1-12, " + uuidA + ":30", uuidA + ":1-20:30", 21},
+		{uuidA + ":1-5:Batch:1-3:7", uuidA + ":1-5:batch:1-3:7", 9},
+	}
+	for _, test := range tests {
+		s := mustParse(t, test.text)
+		if got := s.String(); got != test.want {
+			t.Errorf("Parse(%q) = %q, want %q", test.text, got, test.want)
+		}
+		if got := s.Count(); got != test.count {
+			t.Errorf("Parse(%q).Count() = %d, want %d", test.text, got, test.count)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, text := range []string{
+		"3e11fa47-71ca-11e1-9e33:1-5",
+		uuidA,
+		uuidA + ":0",
+		uuidA + ":5-3",
+		uuidA + ":1-x",
+		uuidA + ":1tag:1",
+		uuidA + ":tag",
+		uuidA + ":a:b:1",
+	} {
+		if _, err := Parse(text); !errors.Is(err, ErrInvalidSet) {
+			t.Errorf("Parse(%q) error = %v, want ErrInvalidSet", text, err)
+		}
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{uuidA + ":1-100", uuidA + ":1-100", ""},
+		{uuidA + ":1-100", uuidA + ":1-90", uuidA + ":91-100"},
+		{uuidA + ":1-100", uuidA + ":10-20:50", uuidA + ":1-9:21-49:51-100"},
+		{uuidA + ":1-10," + uuidB + ":1-3", uuidA + ":1-10", uuidB + ":1-3"},
+		{uuidA + ":1-10", uuidA + ":1-20," + uuidB + ":1-3", ""},
+		{uuidA + ":5-10", uuidA + ":1-3:12-20", uuidA + ":5-10"},
+		{uuidA + ":1-10:t:1-5", uuidA + ":1-10", uuidA + ":t:1-5"},
+	}
+	for _, test := range tests {
+		got := mustParse(t, test.a).Subtract(mustParse(t, test.b)).String()
+		if got != test.want {
+			t.Errorf("%s - %s = %q, want %q", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestUnionContains(t *testing.T) {
+	a := mustParse(t, uuidA+":1-5:9")
+	b := mustParse(t, uuidA+":6-8,"+uuidB+":1")
+	union := a.Union(b)
+	if got := union.String(); got != uuidA+":1-9,"+uuidB+":1" {
+		t.Errorf("union = %q", got)
+	}
+	if a.String() != uuidA+":1-5:9" {
+		t.Errorf("union changed a to %q", a)
+	}
+	if !union.Contains(a) || !union.Contains(b) || a.Contains(union) {
+		t.Error("contains")
+	}
+	if !union.Equal(mustParse(t, uuidB+":1,"+uuidA+":1-9")) || union.Equal(a) {
+		t.Error("equal")
+	}
+	if !mustParse(t, "").IsEmpty() || union.IsEmpty() {
+		t.Error("empty")
+	}
+}
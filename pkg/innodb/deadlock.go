@@ -0,0 +1,191 @@
+package innodb
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const DeadlockSection = "LATEST DETECTED DEADLOCK"
+
+var (
+	ErrNoDeadlock = errors.New("no deadlock detected since server start")
+
+	trxHeaderRe = regexp.MustCompile(`^\*\*\* \((\d+)\) (TRANSACTION|HOLDS THE LOCK\(S\)|WAITING FOR THIS LOCK TO BE GRANTED):`)
+	victimRe    = regexp.MustCompile(`^\*\*\* WE ROLL BACK TRANSACTION \((\d+)\)`)
+	trxRe       = regexp.MustCompile(`^TRANSACTION (\d+), ACTIVE (?:\([A-Z ]+\) )?(\d+) sec\s*(.*)$`)
+	tablesRe    = regexp.MustCompile(`^mysql tables in use (\d+), locked (\d+)`)
+	lockWaitRe  = regexp.MustCompile(`(\d+) lock struct\(s\), heap size \d+(?:, (\d+) row lock\(s\))?`)
+	threadRe    = regexp.MustCompile(`^MySQL thread id (\d+), OS thread handle \w+, query id (\d+)\s*(.*)$`)
+	recordLkRe  = regexp.MustCompile("^RECORD LOCKS space id (\\d+) page no (\\d+) n bits \\d+ index (\\S+) of table (`[^`]+`\\.`[^`]+`) trx id \\d+ lock[_ ]mode (.+?)( waiting)?$")
+	tableLkRe   = regexp.MustCompile("^TABLE LOCK table (`[^`]+`\\.`[^`]+`) trx id \\d+ lock[_ ]mode (.+?)( waiting)?$")
+)
+
+// Deadlock is the LATEST DETECTED DEADLOCK section, Victim is the number of the transaction rolled back
+type Deadlock struct {
+	Time         string                `json:"time"`
+	Transactions []DeadlockTransaction `json:"transactions"`
+	Victim       int                   `json:"victim"`
+}
+
+type DeadlockTransaction struct {
+	Number        int    `json:"number"`
+	ID            uint64 `json:"id"`
+	ActiveSeconds int64  `json:"active_seconds"`
+	State         string `json:"state"`
+	TablesInUse   int    `json:"tables_in_use"`
+	TablesLocked  int    `json:"tables_locked"`
+	LockStructs   int    `json:"lock_structs"`
+	RowLocks      int    `json:"row_locks"`
+	ThreadID      uint64 `json:"thread_id"`
+	QueryID       uint64 `json:"query_id"`
+	// Client is what follows the query id: host, ip, user and thread state
+	Client     string `json:"client"`
+	Query      string `json:"query"`
+	Holds      []Lock `json:"holds"`
+	WaitingFor []Lock `json:"waiting_for"`
+}
+
+// Lock is a record or table lock line, Table is schema.table without quotes
+type Lock struct {
+	Kind    string `json:"kind"`
+	Table   string `json:"table"`
+	Index   string `json:"index,omitempty"`
+	Space   uint64 `json:"space,omitempty"`
+	Page    uint64 `json:"page,omitempty"`
+	Mode    string `json:"mode"`
+	Waiting bool   `json:"waiting"`
+}
+
+// ParseDeadlock parses the LATEST DETECTED DEADLOCK section of SHOW ENGINE INNODB STATUS output
+func ParseDeadlock(status string) (*Deadlock, error) {
+	section, ok := Sections(status)[DeadlockSection]
+	if !ok {
+		return nil, ErrNoDeadlock
+	}
+	return parseDeadlockSection(section), nil
+}
+
+func parseDeadlockSection(section string) *Deadlock {
+	d := &Deadlock{}
+	var trx *DeadlockTransaction
+	// part is the block of the current transaction being read: TRANSACTION, HOLDS THE LOCK(S) or WAITING FOR...
+	part := ""
+	var query []string
+	endQuery := func() {
+		if trx != nil && len(query) > 0 && len(trx.Query) == 0 {
+			trx.Query = strings.TrimSpace(strings.Join(query, "\n"))
+		}
+		query = nil
+	}
+
+	for index, line := range strings.Split(section, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if index == 0 && !strings.HasPrefix(trimmed, "***") {
+			d.Time = timestamp(trimmed)
+			continue
+		}
+
+		if m := trxHeaderRe.FindStringSubmatch(trimmed); m != nil {
+			endQuery()
+			number, _ := strconv.Atoi(m[1])
+			trx = d.transaction(number)
+			part = m[2]
+			continue
+		}
+		if m := victimRe.FindStringSubmatch(trimmed); m != nil {
+			endQuery()
+			d.Victim, _ = strconv.Atoi(m[1])
+			trx = nil
+			continue
+		}
+		if trx == nil {
+			continue
+		}
+
+		switch part {
+		case "TRANSACTION":
+			parseTrxLine(trx, line, &query)
+		case "HOLDS THE LOCK(S)", "WAITING FOR THIS LOCK TO BE GRANTED":
+			lock, ok := parseLock(trimmed)
+			if !ok {
+				continue
+			}
+			if part == "HOLDS THE LOCK(S)" {
+				trx.Holds = append(trx.Holds, lock)
+			} else {
+				trx.WaitingFor = append(trx.WaitingFor, lock)
+			}
+		}
+	}
+	endQuery()
+	return d
+}
+
+// transaction returns the numbered transaction, adding it at first sight
+func (d *Deadlock) transaction(number int) *DeadlockTransaction {
+	for index := range d.Transactions {
+		if d.Transactions[index].Number == number {
+			return &d.Transactions[index]
+		}
+	}
+	d.Transactions = append(d.Transactions, DeadlockTransaction{Number: number})
+	return &d.Transactions[len(d.Transactions)-1]
+}
+
+// parseTrxLine reads the transaction header lines, the lines after MySQL thread id are the statement
+func parseTrxLine(trx *DeadlockTransaction, line string, query *[]string) {
+	trimmed := strings.TrimSpace(line)
+	if trx.ThreadID > 0 || trx.QueryID > 0 {
+		*query = append(*query, line)
+		return
+	}
+	if m := trxRe.FindStringSubmatch(trimmed); m != nil {
+		trx.ID, _ = strconv.ParseUint(m[1], 10, 64)
+		trx.ActiveSeconds, _ = strconv.ParseInt(m[2], 10, 64)
+		trx.State = m[3]
+		return
+	}
+	if m := tablesRe.FindStringSubmatch(trimmed); m != nil {
+		trx.TablesInUse, _ = strconv.Atoi(m[1])
+		trx.TablesLocked, _ = strconv.Atoi(m[2])
+		return
+	}
+	if m := lockWaitRe.FindStringSubmatch(trimmed); m != nil {
+		trx.LockStructs, _ = strconv.Atoi(m[1])
+		trx.RowLocks, _ = strconv.Atoi(m[2])
+		return
+	}
+	if m := threadRe.FindStringSubmatch(trimmed); m != nil {
+		trx.ThreadID, _ = strconv.ParseUint(m[1], 10, 64)
+		trx.QueryID, _ = strconv.ParseUint(m[2], 10, 64)
+		trx.Client = m[3]
+	}
+}
+
+func parseLock(line string) (Lock, bool) {
+	if m := recordLkRe.FindStringSubmatch(line); m != nil {
+		space, _ := strconv.ParseUint(m[1], 10, 64)
+		page, _ := strconv.ParseUint(m[2], 10, 64)
+		return Lock{Kind: "RECORD", Space: space, Page: page, Index: m[3], Table: unquote(m[4]), Mode: m[5], Waiting: len(m[6]) > 0}, true
+	}
+	if m := tableLkRe.FindStringSubmatch(line); m != nil {
+		return Lock{Kind: "TABLE", Table: unquote(m[1]), Mode: m[2], Waiting: len(m[3]) > 0}, true
+	}
+	return Lock{}, false
+}
+
+// timestamp drops the thread id after the date and time of a section header line
+func timestamp(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) >= 2 {
+		return fields[0] + " " + fields[1]
+	}
+	return line
+}
+
+// unquote turns `schema`.`table` into schema.table
+func unquote(table string) string {
+	return strings.ReplaceAll(table, "`", "")
+}
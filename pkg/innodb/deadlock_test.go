@@ -0,0 +1,132 @@
+package innodb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func readStatus(t *testing.T, name string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestParseDeadlock80(t *testing.T) {
+	d, err := ParseDeadlock(readStatus(t, "status_80_deadlock.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Time != "2023-02-01 09:58:41" {
+		t.Errorf("time = %q", d.Time)
+	}
+	if d.Victim != 2 {
+		t.Errorf("victim = %d, want 2", d.Victim)
+	}
+	if len(d.Transactions) != 2 {
+		t.Fatalf("got %d transactions, want 2", len(d.Transactions))
+	}
+
+	first := d.Transactions[0]
+	want := DeadlockTransaction{
+		Number:        1,
+		ID:            1862,
+		ActiveSeconds: 11,
+		State:         "starting index read",
+		TablesInUse:   1,
+		TablesLocked:  1,
+		LockStructs:   3,
+		RowLocks:      2,
+		ThreadID:      9,
+		QueryID:       61,
+		Client:        "localhost root updating",
+		Query:         "update accounts set balance = balance - 10 where id = 2",
+		Holds: []Lock{{
+			Kind: "RECORD", Table: "bank.accounts", Index: "PRIMARY", Space: 3, Page: 4, Mode: "X locks rec but not gap",
+		}},
+		WaitingFor: []Lock{{
+			Kind: "RECORD", Table: "bank.accounts", Index: "PRIMARY", Space: 3, Page: 4, Mode: "X locks rec but not gap", Waiting: true,
+		}},
+	}
+	if !reflect.DeepEqual(first, want) {
+		t.Errorf("transaction 1 =\n%+v\nwant\n%+v", first, want)
+	}
+
+	second := d.Transactions[1]
+	if second.ID != 1863 || second.ThreadID != 10 || second.Client != "10.0.3.17 app updating" {
+		t.Errorf("transaction 2 header = %+v", second)
+	}
+	wantQuery := "update accounts\n   set balance = balance + 10\n where id = 1"
+	if second.Query != wantQuery {
+		t.Errorf("multi line query = %q, want %q", second.Query, wantQuery)
+	}
+	if len(second.Holds) != 1 || len(second.WaitingFor) != 1 || !second.WaitingFor[0].Waiting {
+		t.Errorf("transaction 2 locks = %+v %+v", second.Holds, second.WaitingFor)
+	}
+}
+
+func TestParseDeadlock57(t *testing.T) {
+	d, err := ParseDeadlock(readStatus(t, "status_57_deadlock.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.Time != "2023-02-01 10:11:02" || d.Victim != 1 || len(d.Transactions) != 2 {
+		t.Fatalf("deadlock = %+v", d)
+	}
+
+	// 5.7 doesn't print the locks held by the first transaction
+	first := d.Transactions[0]
+	if len(first.Holds) != 0 || len(first.WaitingFor) != 1 {
+		t.Errorf("transaction 1 locks = %+v %+v", first.Holds, first.WaitingFor)
+	}
+	if first.Query != "INSERT INTO order_items (order_id, sku, qty) VALUES (1001, 'A-1', 2)" {
+		t.Errorf("query without blank line before next block = %q", first.Query)
+	}
+	if mode := first.WaitingFor[0].Mode; mode != "X locks gap before rec insert intention" {
+		t.Errorf("mode = %q", mode)
+	}
+
+	second := d.Transactions[1]
+	if second.ID != 4512878 || second.ActiveSeconds != 1 || second.State != "inserting" {
+		t.Errorf("prepared transaction header = %+v", second)
+	}
+	if second.LockStructs != 4 || second.RowLocks != 3 {
+		t.Errorf("lock counts without LOCK WAIT = %d %d", second.LockStructs, second.RowLocks)
+	}
+	wantHolds := []Lock{
+		{Kind: "TABLE", Table: "orders.order_items", Mode: "IX"},
+		{Kind: "RECORD", Table: "orders.order_items", Index: "idx_order", Space: 231, Page: 18, Mode: "X locks gap before rec"},
+	}
+	if !reflect.DeepEqual(second.Holds, wantHolds) {
+		t.Errorf("holds =\n%+v\nwant\n%+v", second.Holds, wantHolds)
+	}
+}
+
+func TestParseDeadlockNone(t *testing.T) {
+	_, err := ParseDeadlock(readStatus(t, "status_no_deadlock.txt"))
+	if !errors.Is(err, ErrNoDeadlock) {
+		t.Errorf("err = %v, want ErrNoDeadlock", err)
+	}
+}
+
+func TestSections(t *testing.T) {
+	sections := Sections(readStatus(t, "status_80_deadlock.txt"))
+	for _, title := range []string{"BACKGROUND THREAD", "SEMAPHORES", DeadlockSection, "TRANSACTIONS", "FILE I/O"} {
+		if _, ok := sections[title]; !ok {
+			t.Errorf("section %s missing", title)
+		}
+	}
+	if got := sections["BACKGROUND THREAD"]; got != "srv_master_thread loops: 38 srv_active, 0 srv_shutdown, 4200 srv_idle\nsrv_master_thread log flush and writes: 0" {
+		t.Errorf("section body = %q", got)
+	}
+	if got := sections["FILE I/O"]; got != "I/O thread 0 state: waiting for completed aio requests (insert buffer thread)" {
+		t.Errorf("last section body = %q", got)
+	}
+}
@@ -0,0 +1,40 @@
+package innodb
+
+import (
+	"strings"
+)
+
+// Sections splits SHOW ENGINE INNODB STATUS output into its sections by title,
+// a section title is a line framed by two lines of dashes
+func Sections(status string) map[string]string {
+	lines := strings.Split(strings.ReplaceAll(status, "\r\n", "\n"), "\n")
+	sections := make(map[string]string)
+
+	title := ""
+	var body []string
+	flush := func() {
+		if len(title) > 0 {
+			sections[title] = strings.Trim(strings.Join(body, "\n"), "\n")
+		}
+	}
+	for index := 0; index < len(lines); index++ {
+		if index+1 < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[index+1]), "END OF INNODB MONITOR OUTPUT") {
+			break
+		}
+		if index+2 < len(lines) && isDashes(lines[index]) && isDashes(lines[index+2]) && len(strings.TrimSpace(lines[index+1])) > 0 {
+			flush()
+			title = strings.TrimSpace(lines[index+1])
+			body = nil
+			index += 2
+			continue
+		}
+		body = append(body, lines[index])
+	}
+	flush()
+	return sections
+}
+
+func isDashes(line string) bool {
+	line = strings.TrimSpace(line)
+	return len(line) >= 3 && strings.Trim(line, "-") == ""
+}
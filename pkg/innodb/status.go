@@ -0,0 +1,337 @@
+package innodb
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNotStatus = errors.New("not SHOW ENGINE INNODB STATUS output")
+
+	headerRe     = regexp.MustCompile(`(?m)^(\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2})\S* (?:\w+ )?INNODB MONITOR OUTPUT`)
+	averagesRe   = regexp.MustCompile(`(?m)^Per second averages calculated from the last (\d+) seconds`)
+	semWaitRe    = regexp.MustCompile(`(?m)^--Thread \d+ has waited at \S+ line \d+ for ([\d.]+) seconds the semaphore`)
+	trxActiveRe  = regexp.MustCompile(`(?m)^---TRANSACTION \d+, ACTIVE (?:\([A-Z ]+\) )?(\d+) sec`)
+	trxLockWtRe  = regexp.MustCompile(`(?m)^LOCK WAIT `)
+	purgeRe      = regexp.MustCompile(`(?m)^Purge done for trx's n:o < (\d+) undo n:o < (\d+) state: (.*)$`)
+	pendingAioRe = regexp.MustCompile(`(?m)^Pending normal aio reads: ?(\[[\d, ]*\]|\d+)?.*?aio writes: ?(\[[\d, ]*\]|\d+)?`)
+	ioRatesRe    = regexp.MustCompile(`(?m)^([\d.]+) reads/s, (\d+) avg bytes/read, ([\d.]+) writes/s, ([\d.]+) fsyncs/s`)
+	hashRatesRe  = regexp.MustCompile(`(?m)^([\d.]+) hash searches/s, ([\d.]+) non-hash searches/s`)
+	hitRateRe    = regexp.MustCompile(`(?m)^Buffer pool hit rate (\d+) / (\d+)`)
+	rowRatesRe   = regexp.MustCompile(`(?m)^([\d.]+) inserts/s, ([\d.]+) updates/s, ([\d.]+) deletes/s, ([\d.]+) reads/s`)
+	queuedRe     = regexp.MustCompile(`(?m)^(\d+) queries inside InnoDB, (\d+) queries in queue`)
+	readViewsRe  = regexp.MustCompile(`(?m)^(\d+) read views open inside InnoDB`)
+	mainStateRe  = regexp.MustCompile(`(?m)^Process ID=\d+, Main thread ID=\S+(?: , |, )state[=:] ?(.*)$`)
+	ibufOpsRe    = regexp.MustCompile(`(?m)^\s*insert (\d+), delete mark (\d+), delete (\d+)`)
+)
+
+// Status is SHOW ENGINE INNODB STATUS parsed, sections missing from the output are left zero
+type Status struct {
+	Time string `json:"time"`
+	// AveragesSeconds is the interval the per second rates are calculated on
+	AveragesSeconds int64         `json:"averages_seconds"`
+	Semaphores      Semaphores    `json:"semaphores"`
+	Transactions    Transactions  `json:"transactions"`
+	FileIO          FileIO        `json:"file_io"`
+	InsertBuffer    InsertBuffer  `json:"insert_buffer"`
+	Log             Log           `json:"log"`
+	BufferPool      BufferPool    `json:"buffer_pool"`
+	RowOperations   RowOperations `json:"row_operations"`
+	LatestDeadlock  *Deadlock     `json:"latest_deadlock,omitempty"`
+}
+
+type Semaphores struct {
+	ReservationCount uint64 `json:"reservation_count"`
+	SignalCount      uint64 `json:"signal_count"`
+	// Waits are the threads waiting for a semaphore now, LongestWait in seconds, a wait over 600 seconds crashes the server
+	Waits           int     `json:"waits"`
+	LongestWait     float64 `json:"longest_wait"`
+	RWSharedSpins   uint64  `json:"rw_shared_spins"`
+	RWSharedOSWaits uint64  `json:"rw_shared_os_waits"`
+	RWExclSpins     uint64  `json:"rw_excl_spins"`
+	RWExclOSWaits   uint64  `json:"rw_excl_os_waits"`
+	RWSXSpins       uint64  `json:"rw_sx_spins"`
+	RWSXOSWaits     uint64  `json:"rw_sx_os_waits"`
+}
+
+type Transactions struct {
+	TrxIDCounter    uint64 `json:"trx_id_counter"`
+	PurgedUpTo      uint64 `json:"purged_up_to"`
+	PurgeUndoUpTo   uint64 `json:"purge_undo_up_to"`
+	PurgeState      string `json:"purge_state"`
+	HistoryListLen  uint64 `json:"history_list_length"`
+	Active          int    `json:"active"`
+	LockWaits       int    `json:"lock_waits"`
+	LongestActive   int64  `json:"longest_active"`
+	ReadViews       uint64 `json:"read_views"`
+	QueriesInside   uint64 `json:"queries_inside"`
+	QueriesInQueue  uint64 `json:"queries_in_queue"`
+	MainThreadState string `json:"main_thread_state"`
+}
+
+type FileIO struct {
+	PendingReads       uint64  `json:"pending_reads"`
+	PendingWrites      uint64  `json:"pending_writes"`
+	PendingLogFsync    uint64  `json:"pending_log_fsync"`
+	PendingBufferFsync uint64  `json:"pending_buffer_pool_fsync"`
+	Reads              uint64  `json:"reads"`
+	Writes             uint64  `json:"writes"`
+	Fsyncs             uint64  `json:"fsyncs"`
+	ReadsPerSec        float64 `json:"reads_per_sec"`
+	AvgBytesPerRead    uint64  `json:"avg_bytes_per_read"`
+	WritesPerSec       float64 `json:"writes_per_sec"`
+	FsyncsPerSec       float64 `json:"fsyncs_per_sec"`
+}
+
+type InsertBuffer struct {
+	Size                  uint64  `json:"size"`
+	FreeListLen           uint64  `json:"free_list_len"`
+	SegSize               uint64  `json:"seg_size"`
+	Merges                uint64  `json:"merges"`
+	MergedInserts         uint64  `json:"merged_inserts"`
+	MergedDeleteMarks     uint64  `json:"merged_delete_marks"`
+	MergedDeletes         uint64  `json:"merged_deletes"`
+	HashSearchesPerSec    float64 `json:"hash_searches_per_sec"`
+	NonHashSearchesPerSec float64 `json:"non_hash_searches_per_sec"`
+}
+
+// Log has the log sequence numbers, CheckpointAge is how much redo the last checkpoint is behind.
+// Capacity is not in the status output, it's set by SetCapacity
+type Log struct {
+	SequenceNumber   uint64  `json:"sequence_number"`
+	FlushedUpTo      uint64  `json:"flushed_up_to"`
+	PagesFlushedUpTo uint64  `json:"pages_flushed_up_to"`
+	LastCheckpoint   uint64  `json:"last_checkpoint"`
+	CheckpointAge    uint64  `json:"checkpoint_age"`
+	Capacity         uint64  `json:"capacity,omitempty"`
+	CheckpointAgePct float64 `json:"checkpoint_age_pct,omitempty"`
+	IOs              uint64  `json:"ios"`
+}
+
+// BufferPool sizes are in pages, HitRate is per 1000 page gets, 0 without page gets in the interval
+type BufferPool struct {
+	TotalMemory    uint64  `json:"total_memory"`
+	Size           uint64  `json:"size"`
+	FreeBuffers    uint64  `json:"free_buffers"`
+	DatabasePages  uint64  `json:"database_pages"`
+	OldPages       uint64  `json:"old_pages"`
+	ModifiedPages  uint64  `json:"modified_pages"`
+	PendingReads   uint64  `json:"pending_reads"`
+	PagesRead      uint64  `json:"pages_read"`
+	PagesCreated   uint64  `json:"pages_created"`
+	PagesWritten   uint64  `json:"pages_written"`
+	HitRate        float64 `json:"hit_rate"`
+	PagesMadeYoung uint64  `json:"pages_made_young"`
+	PagesNotYoung  uint64  `json:"pages_not_young"`
+	LRULen         uint64  `json:"lru_len"`
+}
+
+type RowOperations struct {
+	Inserted      uint64  `json:"inserted"`
+	Updated       uint64  `json:"updated"`
+	Deleted       uint64  `json:"deleted"`
+	Read          uint64  `json:"read"`
+	InsertsPerSec float64 `json:"inserts_per_sec"`
+	UpdatesPerSec float64 `json:"updates_per_sec"`
+	DeletesPerSec float64 `json:"deletes_per_sec"`
+	ReadsPerSec   float64 `json:"reads_per_sec"`
+}
+
+// ParseStatus parses the whole SHOW ENGINE INNODB STATUS output of MySQL 5.7 or 8.0
+func ParseStatus(status string) (*Status, error) {
+	sections := Sections(status)
+	if len(sections) == 0 {
+		return nil, ErrNotStatus
+	}
+
+	s := &Status{}
+	if m := headerRe.FindStringSubmatch(status); m != nil {
+		s.Time = strings.Replace(m[1], "T", " ", 1)
+	}
+	if m := averagesRe.FindStringSubmatch(status); m != nil {
+		s.AveragesSeconds, _ = strconv.ParseInt(m[1], 10, 64)
+	}
+	s.Semaphores = parseSemaphores(sections["SEMAPHORES"])
+	s.Transactions = parseTransactions(sections["TRANSACTIONS"], sections["ROW OPERATIONS"])
+	s.FileIO = parseFileIO(sections["FILE I/O"])
+	s.InsertBuffer = parseInsertBuffer(sections["INSERT BUFFER AND ADAPTIVE HASH INDEX"])
+	s.Log = parseLog(sections["LOG"])
+	s.BufferPool = parseBufferPool(sections["BUFFER POOL AND MEMORY"])
+	s.RowOperations = parseRowOperations(sections["ROW OPERATIONS"])
+	if section, ok := sections[DeadlockSection]; ok {
+		s.LatestDeadlock = parseDeadlockSection(section)
+	}
+	return s, nil
+}
+
+// SetCapacity sets the redo log capacity in bytes and the checkpoint age percentage of it
+func (l *Log) SetCapacity(capacity uint64) {
+	l.Capacity = capacity
+	if capacity > 0 {
+		l.CheckpointAgePct = float64(l.CheckpointAge) * 100 / float64(capacity)
+	}
+}
+
+func parseSemaphores(section string) Semaphores {
+	s := Semaphores{}
+	s.ReservationCount = number(section, `OS WAIT ARRAY INFO: reservation count (\d+)`)
+	s.SignalCount = number(section, `OS WAIT ARRAY INFO: signal count (\d+)`)
+	s.RWSharedSpins = number(section, `RW-shared spins (\d+)`)
+	s.RWSharedOSWaits = number(section, `RW-shared spins \d+, rounds \d+, OS waits (\d+)`)
+	s.RWExclSpins = number(section, `RW-excl spins (\d+)`)
+	s.RWExclOSWaits = number(section, `RW-excl spins \d+, rounds \d+, OS waits (\d+)`)
+	s.RWSXSpins = number(section, `RW-sx spins (\d+)`)
+	s.RWSXOSWaits = number(section, `RW-sx spins \d+, rounds \d+, OS waits (\d+)`)
+	for _, m := range semWaitRe.FindAllStringSubmatch(section, -1) {
+		s.Waits++
+		if wait, _ := strconv.ParseFloat(m[1], 64); wait > s.LongestWait {
+			s.LongestWait = wait
+		}
+	}
+	return s
+}
+
+func parseTransactions(section, rowOperations string) Transactions {
+	t := Transactions{}
+	t.TrxIDCounter = number(section, `Trx id counter (\d+)`)
+	t.HistoryListLen = number(section, `History list length (\d+)`)
+	if m := purgeRe.FindStringSubmatch(section); m != nil {
+		t.PurgedUpTo, _ = strconv.ParseUint(m[1], 10, 64)
+		t.PurgeUndoUpTo, _ = strconv.ParseUint(m[2], 10, 64)
+		t.PurgeState = strings.TrimSpace(m[3])
+	}
+	for _, m := range trxActiveRe.FindAllStringSubmatch(section, -1) {
+		t.Active++
+		if active, _ := strconv.ParseInt(m[1], 10, 64); active > t.LongestActive {
+			t.LongestActive = active
+		}
+	}
+	t.LockWaits = len(trxLockWtRe.FindAllStringIndex(section, -1))
+
+	if m := queuedRe.FindStringSubmatch(rowOperations); m != nil {
+		t.QueriesInside, _ = strconv.ParseUint(m[1], 10, 64)
+		t.QueriesInQueue, _ = strconv.ParseUint(m[2], 10, 64)
+	}
+	if m := readViewsRe.FindStringSubmatch(rowOperations); m != nil {
+		t.ReadViews, _ = strconv.ParseUint(m[1], 10, 64)
+	}
+	if m := mainStateRe.FindStringSubmatch(rowOperations); m != nil {
+		t.MainThreadState = strings.TrimSpace(m[1])
+	}
+	return t
+}
+
+func parseFileIO(section string) FileIO {
+	f := FileIO{}
+	if m := pendingAioRe.FindStringSubmatch(section); m != nil {
+		f.PendingReads = sum(m[1])
+		f.PendingWrites = sum(m[2])
+	}
+	f.PendingLogFsync = number(section, `Pending flushes \(fsync\) log: (\d+)`)
+	f.PendingBufferFsync = number(section, `Pending flushes \(fsync\) log: \d+; buffer pool: (\d+)`)
+	f.Reads = number(section, `(\d+) OS file reads`)
+	f.Writes = number(section, `(\d+) OS file writes`)
+	f.Fsyncs = number(section, `(\d+) OS fsyncs`)
+	if m := ioRatesRe.FindStringSubmatch(section); m != nil {
+		f.ReadsPerSec, _ = strconv.ParseFloat(m[1], 64)
+		f.AvgBytesPerRead, _ = strconv.ParseUint(m[2], 10, 64)
+		f.WritesPerSec, _ = strconv.ParseFloat(m[3], 64)
+		f.FsyncsPerSec, _ = strconv.ParseFloat(m[4], 64)
+	}
+	return f
+}
+
+func parseInsertBuffer(section string) InsertBuffer {
+	b := InsertBuffer{}
+	b.Size = number(section, `Ibuf: size (\d+)`)
+	b.FreeListLen = number(section, `free list len (\d+)`)
+	b.SegSize = number(section, `seg size (\d+)`)
+	b.Merges = number(section, `seg size \d+, (\d+) merges`)
+	// the first insert/delete mark/delete line is merged operations, the second discarded operations
+	merged := ibufOpsRe.FindStringSubmatch(section)
+	if merged != nil {
+		b.MergedInserts, _ = strconv.ParseUint(merged[1], 10, 64)
+		b.MergedDeleteMarks, _ = strconv.ParseUint(merged[2], 10, 64)
+		b.MergedDeletes, _ = strconv.ParseUint(merged[3], 10, 64)
+	}
+	if m := hashRatesRe.FindStringSubmatch(section); m != nil {
+		b.HashSearchesPerSec, _ = strconv.ParseFloat(m[1], 64)
+		b.NonHashSearchesPerSec, _ = strconv.ParseFloat(m[2], 64)
+	}
+	return b
+}
+
+func parseLog(section string) Log {
+	l := Log{}
+	l.SequenceNumber = number(section, `Log sequence number\s+(\d+)`)
+	l.FlushedUpTo = number(section, `Log flushed up to\s+(\d+)`)
+	l.PagesFlushedUpTo = number(section, `Pages flushed up to\s+(\d+)`)
+	l.LastCheckpoint = number(section, `Last checkpoint at\s+(\d+)`)
+	l.IOs = number(section, `(\d+) log i/o's done`)
+	if l.SequenceNumber > l.LastCheckpoint {
+		l.CheckpointAge = l.SequenceNumber - l.LastCheckpoint
+	}
+	return l
+}
+
+func parseBufferPool(section string) BufferPool {
+	p := BufferPool{}
+	p.TotalMemory = number(section, `Total (?:large )?memory allocated (\d+)`)
+	p.Size = number(section, `Buffer pool size\s+(\d+)`)
+	p.FreeBuffers = number(section, `Free buffers\s+(\d+)`)
+	p.DatabasePages = number(section, `Database pages\s+(\d+)`)
+	p.OldPages = number(section, `Old database pages\s+(\d+)`)
+	p.ModifiedPages = number(section, `Modified db pages\s+(\d+)`)
+	p.PendingReads = number(section, `Pending reads\s+(\d+)`)
+	p.PagesMadeYoung = number(section, `Pages made young (\d+)`)
+	p.PagesNotYoung = number(section, `Pages made young \d+, not young (\d+)`)
+	p.PagesRead = number(section, `Pages read (\d+)`)
+	p.PagesCreated = number(section, `Pages read \d+, created (\d+)`)
+	p.PagesWritten = number(section, `Pages read \d+, created \d+, written (\d+)`)
+	p.LRULen = number(section, `LRU len: (\d+)`)
+	if m := hitRateRe.FindStringSubmatch(section); m != nil {
+		hits, _ := strconv.ParseFloat(m[1], 64)
+		gets, _ := strconv.ParseFloat(m[2], 64)
+		if gets > 0 {
+			p.HitRate = hits * 1000 / gets
+		}
+	}
+	return p
+}
+
+func parseRowOperations(section string) RowOperations {
+	r := RowOperations{}
+	r.Inserted = number(section, `Number of rows inserted (\d+)`)
+	r.Updated = number(section, `Number of rows inserted \d+, updated (\d+)`)
+	r.Deleted = number(section, `Number of rows inserted \d+, updated \d+, deleted (\d+)`)
+	r.Read = number(section, `Number of rows inserted \d+, updated \d+, deleted \d+, read (\d+)`)
+	if m := rowRatesRe.FindStringSubmatch(section); m != nil {
+		r.InsertsPerSec, _ = strconv.ParseFloat(m[1], 64)
+		r.UpdatesPerSec, _ = strconv.ParseFloat(m[2], 64)
+		r.DeletesPerSec, _ = strconv.ParseFloat(m[3], 64)
+		r.ReadsPerSec, _ = strconv.ParseFloat(m[4], 64)
+	}
+	return r
+}
+
+// number returns the first group of the first match of pattern, 0 without match
+func number(text, pattern string) uint64 {
+	m := regexp.MustCompile(pattern).FindStringSubmatch(text)
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.ParseUint(m[1], 10, 64)
+	return n
+}
+
+// sum adds the pending counters of the aio threads, printed as [0, 0, 0, 0] or a single number
+func sum(list string) uint64 {
+	var total uint64
+	for _, field := range strings.FieldsFunc(list, func(r rune) bool { return r == '[' || r == ']' || r == ',' || r == ' ' }) {
+		n, _ := strconv.ParseUint(field, 10, 64)
+		total += n
+	}
+	return total
+}
@@ -0,0 +1,130 @@
+package innodb
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseStatus80(t *testing.T) {
+	s, err := ParseStatus(readStatus(t, "status_80_full.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Time != "2023-02-02 14:20:11" || s.AveragesSeconds != 17 {
+		t.Errorf("header = %q %d", s.Time, s.AveragesSeconds)
+	}
+
+	wantSem := Semaphores{
+		ReservationCount: 48213, SignalCount: 46102, Waits: 2, LongestWait: 12,
+		RWSharedSpins: 2311, RWSharedOSWaits: 1980, RWExclSpins: 812, RWExclOSWaits: 301, RWSXSpins: 44, RWSXOSWaits: 19,
+	}
+	if s.Semaphores != wantSem {
+		t.Errorf("semaphores =\n%+v\nwant\n%+v", s.Semaphores, wantSem)
+	}
+
+	wantTrx := Transactions{
+		TrxIDCounter: 5829112, PurgedUpTo: 5711020, PurgeState: "running", HistoryListLen: 118234,
+		Active: 2, LockWaits: 1, LongestActive: 3604, ReadViews: 5, QueriesInside: 2, QueriesInQueue: 1, MainThreadState: "sleeping",
+	}
+	if s.Transactions != wantTrx {
+		t.Errorf("transactions =\n%+v\nwant\n%+v", s.Transactions, wantTrx)
+	}
+
+	wantIO := FileIO{
+		PendingReads: 3, PendingWrites: 4, PendingLogFsync: 1, PendingBufferFsync: 3, Reads: 90211, Writes: 1203991, Fsyncs: 288102,
+		ReadsPerSec: 12.41, AvgBytesPerRead: 16384, WritesPerSec: 402.18, FsyncsPerSec: 88.06,
+	}
+	if s.FileIO != wantIO {
+		t.Errorf("file io =\n%+v\nwant\n%+v", s.FileIO, wantIO)
+	}
+
+	wantIbuf := InsertBuffer{
+		Size: 12, FreeListLen: 310, SegSize: 323, Merges: 907, MergedInserts: 1502, MergedDeleteMarks: 220, MergedDeletes: 17,
+		HashSearchesPerSec: 8102.52, NonHashSearchesPerSec: 2210.90,
+	}
+	if s.InsertBuffer != wantIbuf {
+		t.Errorf("insert buffer =\n%+v\nwant\n%+v", s.InsertBuffer, wantIbuf)
+	}
+
+	wantLog := Log{
+		SequenceNumber: 90832211932, FlushedUpTo: 90832210110, PagesFlushedUpTo: 89911203211, LastCheckpoint: 89758968732,
+		CheckpointAge: 1073243200, IOs: 1102211,
+	}
+	if s.Log != wantLog {
+		t.Errorf("log =\n%+v\nwant\n%+v", s.Log, wantLog)
+	}
+
+	wantPool := BufferPool{
+		TotalMemory: 8795455488, Size: 524288, FreeBuffers: 8192, DatabasePages: 510211, OldPages: 188310, ModifiedPages: 44102,
+		PendingReads: 3, PagesRead: 4410221, PagesCreated: 220117, PagesWritten: 9921034, HitRate: 997,
+		PagesMadeYoung: 2203311, PagesNotYoung: 99120211, LRULen: 510211,
+	}
+	if s.BufferPool != wantPool {
+		t.Errorf("buffer pool =\n%+v\nwant\n%+v", s.BufferPool, wantPool)
+	}
+
+	// the user rows line comes before the system rows line
+	wantRows := RowOperations{
+		Inserted: 20331021, Updated: 8802211, Deleted: 102213, Read: 9912203311,
+		InsertsPerSec: 41.20, UpdatesPerSec: 20.11, DeletesPerSec: 0.35, ReadsPerSec: 102211.90,
+	}
+	if s.RowOperations != wantRows {
+		t.Errorf("row operations =\n%+v\nwant\n%+v", s.RowOperations, wantRows)
+	}
+
+	if s.LatestDeadlock != nil {
+		t.Errorf("latest deadlock = %+v, want none", s.LatestDeadlock)
+	}
+}
+
+func TestParseStatus57(t *testing.T) {
+	s, err := ParseStatus(readStatus(t, "status_57_full.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Transactions.HistoryListLen != 42 || s.Transactions.PurgeState != "running but idle" || s.Transactions.MainThreadState != "sleeping" {
+		t.Errorf("transactions = %+v", s.Transactions)
+	}
+	if s.Transactions.Active != 1 || s.Transactions.LockWaits != 0 {
+		t.Errorf("active %d lock waits %d", s.Transactions.Active, s.Transactions.LockWaits)
+	}
+	if s.FileIO.PendingReads != 0 || s.FileIO.PendingWrites != 0 || s.FileIO.Writes != 402211 {
+		t.Errorf("file io = %+v", s.FileIO)
+	}
+	if s.Log.SequenceNumber != 2560183443 || s.Log.LastCheckpoint != 2560002211 || s.Log.CheckpointAge != 181232 {
+		t.Errorf("log = %+v", s.Log)
+	}
+	if s.BufferPool.HitRate != 0 || s.BufferPool.Size != 131056 {
+		t.Errorf("buffer pool without page gets = %+v", s.BufferPool)
+	}
+	if s.Semaphores.Waits != 0 || s.Semaphores.RWSXOSWaits != 81 {
+		t.Errorf("semaphores = %+v", s.Semaphores)
+	}
+}
+
+func TestParseStatusDeadlock(t *testing.T) {
+	s, err := ParseStatus(readStatus(t, "status_80_deadlock.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.LatestDeadlock == nil || s.LatestDeadlock.Victim != 2 {
+		t.Errorf("latest deadlock = %+v", s.LatestDeadlock)
+	}
+}
+
+func TestParseStatusInvalid(t *testing.T) {
+	_, err := ParseStatus("ERROR 1227 (42000): Access denied; you need the PROCESS privilege")
+	if !errors.Is(err, ErrNotStatus) {
+		t.Errorf("err = %v, want ErrNotStatus", err)
+	}
+}
+
+func TestSetCapacity(t *testing.T) {
+	l := Log{CheckpointAge: 1 << 29}
+	l.SetCapacity(2 << 30)
+	if l.CheckpointAgePct != 25 {
+		t.Errorf("checkpoint age pct = %v, want 25", l.CheckpointAgePct)
+	}
+}
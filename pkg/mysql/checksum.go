@@ -0,0 +1,197 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"rdsdba/pkg/engine"
+)
+
+// ChecksumTable is a table with the columns the checksum reads, Key is its primary key, empty without one
+type ChecksumTable struct {
+	engine.Table
+	Key     []string
+	Columns []string
+	// keyTypes are the column_type of Key, bounds are read back with them
+	keyTypes []string
+}
+
+// Chunk is the primary key range above Lower up to Upper included, a nil bound is open.
+// Bounds are int64, uint64 or string like the key columns.
+type Chunk struct {
+	Index int           `json:"index"`
+	Lower []interface{} `json:"lower"`
+	Upper []interface{} `json:"upper"`
+}
+
+// chunkKeyTypes are the primary key data types NextChunkBound reads back exactly, floating point and temporal
+// values change on the way through text and binary strings aren't valid text
+var chunkKeyTypes = map[string]bool{
+	"tinyint": true, "smallint": true, "mediumint": true, "int": true, "bigint": true,
+	"char": true, "varchar": true,
+}
+
+// ChunkChecksum is the row count and the xor of the row CRC32s of a chunk
+type ChunkChecksum struct {
+	Rows int64  `json:"rows"`
+	CRC  uint64 `json:"crc"`
+}
+
+// ChecksumTable reads the primary key and the columns of t
+func (i *Instance) ChecksumTable(ctx context.Context, t engine.Table) (ChecksumTable, error) {
+	c := ChecksumTable{Table: t}
+	var keyTypes, dataTypes []string
+	stmts := []struct {
+		columns []*[]string
+		stmt    string
+	}{
+		{[]*[]string{&c.Key, &dataTypes, &keyTypes}, `select s.column_name, c.data_type, c.column_type from information_schema.statistics s
+		join information_schema.columns c on c.table_schema = s.table_schema and c.table_name = s.table_name and c.column_name = s.column_name
+		where s.table_schema = ? and s.table_name = ? and s.index_name = 'PRIMARY' order by s.seq_in_index`},
+		{[]*[]string{&c.Columns}, `select column_name from information_schema.columns
+		where table_schema = ? and table_name = ? order by ordinal_position`},
+	}
+	for _, s := range stmts {
+		err := i.retry(ctx, func() error {
+			rows, err := i.DB.QueryContext(ctx, s.stmt, t.SchemaName, t.TableName)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+			values := make([]string, len(s.columns))
+			dest := make([]interface{}, len(values))
+			for index, columns := range s.columns {
+				*columns = (*columns)[:0]
+				dest[index] = &values[index]
+			}
+			for rows.Next() {
+				if err = rows.Scan(dest...); err != nil {
+					return err
+				}
+				for index, columns := range s.columns {
+					*columns = append(*columns, values[index])
+				}
+			}
+			return rows.Err()
+		})
+		if err != nil {
+			return c, err
+		}
+	}
+	if len(c.Columns) == 0 {
+		return c, fmt.Errorf("table %s not found", t)
+	}
+	for index, dataType := range dataTypes {
+		if !chunkKeyTypes[strings.ToLower(dataType)] {
+			return c, fmt.Errorf("primary key column %s is %s, only integer, char and varchar keys can be chunked", c.Key[index], keyTypes[index])
+		}
+	}
+	c.keyTypes = keyTypes
+	return c, nil
+}
+
+// NextChunkBound is the primary key of the size-th row above lower, nil when fewer rows are left
+func (i *Instance) NextChunkBound(ctx context.Context, t ChecksumTable, lower []interface{}, size int) ([]interface{}, error) {
+	key := quoteColumns(t.Key)
+	where, args := chunkWhere(t.Key, Chunk{Lower: lower})
+	stmt := fmt.Sprintf("select %s from %s force index(`PRIMARY`) where %s order by %s limit 1 offset %d",
+		strings.Join(key, ", "), quoteTable(t.Table), where, strings.Join(key, ", "), size-1)
+
+	bound := make([]sql.RawBytes, len(t.Key))
+	dest := make([]interface{}, len(bound))
+	for index := range bound {
+		dest[index] = &bound[index]
+	}
+	var upper []interface{}
+	err := i.retry(ctx, func() error {
+		rows, err := i.DB.QueryContext(ctx, stmt, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		upper = nil
+		if rows.Next() {
+			if err = rows.Scan(dest...); err != nil {
+				return err
+			}
+			for index, value := range bound {
+				key, err := keyValue(string(value), t.keyTypes[index])
+				if err != nil {
+					return fmt.Errorf("primary key column %s: %w", t.Key[index], err)
+				}
+				upper = append(upper, key)
+			}
+		}
+		return rows.Err()
+	})
+	return upper, err
+}
+
+// keyValue converts a key read as text back to the type of its column, integers compared as strings
+// would be converted to double by the server and lose precision past 2^53
+func keyValue(value string, columnType string) (interface{}, error) {
+	columnType = strings.ToLower(columnType)
+	if !strings.Contains(columnType, "int") {
+		return value, nil
+	}
+	if strings.Contains(columnType, "unsigned") {
+		return strconv.ParseUint(value, 10, 64)
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// ChecksumChunk counts the rows of the chunk and xors the CRC32 of each row like pt-table-checksum,
+// NULL and empty string differ through the ISNULL flags
+func (i *Instance) ChecksumChunk(ctx context.Context, t ChecksumTable, c Chunk) (ChunkChecksum, error) {
+	columns := quoteColumns(t.Columns)
+	nulls := make([]string, len(columns))
+	for index, column := range columns {
+		nulls[index] = "isnull(" + column + ")"
+	}
+	row := fmt.Sprintf("concat_ws('#', %s, concat(%s))", strings.Join(columns, ", "), strings.Join(nulls, ", "))
+	where, args := chunkWhere(t.Key, c)
+	index := ""
+	if len(t.Key) > 0 {
+		index = " force index(`PRIMARY`)"
+	}
+	stmt := fmt.Sprintf("select count(*), coalesce(bit_xor(crc32(%s)), 0) from %s%s where %s", row, quoteTable(t.Table), index, where)
+
+	var sum ChunkChecksum
+	err := i.retry(ctx, func() error {
+		return i.DB.QueryRowContext(ctx, stmt, args...).Scan(&sum.Rows, &sum.CRC)
+	})
+	return sum, err
+}
+
+// chunkWhere compares the key as row constructor, (a, b) > (?, ?), which MySQL reads as a range
+func chunkWhere(key []string, c Chunk) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	columns := "(" + strings.Join(quoteColumns(key), ", ") + ")"
+	marks := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(key)), ", ") + ")"
+	for _, bound := range []struct {
+		values []interface{}
+		op     string
+	}{{c.Lower, ">"}, {c.Upper, "<="}} {
+		if bound.values == nil {
+			continue
+		}
+		conditions = append(conditions, columns+" "+bound.op+" "+marks)
+		args = append(args, bound.values...)
+	}
+	if len(conditions) == 0 {
+		return "1 = 1", nil
+	}
+	return strings.Join(conditions, " and "), args
+}
+
+func quoteColumns(columns []string) []string {
+	quoted := make([]string, len(columns))
+	for index, column := range columns {
+		quoted[index] = quoteName(column)
+	}
+	return quoted
+}
@@ -0,0 +1,50 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestKeyValue(t *testing.T) {
+	tests := []struct {
+		value      string
+		columnType string
+		want       interface{}
+	}{
+		{"42", "int", int64(42)},
+		{"-7", "bigint(20)", int64(-7)},
+		{"18446744073709551615", "bigint unsigned", uint64(18446744073709551615)},
+		{"9007199254740993", "BIGINT(20) UNSIGNED", uint64(9007199254740993)},
+		{"007", "varchar(16)", "007"},
+		{"a b ", "char(4)", "a b "},
+	}
+	for _, test := range tests {
+		got, err := keyValue(test.value, test.columnType)
+		if err != nil || got != test.want {
+			t.Errorf("keyValue(%q, %q) = %#v, %v, want %#v", test.value, test.columnType, got, err, test.want)
+		}
+	}
+	if _, err := keyValue("abc", "int"); err == nil {
+		t.Error("keyValue of a non number int: no error")
+	}
+}
+
+func TestChunkWhere(t *testing.T) {
+	key := []string{"tenant", "id"}
+	tests := []struct {
+		chunk    Chunk
+		want     string
+		wantArgs []interface{}
+	}{
+		{Chunk{}, "1 = 1", nil},
+		{Chunk{Upper: []interface{}{"acme", uint64(10)}}, "(`tenant`, `id`) <= (?, ?)", []interface{}{"acme", uint64(10)}},
+		{Chunk{Lower: []interface{}{"acme", uint64(10)}, Upper: []interface{}{"zeta", uint64(3)}},
+			"(`tenant`, `id`) > (?, ?) and (`tenant`, `id`) <= (?, ?)", []interface{}{"acme", uint64(10), "zeta", uint64(3)}},
+	}
+	for _, test := range tests {
+		where, args := chunkWhere(key, test.chunk)
+		if where != test.want || !reflect.DeepEqual(args, test.wantArgs) {
+			t.Errorf("chunkWhere(%v) = %q %#v, want %q %#v", test.chunk, where, args, test.want, test.wantArgs)
+		}
+	}
+}
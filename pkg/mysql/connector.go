@@ -0,0 +1,204 @@
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	gomysql "github.com/go-sql-driver/mysql"
+)
+
+const errAccessDenied = 1045
+
+// connector builds the driver config for every new connection, so the password can change during a run
+type connector struct {
+	instance *Instance
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.connect(ctx)
+	if isAccessDenied(err) && c.instance.Config.Password != nil && !c.instance.Config.IAM.Enabled {
+		// password may have been rotated, reload it once
+		c.instance.logger.Warn().Msg("access denied, reloading password")
+		c.instance.Config.Password.Invalidate()
+		conn, err = c.connect(ctx)
+	}
+	return conn, err
+}
+
+func (c *connector) connect(ctx context.Context) (driver.Conn, error) {
+	dsn, err := c.instance.driverConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mysqlConnector, err := gomysql.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	generation := c.instance.generation.Load()
+	dc, err := mysqlConnector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: dc, instance: c.instance, generation: generation}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return gomysql.MySQLDriver{}
+}
+
+// conn is a driver connection of one pool generation, database/sql drops it instead of reusing it after ResetPool
+type conn struct {
+	driver.Conn
+	instance   *Instance
+	generation uint64
+}
+
+func (c *conn) stale() bool {
+	return c.generation != c.instance.generation.Load()
+}
+
+func (c *conn) IsValid() bool {
+	if c.stale() {
+		return false
+	}
+	v, ok := c.Conn.(driver.Validator)
+	return !ok || v.IsValid()
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if c.stale() {
+		return driver.ErrBadConn
+	}
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if q, ok := c.Conn.(driver.QueryerContext); ok {
+		return q.QueryContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if e, ok := c.Conn.(driver.ExecerContext); ok {
+		return e.ExecContext(ctx, query, args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// driverConfig returns the go-sql-driver config, the password is only ever set here
+func (i *Instance) driverConfig(ctx context.Context) (*gomysql.Config, error) {
+	cfg := i.Config
+	base := cfg.RawDSN
+	if len(base) == 0 {
+		network, address := "tcp", net.JoinHostPort(cfg.DSN.Host, strconv.Itoa(cfg.DSN.Port))
+		if len(cfg.Socket) > 0 {
+			network, address = "unix", cfg.Socket
+		}
+		base = fmt.Sprintf(ConnectionFmt, cfg.DSN.User, network, address, connTimeout)
+	}
+	dsn, err := gomysql.ParseDSN(withParams(base, cfg.Params, cfg.SessionVars))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dsn.DBName) == 0 {
+		dsn.DBName = cfg.DSN.Database
+	}
+	if len(i.sshNet) > 0 && dsn.Net == "tcp" {
+		dsn.Net = i.sshNet
+	}
+	if len(dsn.TLSConfig) == 0 && dsn.TLS == nil {
+		dsn.TLSConfig = i.tlsName
+		mode := NormalizeSSLMode(cfg.TLS.Mode)
+		dsn.AllowFallbackToPlaintext = len(mode) == 0 || mode == SSLPreferred
+	}
+
+	if cfg.IAM.Enabled {
+		dsn.AllowCleartextPasswords = true
+		dsn.AllowFallbackToPlaintext = false
+		dsn.Passwd, err = i.iamToken(ctx)
+		return dsn, err
+	}
+
+	// password in raw DSN wins
+	if len(dsn.Passwd) > 0 {
+		return dsn, nil
+	}
+	dsn.Passwd = cfg.DSN.Passwd
+	if cfg.Password != nil {
+		dsn.Passwd, err = cfg.Password.Password(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return dsn, nil
+}
+
+// withParams appends driver params and session variables, the driver runs SET name=value for unknown params
+func withParams(dsn string, params ...map[string]string) string {
+	var b strings.Builder
+	b.WriteString(dsn)
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	for _, m := range params {
+		for name, value := range m {
+			b.WriteString(sep + name + "=" + url.QueryEscape(value))
+			sep = "&"
+		}
+	}
+	return b.String()
+}
+
+// redactDSN formats the DSN for logging with the password masked
+func redactDSN(dsn *gomysql.Config) string {
+	redacted := dsn.Clone()
+	if len(redacted.Passwd) > 0 {
+		redacted.Passwd = "xxxxx"
+	}
+	return redacted.FormatDSN()
+}
+
+func isAccessDenied(err error) bool {
+	var mysqlErr *gomysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == errAccessDenied
+}
@@ -19,7 +19,18 @@ type QueryResponseInfo struct {
 	ExecutionTime int64
 }
 
+// Query returns at most MaxRowsSize rows
 func Query(ctx context.Context, db *sql.DB, sqlStmt string) (QueryResponseInfo, []string, []map[string]interface{}, error) {
+	return query(ctx, db, sqlStmt, MaxRowsSize)
+}
+
+// QueryAll is Query without limit on the number of rows
+func QueryAll(ctx context.Context, db *sql.DB, sqlStmt string) (QueryResponseInfo, []string, []map[string]interface{}, error) {
+	return query(ctx, db, sqlStmt, 0)
+}
+
+// query returns at most maxRows rows, 0 means no limit
+func query(ctx context.Context, db *sql.DB, sqlStmt string, maxRows int) (QueryResponseInfo, []string, []map[string]interface{}, error) {
 	var cols []string
 	var tableData []map[string]interface{}
 	var result QueryResponseInfo
@@ -59,7 +70,7 @@ func Query(ctx context.Context, db *sql.DB, sqlStmt string) (QueryResponseInfo,
 
 	currentRow := 1
 	for rows.Next() {
-		if currentRow > MaxRowsSize {
+		if maxRows > 0 && currentRow > maxRows {
 			break
 		}
 		_ = rows.Scan(valuesPtr...)
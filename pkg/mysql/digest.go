@@ -0,0 +1,130 @@
+package mysql
+
+import (
+	"context"
+)
+
+// picoseconds per second, performance_schema timers are in picoseconds
+const picoseconds = 1e12
+
+const digestStmt = `select coalesce(schema_name, ''), coalesce(digest, ''), coalesce(digest_text, ''), count_star,
+	sum_timer_wait, max_timer_wait, sum_lock_time, sum_errors, sum_warnings, sum_rows_affected, sum_rows_sent, sum_rows_examined,
+	sum_created_tmp_tables, sum_created_tmp_disk_tables, sum_sort_merge_passes, sum_no_index_used, sum_no_good_index_used,
+	cast(first_seen as char), cast(last_seen as char)
+from performance_schema.events_statements_summary_by_digest`
+
+// Digest is a row of events_statements_summary_by_digest, latencies are seconds, counters are sums since the
+// last truncate or server start unless produced by DigestDelta
+type Digest struct {
+	Schema          string  `json:"schema"`
+	Digest          string  `json:"digest"`
+	Text            string  `json:"text"`
+	Count           uint64  `json:"count"`
+	TotalLatency    float64 `json:"total_latency"`
+	MaxLatency      float64 `json:"max_latency"`
+	LockTime        float64 `json:"lock_time"`
+	Errors          uint64  `json:"errors"`
+	Warnings        uint64  `json:"warnings"`
+	RowsAffected    uint64  `json:"rows_affected"`
+	RowsSent        uint64  `json:"rows_sent"`
+	RowsExamined    uint64  `json:"rows_examined"`
+	TmpTables       uint64  `json:"tmp_tables"`
+	TmpDiskTables   uint64  `json:"tmp_disk_tables"`
+	SortMergePasses uint64  `json:"sort_merge_passes"`
+	NoIndexUsed     uint64  `json:"no_index_used"`
+	NoGoodIndexUsed uint64  `json:"no_good_index_used"`
+	FirstSeen       string  `json:"first_seen"`
+	LastSeen        string  `json:"last_seen"`
+}
+
+func (d Digest) key() string {
+	return d.Schema + "/" + d.Digest
+}
+
+// AvgLatency is the average latency in seconds
+func (d Digest) AvgLatency() float64 {
+	if d.Count == 0 {
+		return 0
+	}
+	return d.TotalLatency / float64(d.Count)
+}
+
+// ExaminedPerSent is rows examined per row sent, rows examined when nothing was sent
+func (d Digest) ExaminedPerSent() float64 {
+	if d.RowsSent == 0 {
+		return float64(d.RowsExamined)
+	}
+	return float64(d.RowsExamined) / float64(d.RowsSent)
+}
+
+// Digests reads events_statements_summary_by_digest, it needs performance_schema on
+func (i *Instance) Digests(ctx context.Context) ([]Digest, error) {
+	var digests []Digest
+	err := i.retry(ctx, func() error {
+		rows, err := i.DB.QueryContext(ctx, digestStmt)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		digests = digests[:0]
+		for rows.Next() {
+			var d Digest
+			var total, maxWait, lock uint64
+			err = rows.Scan(&d.Schema, &d.Digest, &d.Text, &d.Count, &total, &maxWait, &lock, &d.Errors, &d.Warnings,
+				&d.RowsAffected, &d.RowsSent, &d.RowsExamined, &d.TmpTables, &d.TmpDiskTables, &d.SortMergePasses,
+				&d.NoIndexUsed, &d.NoGoodIndexUsed, &d.FirstSeen, &d.LastSeen)
+			if err != nil {
+				return err
+			}
+			d.TotalLatency = float64(total) / picoseconds
+			d.MaxLatency = float64(maxWait) / picoseconds
+			d.LockTime = float64(lock) / picoseconds
+			digests = append(digests, d)
+		}
+		return rows.Err()
+	})
+	return digests, err
+}
+
+// DigestDelta returns what the digests of after did since before, digests without executions in between are left out.
+// A digest absent from before, or with any counter lower because the table was truncated or the digest evicted
+// and created again, is taken whole. MaxLatency is the max since the digest was first seen, it can't be diffed.
+func DigestDelta(before, after []Digest) []Digest {
+	previous := make(map[string]Digest, len(before))
+	for _, d := range before {
+		previous[d.key()] = d
+	}
+
+	var delta []Digest
+	for _, d := range after {
+		if p, ok := previous[d.key()]; ok && !d.resetSince(p) {
+			d.Count -= p.Count
+			d.TotalLatency -= p.TotalLatency
+			d.LockTime -= p.LockTime
+			d.Errors -= p.Errors
+			d.Warnings -= p.Warnings
+			d.RowsAffected -= p.RowsAffected
+			d.RowsSent -= p.RowsSent
+			d.RowsExamined -= p.RowsExamined
+			d.TmpTables -= p.TmpTables
+			d.TmpDiskTables -= p.TmpDiskTables
+			d.SortMergePasses -= p.SortMergePasses
+			d.NoIndexUsed -= p.NoIndexUsed
+			d.NoGoodIndexUsed -= p.NoGoodIndexUsed
+		}
+		if d.Count > 0 {
+			delta = append(delta, d)
+		}
+	}
+	return delta
+}
+
+// resetSince reports whether a counter of d is lower than in p, the counters only grow while the row lives
+func (d Digest) resetSince(p Digest) bool {
+	return d.Count < p.Count || d.TotalLatency < p.TotalLatency || d.LockTime < p.LockTime ||
+		d.Errors < p.Errors || d.Warnings < p.Warnings || d.RowsAffected < p.RowsAffected ||
+		d.RowsSent < p.RowsSent || d.RowsExamined < p.RowsExamined || d.TmpTables < p.TmpTables ||
+		d.TmpDiskTables < p.TmpDiskTables || d.SortMergePasses < p.SortMergePasses ||
+		d.NoIndexUsed < p.NoIndexUsed || d.NoGoodIndexUsed < p.NoGoodIndexUsed
+}
@@ -0,0 +1,74 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDigestDelta(t *testing.T) {
+	before := []Digest{
+		{Schema: "orders", Digest: "unchanged", Count: 5, TotalLatency: 1, LockTime: 0.1, RowsExamined: 50},
+		{Schema: "orders", Digest: "grown", Count: 10, TotalLatency: 2, MaxLatency: 0.5, LockTime: 0.2, Errors: 1, RowsSent: 10, RowsExamined: 100},
+		{Schema: "orders", Digest: "reset", Count: 100, TotalLatency: 30, LockTime: 3, RowsExamined: 10000},
+		{Schema: "orders", Digest: "recreated", Count: 4, TotalLatency: 1.5, LockTime: 0.3, RowsExamined: 40},
+		{Schema: "orders", Digest: "gone", Count: 7, TotalLatency: 1},
+		{Schema: "orders", Digest: "faster", Count: 8, TotalLatency: 4, LockTime: 0.1},
+	}
+	after := []Digest{
+		{Schema: "orders", Digest: "unchanged", Count: 5, TotalLatency: 1, LockTime: 0.1, RowsExamined: 50},
+		{Schema: "orders", Digest: "grown", Count: 14, TotalLatency: 3.5, MaxLatency: 0.9, LockTime: 0.25, Errors: 1, RowsSent: 14, RowsExamined: 180},
+		{Schema: "orders", Digest: "reset", Count: 3, TotalLatency: 0.6, LockTime: 0.01, RowsExamined: 30},
+		{Schema: "orders", Digest: "new", Count: 2, TotalLatency: 0.4, LockTime: 0.02, RowsSent: 2, RowsExamined: 2},
+		{Schema: "reporting", Digest: "grown", Count: 1, TotalLatency: 5, RowsExamined: 1000},
+		// evicted and created again, it ran more often since than before but examined fewer rows
+		{Schema: "orders", Digest: "recreated", Count: 5, TotalLatency: 1.6, LockTime: 0.4, RowsExamined: 30},
+		// sum_timer_wait only grows, a lower latency is a new row too
+		{Schema: "orders", Digest: "faster", Count: 9, TotalLatency: 0.9, LockTime: 0.1},
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		want *Digest
+	}{
+		{"unchanged", "orders/unchanged", nil},
+		{"grown", "orders/grown", &Digest{Schema: "orders", Digest: "grown", Count: 4, TotalLatency: 1.5, MaxLatency: 0.9, LockTime: 0.05, RowsSent: 4, RowsExamined: 80}},
+		{"reset", "orders/reset", &after[2]},
+		{"new", "orders/new", &after[3]},
+		{"same digest other schema", "reporting/grown", &after[4]},
+		{"reset with higher count", "orders/recreated", &after[5]},
+		{"reset with lower latency", "orders/faster", &after[6]},
+		{"gone", "orders/gone", nil},
+	}
+
+	delta := make(map[string]Digest)
+	for _, d := range DigestDelta(before, after) {
+		delta[d.key()] = d
+	}
+	for _, test := range tests {
+		got, ok := delta[test.key]
+		if test.want == nil {
+			if ok {
+				t.Errorf("%s: %+v in delta, want left out", test.name, got)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("%s: missing from delta", test.name)
+			continue
+		}
+		// float subtraction isn't exact
+		for _, f := range []struct{ got, want *float64 }{{&got.TotalLatency, &test.want.TotalLatency}, {&got.LockTime, &test.want.LockTime}} {
+			if *f.got < 0 || *f.got-*f.want > 1e-9 || *f.want-*f.got > 1e-9 {
+				t.Errorf("%s: latency %v, want %v", test.name, *f.got, *f.want)
+			}
+			*f.got = *f.want
+		}
+		if !reflect.DeepEqual(got, *test.want) {
+			t.Errorf("%s: delta =\n%+v\nwant\n%+v", test.name, got, *test.want)
+		}
+	}
+	if len(delta) != 6 {
+		t.Errorf("delta has %d digests, want 6", len(delta))
+	}
+}
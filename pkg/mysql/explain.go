@@ -0,0 +1,161 @@
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PlanTable is a table access of EXPLAIN FORMAT=JSON, Rows is rows_examined_per_scan and Filtered a percentage
+type PlanTable struct {
+	Table        string   `json:"table"`
+	AccessType   string   `json:"access_type"`
+	PossibleKeys []string `json:"possible_keys"`
+	Key          string   `json:"key"`
+	UsedKeyParts []string `json:"used_key_parts"`
+	Rows         int64    `json:"rows"`
+	Filtered     float64  `json:"filtered"`
+	UsingIndex   bool     `json:"using_index"`
+	Condition    string   `json:"condition,omitempty"`
+}
+
+// Plan is what EXPLAIN FORMAT=JSON chose, Messages are the plan notes like Impossible WHERE
+type Plan struct {
+	Cost      float64     `json:"cost"`
+	Tables    []PlanTable `json:"tables"`
+	Filesort  bool        `json:"filesort"`
+	Temporary bool        `json:"temporary"`
+	Messages  []string    `json:"messages,omitempty"`
+}
+
+// Explain runs EXPLAIN FORMAT=JSON, the statement isn't executed
+func (i *Instance) Explain(ctx context.Context, stmt string) (*Plan, string, error) {
+	var text string
+	err := i.retry(ctx, func() error {
+		return i.DB.QueryRowContext(ctx, "explain format=json "+stmt).Scan(&text)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	plan, err := ParsePlan(text)
+	return plan, text, err
+}
+
+// ExplainAnalyze runs EXPLAIN ANALYZE, available from 8.0.18, it executes the statement
+func (i *Instance) ExplainAnalyze(ctx context.Context, stmt string) (string, error) {
+	var text string
+	err := i.DB.QueryRowContext(ctx, "explain analyze "+stmt).Scan(&text)
+	return text, err
+}
+
+// ParsePlan reads the tables and operations of EXPLAIN FORMAT=JSON output
+func ParsePlan(text string) (*Plan, error) {
+	var root map[string]interface{}
+	if err := json.Unmarshal([]byte(text), &root); err != nil {
+		return nil, fmt.Errorf("unexpected EXPLAIN output: %w", err)
+	}
+	plan := &Plan{}
+	if block, ok := root["query_block"].(map[string]interface{}); ok {
+		if costInfo, ok := block["cost_info"].(map[string]interface{}); ok {
+			plan.Cost = planNumber(costInfo["query_cost"])
+		}
+	}
+	plan.walk(root)
+	return plan, nil
+}
+
+// walk visits the plan depth first, keys in sorted order so tables come out the same on every run
+func (p *Plan) walk(node interface{}) {
+	switch n := node.(type) {
+	case []interface{}:
+		for _, child := range n {
+			p.walk(child)
+		}
+	case map[string]interface{}:
+		if name, ok := n["table_name"].(string); ok {
+			p.Tables = append(p.Tables, planTable(name, n))
+		}
+		if n["using_filesort"] == true {
+			p.Filesort = true
+		}
+		if n["using_temporary_table"] == true {
+			p.Temporary = true
+		}
+		if message, ok := n["message"].(string); ok {
+			p.Messages = append(p.Messages, message)
+		}
+		keys := make([]string, 0, len(n))
+		for key := range n {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			p.walk(n[key])
+		}
+	}
+}
+
+func planTable(name string, n map[string]interface{}) PlanTable {
+	t := PlanTable{Table: name}
+	t.AccessType, _ = n["access_type"].(string)
+	t.Key, _ = n["key"].(string)
+	t.PossibleKeys = stringList(n["possible_keys"])
+	t.UsedKeyParts = stringList(n["used_key_parts"])
+	t.Rows = int64(planNumber(n["rows_examined_per_scan"]))
+	t.Filtered = planNumber(n["filtered"])
+	t.UsingIndex = n["using_index"] == true
+	t.Condition, _ = n["attached_condition"].(string)
+	return t
+}
+
+// planNumber reads the numbers of EXPLAIN json, given as json numbers or as strings depending on the field
+func planNumber(value interface{}) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case string:
+		n, _ := strconv.ParseFloat(v, 64)
+		return n
+	}
+	return 0
+}
+
+func stringList(value interface{}) []string {
+	list, _ := value.([]interface{})
+	values := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+// Issues reviews the plan: full table and index scans, filesort, temporary tables, unused indexes
+// and tables examined with more than maxRows rows per scan
+func (p *Plan) Issues(maxRows int64) []string {
+	var issues []string
+	for _, t := range p.Tables {
+		switch {
+		case t.AccessType == "ALL" && len(t.PossibleKeys) == 0:
+			issues = append(issues, fmt.Sprintf("full table scan on %s, no usable index", t.Table))
+		case t.AccessType == "ALL":
+			issues = append(issues, fmt.Sprintf("full table scan on %s, possible keys %s not used", t.Table, strings.Join(t.PossibleKeys, ",")))
+		case t.AccessType == "index":
+			issues = append(issues, fmt.Sprintf("full index scan on %s using %s", t.Table, t.Key))
+		}
+		if maxRows > 0 && t.Rows > maxRows {
+			issues = append(issues, fmt.Sprintf("%s examines about %d rows per scan", t.Table, t.Rows))
+		}
+	}
+	if p.Filesort {
+		issues = append(issues, "using filesort")
+	}
+	if p.Temporary {
+		issues = append(issues, "using temporary table")
+	}
+	return issues
+}
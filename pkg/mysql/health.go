@@ -0,0 +1,189 @@
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"rdsdba/pkg/engine"
+
+	gomysql "github.com/go-sql-driver/mysql"
+)
+
+const (
+	retryBaseDelay = 50 * time.Millisecond
+	retryMaxDelay  = 2 * time.Second
+)
+
+// server errors seen while an endpoint fails over, statements are retried on them like on connection errors
+var failoverErrors = map[uint16]bool{
+	1040: true, // ER_CON_COUNT_ERROR
+	1053: true, // ER_SERVER_SHUTDOWN
+	1927: true, // ER_CONNECTION_KILLED
+	3100: true, // ER_RUN_HOOK_ERROR, replication plugin during switchover
+}
+
+// readOnlyErrors are what a healthy read only server answers to writes, they only mean a failover
+// once the health check saw the writer turn read only
+var readOnlyErrors = map[uint16]bool{
+	1290: true, // ER_OPTION_PREVENTS_STATEMENT, --read-only
+	1792: true, // ER_CANT_EXECUTE_IN_READ_ONLY_TRANSACTION
+	1836: true, // ER_READ_ONLY_MODE
+}
+
+type outageTracker struct {
+	mu      sync.Mutex
+	open    bool
+	start   time.Time
+	outages []engine.Outage
+}
+
+func (t *outageTracker) failure(at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.open {
+		t.open = true
+		t.start = at
+	}
+}
+
+// success closes the open outage, returns true when one was closed
+func (t *outageTracker) success(at time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.open {
+		return false
+	}
+	t.open = false
+	t.outages = append(t.outages, engine.Outage{Start: t.start, End: at})
+	return true
+}
+
+// IsReadOnlyError reports the errors of a write refused by a read only server
+func IsReadOnlyError(err error) bool {
+	var mysqlErr *gomysql.MySQLError
+	return errors.As(err, &mysqlErr) && readOnlyErrors[mysqlErr.Number]
+}
+
+// IsConnectionError reports errors of the connection rather than of the SQL, plus the failover ones
+func IsConnectionError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var mysqlErr *gomysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return failoverErrors[mysqlErr.Number]
+	}
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, gomysql.ErrInvalidConn) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &netErr)
+}
+
+// retry runs fn again with backoff on connection errors until it succeeds, fails on SQL or RetryTimeout passes.
+// Outages are recorded from the first connection error to the next success, fn runs once when RetryTimeout is 0.
+func (i *Instance) retry(ctx context.Context, fn func() error) error {
+	if i.Config.RetryTimeout <= 0 {
+		return fn()
+	}
+	delay := retryBaseDelay
+	var deadline time.Time
+	for {
+		err := fn()
+		now := time.Now()
+		// writes refused by a writer the health check saw turn read only wait for the new writer
+		if !IsConnectionError(err) && !(i.demoted.Load() && IsReadOnlyError(err)) {
+			if err == nil && i.outages.success(now) {
+				i.logger.Warn().Msg("endpoint recovered")
+			}
+			return err
+		}
+
+		i.outages.failure(now)
+		if deadline.IsZero() {
+			deadline = now.Add(i.Config.RetryTimeout)
+			// stale pooled connections may still point to the old server
+			i.ResetPool()
+		}
+		if now.Add(delay).After(deadline) {
+			return err
+		}
+		i.logger.Debug().Err(err).Dur("backoff", delay).Msg("connection error, retrying")
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		if delay *= 2; delay > retryMaxDelay {
+			delay = retryMaxDelay
+		}
+	}
+}
+
+// Outages returns the closed outages and the open one ending now
+func (i *Instance) Outages() []engine.Outage {
+	i.outages.mu.Lock()
+	defer i.outages.mu.Unlock()
+	outages := append([]engine.Outage(nil), i.outages.outages...)
+	if i.outages.open {
+		outages = append(outages, engine.Outage{Start: i.outages.start, End: time.Now()})
+	}
+	return outages
+}
+
+// ResetPool makes pooled connections be closed instead of reused, new ones resolve DNS again
+func (i *Instance) ResetPool() {
+	i.generation.Add(1)
+}
+
+// watch polls the read only state, a change means a failover: DNS is resolved again and the pool reset.
+// An endpoint read only from the start is a reader, its read only errors are never retried.
+func (i *Instance) watch(ctx context.Context, interval time.Duration) {
+	readOnly, err := i.ReadOnly(ctx)
+	known := err == nil
+	if err != nil {
+		i.logger.Warn().Err(err).Msg("health check failed")
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, interval)
+		current, err := i.ReadOnly(checkCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil {
+				i.logger.Debug().Err(err).Msg("health check failed")
+			}
+			continue
+		}
+		// the first state read is the starting one, not a change
+		if !known {
+			known, readOnly = true, current
+			continue
+		}
+		if current == readOnly {
+			continue
+		}
+
+		addrs, _ := net.DefaultResolver.LookupHost(ctx, i.Config.DSN.Host)
+		i.logger.Warn().Bool("was_read_only", readOnly).Bool("read_only", current).Strs("resolved", addrs).Msg("read only state changed, resetting connection pool")
+		readOnly = current
+		// a known writer turned read only, its refused writes are retried until the new writer answers
+		i.demoted.Store(current)
+		i.ResetPool()
+	}
+}
@@ -0,0 +1,48 @@
+package mysql
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRetryDisabled(t *testing.T) {
+	i := &Instance{}
+	calls := 0
+	err := i.retry(context.Background(), func() error {
+		calls++
+		return io.ErrUnexpectedEOF
+	})
+	if err != io.ErrUnexpectedEOF || calls != 1 {
+		t.Errorf("retry = %v after %d calls, want the connection error after 1", err, calls)
+	}
+	if outages := i.Outages(); len(outages) != 0 {
+		t.Errorf("outages = %v, want none without retry", outages)
+	}
+	if generation := i.generation.Load(); generation != 0 {
+		t.Errorf("pool reset %d times, want none without retry", generation)
+	}
+}
+
+func TestRetryConnectionError(t *testing.T) {
+	i := &Instance{}
+	i.Config.RetryTimeout = time.Minute
+	calls := 0
+	err := i.retry(context.Background(), func() error {
+		calls++
+		if calls == 1 {
+			return io.ErrUnexpectedEOF
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("retry = %v after %d calls, want success after 2", err, calls)
+	}
+	if outages := i.Outages(); len(outages) != 1 {
+		t.Errorf("outages = %v, want the one of the connection error", outages)
+	}
+	if generation := i.generation.Load(); generation != 1 {
+		t.Errorf("pool reset %d times, want once", generation)
+	}
+}
@@ -0,0 +1,66 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/rds/auth"
+)
+
+var (
+	ErrIAMNeedsTLS      = errors.New("IAM authentication sends the token in cleartext, it can't be used with ssl-mode disabled")
+	ErrIAMNoRegion      = errors.New("AWS region unknown for IAM authentication")
+	ErrIAMNoCredentials = errors.New("no AWS credentials provider for IAM authentication")
+)
+
+// IAMConfig enables RDS IAM database authentication, a new token(valid 15 minutes) is signed for every new connection
+type IAMConfig struct {
+	Enabled     bool
+	Region      string
+	Credentials aws.CredentialsProvider
+}
+
+// LoadIAMCredentials resolves credentials from the standard AWS chain: env, shared config and credentials files,
+// SSO, web identity, ECS and EC2 roles. Region falls back to AWS config then to the one in an RDS host name.
+func LoadIAMCredentials(ctx context.Context, region string, host string) (IAMConfig, error) {
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return IAMConfig{}, err
+	}
+
+	if len(region) == 0 {
+		region = awsCfg.Region
+	}
+	if len(region) == 0 {
+		region = regionFromHost(host)
+	}
+	if len(region) == 0 {
+		return IAMConfig{}, ErrIAMNoRegion
+	}
+	return IAMConfig{Enabled: true, Region: region, Credentials: awsCfg.Credentials}, nil
+}
+
+func (i *Instance) iamToken(ctx context.Context) (string, error) {
+	cfg := i.Config
+	if cfg.IAM.Credentials == nil {
+		return "", ErrIAMNoCredentials
+	}
+	endpoint := net.JoinHostPort(cfg.DSN.Host, strconv.Itoa(cfg.DSN.Port))
+	return auth.BuildAuthToken(ctx, endpoint, cfg.IAM.Region, cfg.DSN.User, cfg.IAM.Credentials)
+}
+
+// regionFromHost returns us-east-1 from mydb.xxx.us-east-1.rds.amazonaws.com
+func regionFromHost(host string) string {
+	labels := strings.Split(host, ".")
+	for index := 1; index < len(labels); index++ {
+		if labels[index] == "rds" {
+			return labels[index-1]
+		}
+	}
+	return ""
+}
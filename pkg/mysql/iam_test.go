@@ -0,0 +1,111 @@
+package mysql
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+func TestRegionFromHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"mydb.abcdefghijkl.us-east-1.rds.amazonaws.com", "us-east-1"},
+		{"orders.cluster-abcdefghijkl.eu-west-3.rds.amazonaws.com", "eu-west-3"},
+		{"orders.cluster-ro-abcdefghijkl.ap-northeast-1.rds.amazonaws.com", "ap-northeast-1"},
+		{"orders.cluster-custom-abcdefghijkl.us-west-2.rds.amazonaws.com", "us-west-2"},
+		{"mydb.abcdefghijkl.cn-north-1.rds.amazonaws.com.cn", "cn-north-1"},
+		{"db.example.com", ""},
+		{"rds.example.com", ""},
+		{"127.0.0.1", ""},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := regionFromHost(test.host); got != test.want {
+			t.Errorf("regionFromHost(%q) = %q, want %q", test.host, got, test.want)
+		}
+	}
+}
+
+// countingProvider counts the credentials retrieved, one per signed token
+type countingProvider struct {
+	aws.CredentialsProvider
+	retrieved atomic.Int32
+}
+
+func (p *countingProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	p.retrieved.Add(1)
+	return p.CredentialsProvider.Retrieve(ctx)
+}
+
+func iamInstance(host string, port int, provider aws.CredentialsProvider) *Instance {
+	i := &Instance{}
+	i.Config.DSN.Host = host
+	i.Config.DSN.Port = port
+	i.Config.DSN.User = "app"
+	i.Config.IAM = IAMConfig{Enabled: true, Region: "eu-west-3", Credentials: provider}
+	return i
+}
+
+func TestIAMToken(t *testing.T) {
+	provider := credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", "")
+	i := iamInstance("orders.cluster-abcdefghijkl.eu-west-3.rds.amazonaws.com", 3306, provider)
+
+	dsn, err := i.driverConfig(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dsn.AllowCleartextPasswords || dsn.AllowFallbackToPlaintext {
+		t.Errorf("cleartext %v, fallback to plaintext %v, want the token sent over TLS only", dsn.AllowCleartextPasswords, dsn.AllowFallbackToPlaintext)
+	}
+	token, err := url.Parse("https://" + dsn.Passwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Host != "orders.cluster-abcdefghijkl.eu-west-3.rds.amazonaws.com:3306" {
+		t.Errorf("token host = %q", token.Host)
+	}
+	query := token.Query()
+	if query.Get("Action") != "connect" || query.Get("DBUser") != "app" {
+		t.Errorf("token action %q user %q, want connect app", query.Get("Action"), query.Get("DBUser"))
+	}
+	if credential := query.Get("X-Amz-Credential"); !strings.HasPrefix(credential, "AKIDEXAMPLE/") || !strings.Contains(credential, "/eu-west-3/rds-db/") {
+		t.Errorf("token credential = %q", credential)
+	}
+	if len(query.Get("X-Amz-Signature")) == 0 {
+		t.Error("token isn't signed")
+	}
+
+	i.Config.IAM.Credentials = nil
+	if _, err = i.driverConfig(context.Background()); err != ErrIAMNoCredentials {
+		t.Errorf("without credentials err = %v, want %v", err, ErrIAMNoCredentials)
+	}
+}
+
+func TestIAMTokenPerConnection(t *testing.T) {
+	// a closed port, connections fail after the token is signed
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	provider := &countingProvider{CredentialsProvider: credentials.NewStaticCredentialsProvider("AKIDEXAMPLE", "secret", "")}
+	c := &connector{instance: iamInstance("127.0.0.1", port, provider)}
+	for attempt := 1; attempt <= 3; attempt++ {
+		if _, err = c.Connect(context.Background()); err == nil {
+			t.Fatal("connected to a closed port")
+		}
+		if got := provider.retrieved.Load(); got != int32(attempt) {
+			t.Errorf("%d connections signed %d tokens, want one per connection", attempt, got)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"rdsdba/pkg/engine"
+)
+
+// Index is an index of a user table, Columns carry their prefix length e.g. name(10).
+// Functional key parts(8.0.13+) show as (expression) and make the index Functional.
+// Uses counts the rows read and written through it since startup, nil when performance_schema is off.
+type Index struct {
+	engine.Table
+	Name       string   `json:"index"`
+	Unique     bool     `json:"unique"`
+	Type       string   `json:"type"`
+	Columns    []string `json:"columns"`
+	Functional bool     `json:"functional,omitempty"`
+	Size       int64    `json:"size"`
+	Uses       *uint64  `json:"uses,omitempty"`
+}
+
+// keyPartLength splits name(10) into the column and its prefix length
+var keyPartLength = regexp.MustCompile(`^(.*)(\(\d+\))$`)
+
+func (x Index) Primary() bool {
+	return x.Name == "PRIMARY"
+}
+
+// Definition is the column list as in CREATE TABLE
+func (x Index) Definition() string {
+	return "(" + strings.Join(x.Columns, ",") + ")"
+}
+
+// DropStatement is the ALTER TABLE dropping the index
+func (x Index) DropStatement() string {
+	return fmt.Sprintf("ALTER TABLE %s DROP INDEX %s;", quoteTable(x.Table), quoteName(x.Name))
+}
+
+// RedundantIndex is an index another index of the table already serves
+type RedundantIndex struct {
+	Index     Index  `json:"index"`
+	CoveredBy string `json:"covered_by"`
+	Reason    string `json:"reason"`
+	// Suggestion is the ALTER TABLE fixing it
+	Suggestion string `json:"suggestion"`
+}
+
+// Indexes returns the indexes of user tables with their size from mysql.innodb_index_stats
+// and their use from performance_schema.table_io_waits_summary_by_index_usage
+func (i *Instance) Indexes(ctx context.Context) ([]Index, error) {
+	stmt := fmt.Sprintf(`select table_schema, table_name, index_name, non_unique, coalesce(column_name, ''), coalesce(sub_part, 0), index_type
+	from information_schema.statistics where table_schema not in (%s)
+	order by table_schema, table_name, index_name, seq_in_index`, SystemSchema)
+
+	var indexes []Index
+	err := i.retry(ctx, func() error {
+		rows, err := i.DB.QueryContext(ctx, stmt)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		indexes = indexes[:0]
+		for rows.Next() {
+			var x Index
+			var nonUnique, subPart int
+			var column string
+			if err = rows.Scan(&x.SchemaName, &x.TableName, &x.Name, &nonUnique, &column, &subPart, &x.Type); err != nil {
+				return err
+			}
+			// functional key parts have no column name
+			functional := len(column) == 0
+			if functional {
+				column = "(expression)"
+			}
+			if subPart > 0 {
+				column = fmt.Sprintf("%s(%d)", column, subPart)
+			}
+			last := len(indexes) - 1
+			if last >= 0 && indexes[last].Table == x.Table && indexes[last].Name == x.Name {
+				indexes[last].Columns = append(indexes[last].Columns, column)
+				indexes[last].Functional = indexes[last].Functional || functional
+				continue
+			}
+			x.Unique = nonUnique == 0
+			x.Functional = functional
+			x.Columns = []string{column}
+			indexes = append(indexes, x)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sizes, err := i.indexSizes(ctx)
+	if err != nil {
+		return indexes, fmt.Errorf("index sizes unknown: %w", err)
+	}
+	uses, err := i.indexUses(ctx)
+	if err != nil {
+		return indexes, fmt.Errorf("index usage unknown: %w", err)
+	}
+	for index := range indexes {
+		key := indexKey(indexes[index].Table, indexes[index].Name)
+		indexes[index].Size = sizes[key]
+		if uses != nil {
+			count := uses[key]
+			indexes[index].Uses = &count
+		}
+	}
+	return indexes, nil
+}
+
+func indexKey(t engine.Table, name string) string {
+	return t.String() + "." + name
+}
+
+// indexSizes are the bytes of each index, partitions summed up
+func (i *Instance) indexSizes(ctx context.Context) (map[string]int64, error) {
+	rows, err := i.DB.QueryContext(ctx, `select database_name, table_name, index_name, stat_value * @@innodb_page_size
+	from mysql.innodb_index_stats where stat_name = 'size'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sizes := make(map[string]int64)
+	for rows.Next() {
+		var t engine.Table
+		var name string
+		var size int64
+		if err = rows.Scan(&t.SchemaName, &t.TableName, &name, &size); err != nil {
+			return nil, err
+		}
+		// partitions are stored as table#p#partition or table#P#partition
+		if index := strings.Index(strings.ToLower(t.TableName), "#p#"); index > 0 {
+			t.TableName = t.TableName[:index]
+		}
+		sizes[indexKey(t, name)] += size
+	}
+	return sizes, rows.Err()
+}
+
+// indexUses counts rows read and written through each index since startup, nil when performance_schema is off
+func (i *Instance) indexUses(ctx context.Context) (map[string]uint64, error) {
+	var psEnabled bool
+	if err := i.DB.QueryRowContext(ctx, "select @@global.performance_schema").Scan(&psEnabled); err != nil {
+		return nil, err
+	}
+	if !psEnabled {
+		return nil, nil
+	}
+
+	rows, err := i.DB.QueryContext(ctx, `select object_schema, object_name, index_name, count_star
+	from performance_schema.table_io_waits_summary_by_index_usage where index_name is not null`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	uses := make(map[string]uint64)
+	for rows.Next() {
+		var t engine.Table
+		var name string
+		var count uint64
+		if err = rows.Scan(&t.SchemaName, &t.TableName, &name, &count); err != nil {
+			return nil, err
+		}
+		uses[indexKey(t, name)] = count
+	}
+	return uses, rows.Err()
+}
+
+// Uptime is the seconds since the server started
+func (i *Instance) Uptime(ctx context.Context) (int64, error) {
+	var name string
+	var uptime int64
+	err := i.DB.QueryRowContext(ctx, "show global status like 'Uptime'").Scan(&name, &uptime)
+	return uptime, err
+}
+
+// UnusedIndexes are the secondary indexes without any read or write since startup. Primary and unique keys
+// are left out as they enforce constraints, as are all indexes when usage is unknown.
+func UnusedIndexes(indexes []Index) []Index {
+	var unused []Index
+	for _, x := range indexes {
+		if x.Primary() || x.Unique || x.Uses == nil || *x.Uses > 0 {
+			continue
+		}
+		unused = append(unused, x)
+	}
+	return unused
+}
+
+// RedundantIndexes finds per table duplicate indexes, indexes that are a left prefix of another one
+// and secondary indexes ending with the primary key columns InnoDB appends anyway.
+// Functional indexes are left out, their expressions aren't compared.
+func RedundantIndexes(indexes []Index) []RedundantIndex {
+	byTable := make(map[engine.Table][]Index)
+	var tables []engine.Table
+	for _, x := range indexes {
+		if _, ok := byTable[x.Table]; !ok {
+			tables = append(tables, x.Table)
+		}
+		byTable[x.Table] = append(byTable[x.Table], x)
+	}
+	sort.SliceStable(tables, func(a, b int) bool { return tables[a].String() < tables[b].String() })
+
+	var redundant []RedundantIndex
+	for _, t := range tables {
+		redundant = append(redundant, tableRedundantIndexes(byTable[t])...)
+	}
+	return redundant
+}
+
+func tableRedundantIndexes(indexes []Index) []RedundantIndex {
+	var primary *Index
+	for index := range indexes {
+		if indexes[index].Primary() {
+			primary = &indexes[index]
+		}
+	}
+	// covering candidates by preference: primary key, unique, widest, then by name
+	candidates := append([]Index(nil), indexes...)
+	sort.SliceStable(candidates, func(a, b int) bool {
+		x, y := candidates[a], candidates[b]
+		if x.Primary() != y.Primary() {
+			return x.Primary()
+		}
+		if x.Unique != y.Unique {
+			return x.Unique
+		}
+		if len(x.Columns) != len(y.Columns) {
+			return len(x.Columns) > len(y.Columns)
+		}
+		return x.Name < y.Name
+	})
+
+	var redundant []RedundantIndex
+	dropped := make(map[string]bool)
+	// duplicates first so left prefixes are reported against the index that stays
+	for _, duplicates := range []bool{true, false} {
+		for _, x := range candidates {
+			if x.Primary() || x.Type != "BTREE" || x.Functional || dropped[x.Name] {
+				continue
+			}
+			for _, other := range candidates {
+				if other.Name == x.Name || other.Type != "BTREE" || other.Functional || dropped[other.Name] || !isPrefix(x.Columns, other.Columns) ||
+					duplicates != (len(x.Columns) == len(other.Columns)) {
+					continue
+				}
+				// a unique index only goes for a duplicate enforcing the same uniqueness
+				if x.Unique && !(other.Unique && duplicates) {
+					continue
+				}
+				// of two duplicates the first candidate stays
+				if duplicates && !other.Primary() && other.Unique == x.Unique && other.Name > x.Name {
+					continue
+				}
+				reason := "left prefix of " + other.Name + other.Definition()
+				if duplicates {
+					reason = "duplicate of " + other.Name
+				}
+				redundant = append(redundant, RedundantIndex{Index: x, CoveredBy: other.Name, Reason: reason, Suggestion: x.DropStatement()})
+				dropped[x.Name] = true
+				break
+			}
+		}
+	}
+
+	for _, x := range indexes {
+		if dropped[x.Name] || x.Primary() || x.Unique || x.Functional || primary == nil || len(x.Columns) <= len(primary.Columns) {
+			continue
+		}
+		suffix := x.Columns[len(x.Columns)-len(primary.Columns):]
+		if isPrefix(suffix, primary.Columns) {
+			kept := x.Columns[:len(x.Columns)-len(primary.Columns)]
+			redundant = append(redundant, RedundantIndex{Index: x, CoveredBy: "PRIMARY",
+				Reason: "ends with the primary key columns " + strings.Join(primary.Columns, ",") + " InnoDB appends to every secondary index",
+				Suggestion: fmt.Sprintf("ALTER TABLE %s DROP INDEX %s, ADD INDEX %s (%s);", quoteTable(x.Table), quoteName(x.Name),
+					quoteName(x.Name), strings.Join(quoteKeyParts(kept), ","))})
+		}
+	}
+	return redundant
+}
+
+// quoteKeyParts quotes the column names, prefix lengths stay outside: name(10) is `name`(10)
+func quoteKeyParts(columns []string) []string {
+	quoted := make([]string, len(columns))
+	for index, column := range columns {
+		length := ""
+		if match := keyPartLength.FindStringSubmatch(column); match != nil {
+			column, length = match[1], match[2]
+		}
+		quoted[index] = quoteName(column) + length
+	}
+	return quoted
+}
+
+// isPrefix reports whether columns is a left prefix of, or equal to, of
+func isPrefix(columns, of []string) bool {
+	if len(columns) > len(of) {
+		return false
+	}
+	for index := range columns {
+		if !strings.EqualFold(columns[index], of[index]) {
+			return false
+		}
+	}
+	return true
+}
+
+func quoteName(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+func quoteTable(t engine.Table) string {
+	return quoteName(t.SchemaName) + "." + quoteName(t.TableName)
+}
@@ -0,0 +1,98 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+
+	"rdsdba/pkg/engine"
+)
+
+func TestRedundantIndexes(t *testing.T) {
+	orders := engine.Table{SchemaName: "shop", TableName: "orders"}
+	index := func(name string, unique bool, columns ...string) Index {
+		return Index{Table: orders, Name: name, Unique: unique, Type: "BTREE", Columns: columns}
+	}
+	functional := func(name string) Index {
+		x := index(name, false, "(expression)")
+		x.Functional = true
+		return x
+	}
+	primary := index("PRIMARY", true, "id")
+
+	tests := []struct {
+		name    string
+		indexes []Index
+		want    []string // index covered by, in report order
+	}{
+		{"exact duplicate", []Index{primary, index("idx_b", false, "customer_id"), index("idx_a", false, "customer_id")},
+			[]string{"idx_b covered by idx_a: duplicate of idx_a"}},
+		{"duplicate ignoring case", []Index{primary, index("idx_a", false, "Customer_Id"), index("idx_b", false, "customer_id")},
+			[]string{"idx_b covered by idx_a: duplicate of idx_a"}},
+		{"left prefix", []Index{primary, index("idx_customer", false, "customer_id"), index("idx_customer_status", false, "customer_id", "status")},
+			[]string{"idx_customer covered by idx_customer_status: left prefix of idx_customer_status(customer_id,status)"}},
+		{"prefix length differs", []Index{primary, index("idx_name10", false, "name(10)"), index("idx_name20", false, "name(20)")}, nil},
+		{"non unique duplicate of unique", []Index{primary, index("idx_email", false, "email"), index("uk_email", true, "email")},
+			[]string{"idx_email covered by uk_email: duplicate of uk_email"}},
+		{"unique prefix of non unique stays", []Index{primary, index("uk_email", true, "email"), index("idx_email_name", false, "email", "name")}, nil},
+		{"unique duplicate of primary", []Index{primary, index("uk_id", true, "id")},
+			[]string{"uk_id covered by PRIMARY: duplicate of PRIMARY"}},
+		{"ends with primary key", []Index{primary, index("idx_status_id", false, "status", "id")},
+			[]string{"idx_status_id covered by PRIMARY: ends with the primary key columns id InnoDB appends to every secondary index"}},
+		{"prefix of primary key", []Index{index("PRIMARY", true, "tenant_id", "id"), index("idx_tenant", false, "tenant_id")},
+			[]string{"idx_tenant covered by PRIMARY: left prefix of PRIMARY(tenant_id,id)"}},
+		{"not btree", []Index{primary, {Table: orders, Name: "ft_a", Type: "FULLTEXT", Columns: []string{"note"}},
+			{Table: orders, Name: "ft_b", Type: "FULLTEXT", Columns: []string{"note"}}}, nil},
+		{"functional indexes", []Index{primary, functional("idx_lower_a"), functional("idx_upper_b"), index("idx_c", false, "c")}, nil},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, r := range RedundantIndexes(test.indexes) {
+			got = append(got, r.Index.Name+" covered by "+r.CoveredBy+": "+r.Reason)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: redundant = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRedundantIndexSuggestion(t *testing.T) {
+	t1 := engine.Table{SchemaName: "shop", TableName: "order"}
+	indexes := []Index{
+		{Table: t1, Name: "PRIMARY", Unique: true, Type: "BTREE", Columns: []string{"id"}},
+		{Table: t1, Name: "idx_key", Type: "BTREE", Columns: []string{"key", "name(10)", "id"}},
+		{Table: t1, Name: "idx_a", Type: "BTREE", Columns: []string{"a"}},
+		{Table: t1, Name: "idx_a_b", Type: "BTREE", Columns: []string{"a", "b"}},
+	}
+	want := map[string]string{
+		"idx_a":   "ALTER TABLE `shop`.`order` DROP INDEX `idx_a`;",
+		"idx_key": "ALTER TABLE `shop`.`order` DROP INDEX `idx_key`, ADD INDEX `idx_key` (`key`,`name`(10));",
+	}
+	redundant := RedundantIndexes(indexes)
+	if len(redundant) != len(want) {
+		t.Fatalf("redundant = %+v, want %d", redundant, len(want))
+	}
+	for _, r := range redundant {
+		if r.Suggestion != want[r.Index.Name] {
+			t.Errorf("%s: suggestion %q, want %q", r.Index.Name, r.Suggestion, want[r.Index.Name])
+		}
+	}
+}
+
+func TestUnusedIndexes(t *testing.T) {
+	t1 := engine.Table{SchemaName: "shop", TableName: "orders"}
+	zero, some := uint64(0), uint64(12)
+	indexes := []Index{
+		{Table: t1, Name: "PRIMARY", Unique: true, Columns: []string{"id"}, Uses: &zero},
+		{Table: t1, Name: "uk_email", Unique: true, Columns: []string{"email"}, Uses: &zero},
+		{Table: t1, Name: "idx_used", Columns: []string{"status"}, Uses: &some},
+		{Table: t1, Name: "idx_unused", Columns: []string{"note"}, Uses: &zero},
+		{Table: t1, Name: "idx_unknown", Columns: []string{"created_at"}},
+	}
+	var got []string
+	for _, x := range UnusedIndexes(indexes) {
+		got = append(got, x.Name)
+	}
+	if want := []string{"idx_unused"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unused = %q, want %q", got, want)
+	}
+}
@@ -5,22 +5,32 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"sync/atomic"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"rdsdba/pkg/engine"
+
 	"github.com/mitchellh/mapstructure"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
 const (
-	ConnectionFmt = "%s:%s@tcp(%s:%d)/?timeout=%s&tmp_table_size=2147483648&max_heap_table_size=2147483648&max_execution_time=0&maxAllowedPacket=0"
+	// ConnectionFmt is user@net(address)/?timeout, session variables and extra params are appended
+	ConnectionFmt = "%s@%s(%s)/?timeout=%s&maxAllowedPacket=0"
 	SystemSchema  = " 'information_schema', 'innodb', 'mysql', 'performance_schema', 'sys' "
 	pingTimeout   = 5 * time.Second
 	connTimeout   = 10 * time.Second
 )
 
 var (
+	// WarmupSessionVars let full scans of big tables run without timeout or on disk temporary tables
+	WarmupSessionVars = map[string]string{
+		"tmp_table_size":      "2147483648",
+		"max_heap_table_size": "2147483648",
+		"max_execution_time":  "0",
+	}
+
 	ErrInstanceInitFailed = errors.New("instance initialise failed")
 	ErrDBInitFailed       = errors.New("connection initialise failed %s")
 )
@@ -30,24 +40,54 @@ type Config struct {
 	MaxOpenConns    int
 	MaxIdleConns    int
 	ConnMaxLifeTime time.Duration
-	Debug           bool
 	Sleep           time.Duration
-	DSN             struct {
+	// Logger is the base logger of the instance, the global logger when nil
+	Logger *zerolog.Logger
+	// RetryTimeout is how long statements are retried on connection errors, 0 disables retry
+	RetryTimeout time.Duration
+	// HealthCheckInterval polls the read only state to detect failovers, 0 disables it
+	HealthCheckInterval time.Duration
+	// Password overrides DSN.Passwd when set, DSN.Passwd and Password are never logged
+	Password engine.PasswordProvider
+	TLS      TLSConfig
+	IAM      IAMConfig
+	SSH      SSHConfig
+	// Socket connects over unix socket instead of tcp to DSN.Host
+	Socket string
+	// RawDSN replaces the DSN built from DSN fields, TLS, SSH and password settings still apply when it doesn't set them
+	RawDSN string
+	// SessionVars are set on every new connection, Params are extra go-sql-driver DSN parameters
+	SessionVars map[string]string
+	Params      map[string]string
+	DSN         struct {
 		Host   string
 		Port   int
 		User   string
 		Passwd string
+		// Database is the default database of connections, none when empty
+		Database string
 	}
 }
 
 type Instance struct {
-	Config Config
-	logger zerolog.Logger
-	DB     *sql.DB
+	Config     Config
+	logger     zerolog.Logger
+	DB         *sql.DB
+	tlsName    string
+	sshNet     string
+	generation atomic.Uint64
+	// demoted is set while the health check sees a writer of this endpoint turned read only
+	demoted     atomic.Bool
+	outages     outageTracker
+	cancelWatch context.CancelFunc
 }
 
 func NewInstance(config Config) (*Instance, error) {
-	logger := log.With().
+	base := log.Logger
+	if config.Logger != nil {
+		base = *config.Logger
+	}
+	logger := base.With().
 		Str("user", config.DSN.User).
 		Str("host", config.DSN.Host).
 		Int("port", config.DSN.Port).
@@ -66,25 +106,53 @@ func NewInstance(config Config) (*Instance, error) {
 		return nil, ErrDBInitFailed
 	}
 	i.DB = conn
+
+	if config.HealthCheckInterval > 0 {
+		var ctx context.Context
+		ctx, i.cancelWatch = context.WithCancel(context.Background())
+		go i.watch(ctx, config.HealthCheckInterval)
+	}
 	return i, nil
 }
 
+// Close stops the health check and closes the connection pool
+func (i *Instance) Close() error {
+	if i.cancelWatch != nil {
+		i.cancelWatch()
+	}
+	return i.DB.Close()
+}
+
 func (i *Instance) Open() (*sql.DB, error) {
 	cfg := i.Config
-	db, err := sql.Open("mysql",
-		fmt.Sprintf(ConnectionFmt, cfg.DSN.User, cfg.DSN.Passwd, cfg.DSN.Host, cfg.DSN.Port, connTimeout))
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+
+	tlsName, err := i.registerTLS()
+	if err != nil {
+		return nil, err
+	}
+	i.tlsName = tlsName
+
+	sshNet, err := i.registerSSH()
 	if err != nil {
 		return nil, err
 	}
+	i.sshNet = sshNet
+
+	dsn, err := i.driverConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	i.logger.Debug().Str("dsn", redactDSN(dsn)).Msg("open")
+	db := sql.OpenDB(&connector{instance: i})
 
 	db.SetConnMaxLifetime(cfg.ConnMaxLifeTime)
 	db.SetMaxOpenConns(cfg.MaxOpenConns)
 	db.SetMaxIdleConns(cfg.MaxIdleConns)
 
-	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
-	defer cancel()
-
 	if err = db.PingContext(ctx); err != nil {
+		db.Close()
 		return nil, err
 	}
 	return db, nil
@@ -94,14 +162,17 @@ func (i *Instance) Logger() zerolog.Logger {
 	return i.logger
 }
 
-func (i *Instance) WarmUp(ctx context.Context, table Table) error {
+func (i *Instance) WarmUp(ctx context.Context, table engine.Table) error {
 	tableIdentifier := table.SchemaName + "." + table.TableName
 
 	stmt1 := fmt.Sprintf("select count(*) from %s", tableIdentifier)
 	stmt2 := fmt.Sprintf("ANALYZE TABLE %s", tableIdentifier)
 	stmts := []string{stmt1, stmt2}
 	for index := range stmts {
-		_, _, _, err := Query(ctx, i.DB, stmts[index])
+		err := i.retry(ctx, func() error {
+			_, _, _, err := Query(ctx, i.DB, stmts[index])
+			return err
+		})
 		if err != nil {
 			return err
 		}
@@ -109,17 +180,23 @@ func (i *Instance) WarmUp(ctx context.Context, table Table) error {
 	return nil
 }
 
-func (i *Instance) GetUserTables(ctx context.Context) ([]Table, error) {
-	var tables []Table
+// GetUserTables returns every base table outside system schemas
+func (i *Instance) GetUserTables(ctx context.Context) ([]engine.Table, error) {
+	var tables []engine.Table
 	stmt := fmt.Sprintf("select table_schema, table_name from information_schema.tables where table_schema not in (%s) and table_type='BASE TABLE'", SystemSchema)
-	_, _, data, err := Query(ctx, i.DB, stmt)
+	var data []map[string]interface{}
+	err := i.retry(ctx, func() (err error) {
+		// Query stops at MaxRowsSize rows, schemas often have more tables
+		_, _, data, err = QueryAll(ctx, i.DB, stmt)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	tables = make([]Table, 0, len(data))
+	tables = make([]engine.Table, 0, len(data))
 	for index := range data {
-		var table Table
+		var table engine.Table
 		decodeConfig := &mapstructure.DecoderConfig{
 			WeaklyTypedInput: true,
 			Result:           &table,
@@ -139,7 +216,11 @@ func (i *Instance) GetUserTables(ctx context.Context) ([]Table, error) {
 }
 
 func (i *Instance) Stress(ctx context.Context, query string) (int64, error) {
-	rt, _, _, err := Query(ctx, i.DB, query)
+	var rt QueryResponseInfo
+	err := i.retry(ctx, func() (err error) {
+		rt, _, _, err = Query(ctx, i.DB, query)
+		return err
+	})
 
 	if err != nil {
 		if !errors.Is(err, context.DeadlineExceeded) {
@@ -149,3 +230,13 @@ func (i *Instance) Stress(ctx context.Context, query string) (int64, error) {
 	}
 	return rt.Duration, nil
 }
+
+// ReadOnly reports whether the instance refuses writes, Aurora readers only set innodb_read_only
+func (i *Instance) ReadOnly(ctx context.Context) (bool, error) {
+	var readOnly, innodbReadOnly bool
+	err := i.DB.QueryRowContext(ctx, "select @@global.read_only, @@global.innodb_read_only").Scan(&readOnly, &innodbReadOnly)
+	if err != nil {
+		return false, err
+	}
+	return readOnly || innodbReadOnly, nil
+}
@@ -0,0 +1,184 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+const (
+	// lockWaits80 joins the 8.0 performance_schema lock tables with the transactions and threads of both sides
+	lockWaits80 = `select w.requesting_engine_transaction_id, w.blocking_engine_transaction_id,
+		rt.trx_mysql_thread_id, bt.trx_mysql_thread_id, coalesce(timestampdiff(second, rt.trx_wait_started, now()), 0),
+		coalesce(timestampdiff(second, bt.trx_started, now()), 0),
+		coalesce(rp.user, ''), coalesce(rp.host, ''), coalesce(rt.trx_query, ''),
+		coalesce(bp.user, ''), coalesce(bp.host, ''), coalesce(bp.command, ''), coalesce(bp.time, 0), coalesce(bt.trx_query, ''),
+		concat(rl.object_schema, '.', rl.object_name), coalesce(rl.index_name, ''), rl.lock_type, rl.lock_mode, bl.lock_mode
+	from performance_schema.data_lock_waits w
+	join information_schema.innodb_trx rt on rt.trx_id = w.requesting_engine_transaction_id
+	join information_schema.innodb_trx bt on bt.trx_id = w.blocking_engine_transaction_id
+	join performance_schema.data_locks rl on rl.engine_lock_id = w.requesting_engine_lock_id
+	join performance_schema.data_locks bl on bl.engine_lock_id = w.blocking_engine_lock_id
+	left join information_schema.processlist rp on rp.id = rt.trx_mysql_thread_id
+	left join information_schema.processlist bp on bp.id = bt.trx_mysql_thread_id`
+
+	// lockWaits57 is the same from the 5.7 information_schema tables
+	lockWaits57 = `select w.requesting_trx_id, w.blocking_trx_id,
+		rt.trx_mysql_thread_id, bt.trx_mysql_thread_id, coalesce(timestampdiff(second, rt.trx_wait_started, now()), 0),
+		coalesce(timestampdiff(second, bt.trx_started, now()), 0),
+		coalesce(rp.user, ''), coalesce(rp.host, ''), coalesce(rt.trx_query, ''),
+		coalesce(bp.user, ''), coalesce(bp.host, ''), coalesce(bp.command, ''), coalesce(bp.time, 0), coalesce(bt.trx_query, ''),
+		replace(rl.lock_table, '` + "`" + `', ''), coalesce(rl.lock_index, ''), rl.lock_type, rl.lock_mode, bl.lock_mode
+	from information_schema.innodb_lock_waits w
+	join information_schema.innodb_trx rt on rt.trx_id = w.requesting_trx_id
+	join information_schema.innodb_trx bt on bt.trx_id = w.blocking_trx_id
+	join information_schema.innodb_locks rl on rl.lock_id = w.requested_lock_id
+	join information_schema.innodb_locks bl on bl.lock_id = w.blocking_lock_id
+	left join information_schema.processlist rp on rp.id = rt.trx_mysql_thread_id
+	left join information_schema.processlist bp on bp.id = bt.trx_mysql_thread_id`
+)
+
+// LockWait is one transaction waiting for a lock held by another, times are seconds
+type LockWait struct {
+	WaitingTrx     string `json:"waiting_trx"`
+	BlockingTrx    string `json:"blocking_trx"`
+	WaitingThread  uint64 `json:"waiting_thread"`
+	BlockingThread uint64 `json:"blocking_thread"`
+	WaitTime       int64  `json:"wait_time"`
+	BlockingTrxAge int64  `json:"blocking_trx_age"`
+	WaitingUser    string `json:"waiting_user"`
+	WaitingHost    string `json:"waiting_host"`
+	WaitingQuery   string `json:"waiting_query"`
+	BlockingUser   string `json:"blocking_user"`
+	BlockingHost   string `json:"blocking_host"`
+	// BlockingCommand is Sleep when the blocker is idle in its transaction, BlockingQuery is then empty
+	BlockingCommand string `json:"blocking_command"`
+	BlockingTime    int64  `json:"blocking_time"`
+	BlockingQuery   string `json:"blocking_query"`
+	Table           string `json:"table"`
+	Index           string `json:"index"`
+	LockType        string `json:"lock_type"`
+	WaitingMode     string `json:"waiting_mode"`
+	BlockingMode    string `json:"blocking_mode"`
+}
+
+// LockWaits reads performance_schema.data_lock_waits on 8.0, information_schema.innodb_lock_waits before
+func (i *Instance) LockWaits(ctx context.Context) ([]LockWait, error) {
+	version, err := i.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stmt := lockWaits57
+	if version.AtLeast(8, 0, 1) {
+		stmt = lockWaits80
+	}
+
+	var waits []LockWait
+	err = i.retry(ctx, func() error {
+		rows, err := i.DB.QueryContext(ctx, stmt)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		waits = waits[:0]
+		for rows.Next() {
+			var w LockWait
+			var waitingThread, blockingThread sql.NullInt64
+			err = rows.Scan(&w.WaitingTrx, &w.BlockingTrx, &waitingThread, &blockingThread, &w.WaitTime, &w.BlockingTrxAge,
+				&w.WaitingUser, &w.WaitingHost, &w.WaitingQuery,
+				&w.BlockingUser, &w.BlockingHost, &w.BlockingCommand, &w.BlockingTime, &w.BlockingQuery,
+				&w.Table, &w.Index, &w.LockType, &w.WaitingMode, &w.BlockingMode)
+			if err != nil {
+				return err
+			}
+			w.WaitingThread = uint64(waitingThread.Int64)
+			w.BlockingThread = uint64(blockingThread.Int64)
+			waits = append(waits, w)
+		}
+		return rows.Err()
+	})
+	return waits, err
+}
+
+// BlockNode is a thread in the blocking tree, Waiters are the lock waits of the threads it blocks
+type BlockNode struct {
+	Thread  uint64       `json:"thread"`
+	Trx     string       `json:"trx"`
+	User    string       `json:"user"`
+	Host    string       `json:"host"`
+	Command string       `json:"command,omitempty"`
+	Time    int64        `json:"time,omitempty"`
+	TrxAge  int64        `json:"trx_age,omitempty"`
+	Query   string       `json:"query"`
+	Wait    *LockWait    `json:"wait,omitempty"`
+	Waiters []*BlockNode `json:"waiters,omitempty"`
+}
+
+// BlockingTree returns the root blockers, the threads blocking others without waiting themselves, with their waiters below.
+// A thread waiting on several blockers shows under each of them. Threads in a wait cycle, i.e. a deadlock not yet
+// detected, have no root blocker and are returned as roots.
+func BlockingTree(waits []LockWait) []*BlockNode {
+	waitsOf := make(map[uint64][]LockWait)
+	waiting := make(map[uint64]bool)
+	for _, w := range waits {
+		waitsOf[w.BlockingThread] = append(waitsOf[w.BlockingThread], w)
+		waiting[w.WaitingThread] = true
+	}
+
+	var build func(node *BlockNode, path map[uint64]bool)
+	build = func(node *BlockNode, path map[uint64]bool) {
+		path[node.Thread] = true
+		defer delete(path, node.Thread)
+		for index := range waitsOf[node.Thread] {
+			w := waitsOf[node.Thread][index]
+			if path[w.WaitingThread] {
+				continue
+			}
+			child := &BlockNode{Thread: w.WaitingThread, Trx: w.WaitingTrx, User: w.WaitingUser, Host: w.WaitingHost, Query: w.WaitingQuery, Wait: &w}
+			build(child, path)
+			node.Waiters = append(node.Waiters, child)
+		}
+	}
+
+	var roots []*BlockNode
+	seen := make(map[uint64]bool)
+	for _, w := range waits {
+		blocker := w.BlockingThread
+		if seen[blocker] || (waiting[blocker] && !inCycle(blocker, waitsOf)) {
+			continue
+		}
+		seen[blocker] = true
+		root := &BlockNode{Thread: blocker, Trx: w.BlockingTrx, User: w.BlockingUser, Host: w.BlockingHost,
+			Command: w.BlockingCommand, Time: w.BlockingTime, TrxAge: w.BlockingTrxAge, Query: w.BlockingQuery}
+		build(root, make(map[uint64]bool))
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+// inCycle reports whether thread waits, directly or not, for itself
+func inCycle(thread uint64, waitsOf map[uint64][]LockWait) bool {
+	visited := make(map[uint64]bool)
+	stack := []uint64{thread}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, w := range waitsOf[current] {
+			if w.WaitingThread == thread {
+				return true
+			}
+			if !visited[w.WaitingThread] {
+				visited[w.WaitingThread] = true
+				stack = append(stack, w.WaitingThread)
+			}
+		}
+	}
+	return false
+}
+
+// IdleInTrx reports whether the blocker is a session sleeping with its transaction open, typically an application
+// forgetting to commit
+func (n *BlockNode) IdleInTrx() bool {
+	return strings.EqualFold(n.Command, "Sleep") && len(n.Query) == 0
+}
@@ -0,0 +1,45 @@
+package mysql
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestBlockingTree(t *testing.T) {
+	// wait is blocker->waiter
+	wait := func(blocker, waiter uint64) LockWait {
+		return LockWait{BlockingThread: blocker, WaitingThread: waiter}
+	}
+	tests := []struct {
+		name  string
+		waits []LockWait
+		want  string
+	}{
+		{"no waits", nil, ""},
+		{"single", []LockWait{wait(1, 2)}, "1(2)"},
+		{"chain", []LockWait{wait(2, 3), wait(1, 2)}, "1(2(3))"},
+		{"several waiters", []LockWait{wait(1, 2), wait(1, 3), wait(3, 4)}, "1(2 3(4))"},
+		{"two blockers", []LockWait{wait(1, 3), wait(2, 3), wait(3, 4)}, "1(3(4)) 2(3(4))"},
+		{"cycle", []LockWait{wait(1, 2), wait(2, 1)}, "1(2) 2(1)"},
+		{"cycle with waiters", []LockWait{wait(1, 2), wait(2, 3), wait(3, 1), wait(3, 4)}, "1(2(3(4))) 2(3(1 4)) 3(1(2) 4)"},
+		{"blocker of a cycle", []LockWait{wait(9, 1), wait(1, 2), wait(2, 1)}, "9(1(2)) 1(2) 2(1)"},
+		{"separate trees", []LockWait{wait(1, 2), wait(5, 6)}, "1(2) 5(6)"},
+	}
+	for _, test := range tests {
+		if got := formatTree(BlockingTree(test.waits)); got != test.want {
+			t.Errorf("%s: tree %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func formatTree(nodes []*BlockNode) string {
+	parts := make([]string, len(nodes))
+	for index, n := range nodes {
+		parts[index] = strconv.FormatUint(n.Thread, 10)
+		if len(n.Waiters) > 0 {
+			parts[index] += "(" + formatTree(n.Waiters) + ")"
+		}
+	}
+	return strings.Join(parts, " ")
+}
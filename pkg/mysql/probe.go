@@ -0,0 +1,78 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"rdsdba/pkg/engine"
+)
+
+const DefaultProbeTable = "rdsdba_probe.heartbeat"
+
+// ServerIdentity tells which server answered, AuroraServerID is empty on non Aurora
+type ServerIdentity struct {
+	Hostname       string
+	ServerID       int64
+	AuroraServerID string
+	ReadOnly       bool
+}
+
+func (s ServerIdentity) String() string {
+	if len(s.AuroraServerID) > 0 {
+		return fmt.Sprintf("%s(server_id %d, aurora %s)", s.Hostname, s.ServerID, s.AuroraServerID)
+	}
+	return fmt.Sprintf("%s(server_id %d)", s.Hostname, s.ServerID)
+}
+
+// IsAurora reports whether @@aurora_server_id exists
+func (i *Instance) IsAurora(ctx context.Context) bool {
+	var id sql.NullString
+	return i.DB.QueryRowContext(ctx, "select @@aurora_server_id").Scan(&id) == nil
+}
+
+// CreateProbeTable creates the single row heartbeat table
+func (i *Instance) CreateProbeTable(ctx context.Context, table engine.Table) error {
+	return i.createTable(ctx, table, "id tinyint unsigned not null primary key, ts timestamp(6) not null")
+}
+
+// createTable creates the schema and the table with the columns when missing
+func (i *Instance) createTable(ctx context.Context, table engine.Table, columns string) error {
+	stmts := []string{
+		"create database if not exists " + quoteName(table.SchemaName),
+		fmt.Sprintf("create table if not exists %s (%s)", quoteTable(table), columns),
+	}
+	for _, stmt := range stmts {
+		if _, err := i.DB.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Heartbeat writes the probe table when write is set, then reads it back with the identity of the server which answered,
+// an empty table skips the probe table on readers of a cluster without writer probe
+func (i *Instance) Heartbeat(ctx context.Context, table engine.Table, write bool, aurora bool) (ServerIdentity, error) {
+	var id ServerIdentity
+	if write {
+		stmt := fmt.Sprintf("insert into %s (id, ts) values (1, now(6)) on duplicate key update ts = now(6)", quoteTable(table))
+		if _, err := i.DB.ExecContext(ctx, stmt); err != nil {
+			return id, err
+		}
+	}
+
+	auroraID := "''"
+	if aurora {
+		auroraID = "@@aurora_server_id"
+	}
+	probeTs := "null"
+	if len(table.TableName) > 0 {
+		probeTs = fmt.Sprintf("(select ts from %s where id = 1)", quoteTable(table))
+	}
+	var readOnly, innodbReadOnly bool
+	var ts sql.NullString
+	stmt := fmt.Sprintf("select @@hostname, @@server_id, %s, @@global.read_only, @@global.innodb_read_only, %s", auroraID, probeTs)
+	err := i.DB.QueryRowContext(ctx, stmt).Scan(&id.Hostname, &id.ServerID, &id.AuroraServerID, &readOnly, &innodbReadOnly, &ts)
+	id.ReadOnly = readOnly || innodbReadOnly
+	return id, err
+}
@@ -0,0 +1,151 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Process is one client thread, TrxTime is the age in seconds of its open InnoDB transaction
+type Process struct {
+	ID      uint64 `json:"id"`
+	User    string `json:"user"`
+	Host    string `json:"host"`
+	DB      string `json:"db"`
+	Command string `json:"command"`
+	Time    int64  `json:"time"`
+	State   string `json:"state"`
+	Info    string `json:"info"`
+	TrxTime *int64 `json:"trx_time,omitempty"`
+}
+
+// ProcessFilter selects processes, empty fields match everything.
+// User, DB and Command match exactly ignoring case, Host without the client port, State as substring and Info as regexp.
+// MinTrxTime only matches threads with an open transaction at least that old.
+type ProcessFilter struct {
+	User       string
+	Host       string
+	DB         string
+	Command    string
+	State      string
+	MinTime    int64
+	MinTrxTime int64
+	Info       *regexp.Regexp
+}
+
+func (f ProcessFilter) Match(p Process) bool {
+	host, _, _ := strings.Cut(p.Host, ":")
+	switch {
+	case len(f.User) > 0 && !strings.EqualFold(f.User, p.User):
+		return false
+	case len(f.Host) > 0 && !strings.EqualFold(f.Host, host) && !strings.EqualFold(f.Host, p.Host):
+		return false
+	case len(f.DB) > 0 && !strings.EqualFold(f.DB, p.DB):
+		return false
+	case len(f.Command) > 0 && !strings.EqualFold(f.Command, p.Command):
+		return false
+	case len(f.State) > 0 && !strings.Contains(strings.ToLower(p.State), strings.ToLower(f.State)):
+		return false
+	case p.Time < f.MinTime:
+		return false
+	case f.MinTrxTime > 0 && (p.TrxTime == nil || *p.TrxTime < f.MinTrxTime):
+		return false
+	case f.Info != nil && !f.Info.MatchString(p.Info):
+		return false
+	}
+	return true
+}
+
+// Processlist returns the client threads except the caller's one. performance_schema.threads is read when enabled,
+// it doesn't take the global mutex of information_schema.processlist on busy servers.
+func (i *Instance) Processlist(ctx context.Context) ([]Process, error) {
+	var psEnabled bool
+	if err := i.DB.QueryRowContext(ctx, "select @@global.performance_schema").Scan(&psEnabled); err != nil {
+		return nil, err
+	}
+
+	stmt := `select p.id, coalesce(p.user, ''), coalesce(p.host, ''), coalesce(p.db, ''), coalesce(p.command, ''),
+		coalesce(p.time, 0), coalesce(p.state, ''), coalesce(p.info, ''), timestampdiff(second, t.trx_started, now())
+	from information_schema.processlist p
+	left join information_schema.innodb_trx t on t.trx_mysql_thread_id = p.id
+	where p.id <> connection_id()`
+	if psEnabled {
+		stmt = `select p.processlist_id, coalesce(p.processlist_user, ''), coalesce(p.processlist_host, ''), coalesce(p.processlist_db, ''),
+			coalesce(p.processlist_command, ''), coalesce(p.processlist_time, 0), coalesce(p.processlist_state, ''), coalesce(p.processlist_info, ''),
+			timestampdiff(second, t.trx_started, now())
+		from performance_schema.threads p
+		left join information_schema.innodb_trx t on t.trx_mysql_thread_id = p.processlist_id
+		where p.type = 'FOREGROUND' and p.processlist_id is not null and p.processlist_id <> connection_id()`
+	}
+
+	var processes []Process
+	err := i.retry(ctx, func() error {
+		rows, err := i.DB.QueryContext(ctx, stmt)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		processes = processes[:0]
+		for rows.Next() {
+			var p Process
+			var trxTime sql.NullInt64
+			if err = rows.Scan(&p.ID, &p.User, &p.Host, &p.DB, &p.Command, &p.Time, &p.State, &p.Info, &trxTime); err != nil {
+				return err
+			}
+			if trxTime.Valid {
+				p.TrxTime = &trxTime.Int64
+			}
+			processes = append(processes, p)
+		}
+		return rows.Err()
+	})
+	return processes, err
+}
+
+// HasRDSKill reports whether the RDS procedures mysql.rds_kill and mysql.rds_kill_query exist,
+// the RDS master user has no privilege to KILL threads of other users
+func (i *Instance) HasRDSKill(ctx context.Context) (bool, error) {
+	var count int
+	err := i.DB.QueryRowContext(ctx, "select count(*) from information_schema.routines where routine_schema = 'mysql' and routine_name in ('rds_kill', 'rds_kill_query')").Scan(&count)
+	return count == 2, err
+}
+
+// Kill kills the connection of a thread or only its running statement, with the RDS procedures when rdsKill
+func (i *Instance) Kill(ctx context.Context, id uint64, queryOnly bool, rdsKill bool) error {
+	var stmt string
+	switch {
+	case rdsKill && queryOnly:
+		stmt = fmt.Sprintf("call mysql.rds_kill_query(%d)", id)
+	case rdsKill:
+		stmt = fmt.Sprintf("call mysql.rds_kill(%d)", id)
+	case queryOnly:
+		stmt = fmt.Sprintf("kill query %d", id)
+	default:
+		stmt = fmt.Sprintf("kill %d", id)
+	}
+	_, err := i.DB.ExecContext(ctx, stmt)
+	return err
+}
+
+// ReplicationUsers returns the users holding REPLICATION SLAVE, their threads should never be killed.
+// It reads mysql.user, information_schema.user_privileges silently hides the grants of other users without SELECT on it.
+func (i *Instance) ReplicationUsers(ctx context.Context) ([]string, error) {
+	rows, err := i.DB.QueryContext(ctx, "select distinct user from mysql.user where Repl_slave_priv = 'Y'")
+	if err != nil {
+		return nil, fmt.Errorf("replication users unknown, SELECT on mysql.user needed: %w", err)
+	}
+	defer rows.Close()
+
+	var users []string
+	for rows.Next() {
+		var user string
+		if err = rows.Scan(&user); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
@@ -0,0 +1,196 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"rdsdba/pkg/engine"
+
+	gomysql "github.com/go-sql-driver/mysql"
+)
+
+const (
+	DefaultHeartbeatTable = "rdsdba_heartbeat.heartbeat"
+	// errParse is what servers before 8.0.22 answer to SHOW REPLICA STATUS
+	errParse = 1064
+)
+
+// replicaColumns maps the SHOW SLAVE STATUS column names to the SHOW REPLICA STATUS ones
+var replicaColumns = strings.NewReplacer("Master", "Source", "Slave", "Replica")
+
+// ReplicaStatus is a channel of SHOW REPLICA STATUS with the column names of 8.0.22 whatever the server version.
+// IORunning is Yes, No or Connecting, SecondsBehind is nil while the SQL thread is stopped.
+type ReplicaStatus struct {
+	Channel            string `json:"channel"`
+	SourceHost         string `json:"source_host"`
+	SourcePort         int    `json:"source_port"`
+	SourceServerID     uint64 `json:"source_server_id"`
+	IORunning          string `json:"io_running"`
+	SQLRunning         string `json:"sql_running"`
+	IOState            string `json:"io_state"`
+	SQLState           string `json:"sql_state"`
+	SecondsBehind      *int64 `json:"seconds_behind"`
+	SQLDelay           int64  `json:"sql_delay"`
+	SourceLogFile      string `json:"source_log_file"`
+	ReadSourceLogPos   uint64 `json:"read_source_log_pos"`
+	RelayLogFile       string `json:"relay_log_file"`
+	RelayLogPos        uint64 `json:"relay_log_pos"`
+	RelaySourceLogFile string `json:"relay_source_log_file"`
+	ExecSourceLogPos   uint64 `json:"exec_source_log_pos"`
+	RelayLogSpace      int64  `json:"relay_log_space"`
+	RetrievedGTIDSet   string `json:"retrieved_gtid_set"`
+	ExecutedGTIDSet    string `json:"executed_gtid_set"`
+	AutoPosition       bool   `json:"auto_position"`
+	LastIOErrno        int    `json:"last_io_errno"`
+	LastIOError        string `json:"last_io_error"`
+	LastIOErrorTime    string `json:"last_io_error_time"`
+	LastSQLErrno       int    `json:"last_sql_errno"`
+	LastSQLError       string `json:"last_sql_error"`
+	LastSQLErrorTime   string `json:"last_sql_error_time"`
+}
+
+func (r ReplicaStatus) Running() bool {
+	return r.IORunning == "Yes" && r.SQLRunning == "Yes"
+}
+
+// ReplicaStatus returns a row per replication channel, none on a server which isn't a replica.
+// SHOW REPLICA STATUS needs 8.0.22, older servers get SHOW SLAVE STATUS.
+func (i *Instance) ReplicaStatus(ctx context.Context) ([]ReplicaStatus, error) {
+	var channels []ReplicaStatus
+	err := i.retry(ctx, func() error {
+		var err error
+		channels, err = i.replicaStatus(ctx, "show replica status")
+		var mysqlErr *gomysql.MySQLError
+		if errors.As(err, &mysqlErr) && mysqlErr.Number == errParse {
+			channels, err = i.replicaStatus(ctx, "show slave status")
+		}
+		return err
+	})
+	return channels, err
+}
+
+func (i *Instance) replicaStatus(ctx context.Context, stmt string) ([]ReplicaStatus, error) {
+	rows, err := i.DB.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var channels []ReplicaStatus
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		dest := make([]interface{}, len(columns))
+		for index := range values {
+			dest[index] = &values[index]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		channels = append(channels, newReplicaStatus(columns, values))
+	}
+	return channels, rows.Err()
+}
+
+// newReplicaStatus reads a row of SHOW REPLICA STATUS or SHOW SLAVE STATUS
+func newReplicaStatus(columns []string, values []sql.NullString) ReplicaStatus {
+	status := make(map[string]sql.NullString, len(columns))
+	for index, column := range columns {
+		status[replicaColumns.Replace(column)] = values[index]
+	}
+	text := func(column string) string { return status[column].String }
+	number := func(column string) int64 {
+		n, _ := strconv.ParseInt(status[column].String, 10, 64)
+		return n
+	}
+	r := ReplicaStatus{
+		Channel:            text("Channel_Name"),
+		SourceHost:         text("Source_Host"),
+		SourcePort:         int(number("Source_Port")),
+		SourceServerID:     uint64(number("Source_Server_Id")),
+		IORunning:          text("Replica_IO_Running"),
+		SQLRunning:         text("Replica_SQL_Running"),
+		IOState:            text("Replica_IO_State"),
+		SQLState:           text("Replica_SQL_Running_State"),
+		SQLDelay:           number("SQL_Delay"),
+		SourceLogFile:      text("Source_Log_File"),
+		ReadSourceLogPos:   uint64(number("Read_Source_Log_Pos")),
+		RelayLogFile:       text("Relay_Log_File"),
+		RelayLogPos:        uint64(number("Relay_Log_Pos")),
+		RelaySourceLogFile: text("Relay_Source_Log_File"),
+		ExecSourceLogPos:   uint64(number("Exec_Source_Log_Pos")),
+		RelayLogSpace:      number("Relay_Log_Space"),
+		// the server breaks long sets over several lines
+		RetrievedGTIDSet: strings.ReplaceAll(text("Retrieved_Gtid_Set"), "\n", ""),
+		ExecutedGTIDSet:  strings.ReplaceAll(text("Executed_Gtid_Set"), "\n", ""),
+		AutoPosition:     text("Auto_Position") == "1",
+		LastIOErrno:      int(number("Last_IO_Errno")),
+		LastIOError:      text("Last_IO_Error"),
+		LastIOErrorTime:  text("Last_IO_Error_Timestamp"),
+		LastSQLErrno:     int(number("Last_SQL_Errno")),
+		LastSQLError:     text("Last_SQL_Error"),
+		LastSQLErrorTime: text("Last_SQL_Error_Timestamp"),
+	}
+	if behind := status["Seconds_Behind_Source"]; behind.Valid {
+		seconds := number("Seconds_Behind_Source")
+		r.SecondsBehind = &seconds
+	}
+	return r
+}
+
+// ServerID is @@server_id, the row the heartbeat writes
+func (i *Instance) ServerID(ctx context.Context) (uint64, error) {
+	var id uint64
+	err := i.DB.QueryRowContext(ctx, "select @@server_id").Scan(&id)
+	return id, err
+}
+
+// CreateHeartbeatTable creates the heartbeat table, a row per writing server
+func (i *Instance) CreateHeartbeatTable(ctx context.Context, table engine.Table) error {
+	return i.createTable(ctx, table, "server_id int unsigned not null primary key, ts timestamp(6) not null")
+}
+
+// WriteHeartbeat stores the current time of the writer in its row, replicas receive it through replication
+func (i *Instance) WriteHeartbeat(ctx context.Context, table engine.Table) error {
+	stmt := fmt.Sprintf("insert into %s (server_id, ts) values (@@server_id, now(6)) on duplicate key update ts = now(6)", quoteTable(table))
+	_, err := i.DB.ExecContext(ctx, stmt)
+	return err
+}
+
+// HeartbeatLag is how many seconds the replicated heartbeat of serverID is behind the replica clock,
+// nil when the row hasn't arrived yet. The lag includes the time since the last write.
+func (i *Instance) HeartbeatLag(ctx context.Context, table engine.Table, serverID uint64) (*float64, error) {
+	var lag float64
+	stmt := fmt.Sprintf("select timestampdiff(microsecond, ts, now(6)) / 1000000 from %s where server_id = ?", quoteTable(table))
+	err := i.DB.QueryRowContext(ctx, stmt, serverID).Scan(&lag)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &lag, nil
+}
+
+// GTIDState is @@server_uuid, @@gtid_mode and @@gtid_executed on one line
+type GTIDState struct {
+	ServerUUID string
+	Mode       string
+	Executed   string
+}
+
+func (i *Instance) GTIDState(ctx context.Context) (GTIDState, error) {
+	var state GTIDState
+	err := i.retry(ctx, func() error {
+		return i.DB.QueryRowContext(ctx, "select @@server_uuid, @@global.gtid_mode, @@global.gtid_executed").Scan(&state.ServerUUID, &state.Mode, &state.Executed)
+	})
+	state.Executed = strings.ReplaceAll(state.Executed, "\n", "")
+	return state, err
+}
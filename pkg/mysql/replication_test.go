@@ -0,0 +1,80 @@
+package mysql
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+)
+
+func TestNewReplicaStatus(t *testing.T) {
+	// the same channel as SHOW SLAVE STATUS of 5.7 and SHOW REPLICA STATUS of 8.0.22+, in server column order
+	mysql57 := [][2]string{
+		{"Slave_IO_State", "Waiting for master to send event"}, {"Master_Host", "10.0.1.12"}, {"Master_User", "repl"},
+		{"Master_Port", "3306"}, {"Connect_Retry", "60"}, {"Master_Log_File", "mysql-bin.000042"},
+		{"Read_Master_Log_Pos", "73912"}, {"Relay_Log_File", "relay-bin.000007"}, {"Relay_Log_Pos", "1204"},
+		{"Relay_Master_Log_File", "mysql-bin.000041"}, {"Slave_IO_Running", "Yes"}, {"Slave_SQL_Running", "No"},
+		{"Last_Errno", "1062"}, {"Last_Error", "Duplicate entry"}, {"Exec_Master_Log_Pos", "991"}, {"Relay_Log_Space", "88231"},
+		{"Seconds_Behind_Master", ""}, {"Last_IO_Errno", "0"}, {"Last_IO_Error", ""}, {"Last_SQL_Errno", "1062"},
+		{"Last_SQL_Error", "Duplicate entry"}, {"Master_Server_Id", "1001"}, {"Master_UUID", "3e11fa47-71ca-11e1-9e33-c80aa9429562"},
+		{"SQL_Delay", "3600"}, {"Slave_SQL_Running_State", ""}, {"Last_IO_Error_Timestamp", ""},
+		{"Last_SQL_Error_Timestamp", "230201 10:03:12"}, {"Retrieved_Gtid_Set", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-100,\n8f5d3c4e-71ca-11e1-9e33-c80aa9429562:1-5"},
+		{"Executed_Gtid_Set", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-90"}, {"Auto_Position", "1"}, {"Channel_Name", ""},
+	}
+	mysql80 := [][2]string{
+		{"Replica_IO_State", "Waiting for master to send event"}, {"Source_Host", "10.0.1.12"}, {"Source_User", "repl"},
+		{"Source_Port", "3306"}, {"Connect_Retry", "60"}, {"Source_Log_File", "mysql-bin.000042"},
+		{"Read_Source_Log_Pos", "73912"}, {"Relay_Log_File", "relay-bin.000007"}, {"Relay_Log_Pos", "1204"},
+		{"Relay_Source_Log_File", "mysql-bin.000041"}, {"Replica_IO_Running", "Yes"}, {"Replica_SQL_Running", "No"},
+		{"Last_Errno", "1062"}, {"Last_Error", "Duplicate entry"}, {"Exec_Source_Log_Pos", "991"}, {"Relay_Log_Space", "88231"},
+		{"Seconds_Behind_Source", ""}, {"Last_IO_Errno", "0"}, {"Last_IO_Error", ""}, {"Last_SQL_Errno", "1062"},
+		{"Last_SQL_Error", "Duplicate entry"}, {"Source_Server_Id", "1001"}, {"Source_UUID", "3e11fa47-71ca-11e1-9e33-c80aa9429562"},
+		{"SQL_Delay", "3600"}, {"Replica_SQL_Running_State", ""}, {"Last_IO_Error_Timestamp", ""},
+		{"Last_SQL_Error_Timestamp", "230201 10:03:12"}, {"Retrieved_Gtid_Set", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-100,\n8f5d3c4e-71ca-11e1-9e33-c80aa9429562:1-5"},
+		{"Executed_Gtid_Set", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-90"}, {"Auto_Position", "1"}, {"Channel_Name", ""},
+	}
+	want := ReplicaStatus{
+		SourceHost: "10.0.1.12", SourcePort: 3306, SourceServerID: 1001, IORunning: "Yes", SQLRunning: "No",
+		IOState: "Waiting for master to send event", SQLDelay: 3600, SourceLogFile: "mysql-bin.000042", ReadSourceLogPos: 73912,
+		RelayLogFile: "relay-bin.000007", RelayLogPos: 1204, RelaySourceLogFile: "mysql-bin.000041", ExecSourceLogPos: 991,
+		RelayLogSpace: 88231, RetrievedGTIDSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-100,8f5d3c4e-71ca-11e1-9e33-c80aa9429562:1-5",
+		ExecutedGTIDSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-90", AutoPosition: true,
+		LastSQLErrno: 1062, LastSQLError: "Duplicate entry", LastSQLErrorTime: "230201 10:03:12",
+	}
+
+	for name, row := range map[string][][2]string{"5.7": mysql57, "8.0.22": mysql80} {
+		columns, values := statusRow(row)
+		// the SQL thread is stopped, Seconds_Behind is NULL
+		got := newReplicaStatus(columns, values)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s:\n%+v\nwant\n%+v", name, got, want)
+		}
+		if got.Running() {
+			t.Errorf("%s: running with the SQL thread stopped", name)
+		}
+
+		for index, column := range columns {
+			if column == "Slave_SQL_Running" || column == "Replica_SQL_Running" {
+				values[index].String = "Yes"
+			}
+			if column == "Seconds_Behind_Master" || column == "Seconds_Behind_Source" {
+				values[index] = sql.NullString{String: "12", Valid: true}
+			}
+		}
+		got = newReplicaStatus(columns, values)
+		if got.SecondsBehind == nil || *got.SecondsBehind != 12 || !got.Running() {
+			t.Errorf("%s: running %v, seconds behind %v, want running 12s behind", name, got.Running(), got.SecondsBehind)
+		}
+	}
+}
+
+// statusRow splits column, value pairs, empty Seconds_Behind values are NULL
+func statusRow(row [][2]string) ([]string, []sql.NullString) {
+	columns := make([]string, len(row))
+	values := make([]sql.NullString, len(row))
+	for index, pair := range row {
+		columns[index] = pair[0]
+		null := len(pair[1]) == 0 && (pair[0] == "Seconds_Behind_Master" || pair[0] == "Seconds_Behind_Source")
+		values[index] = sql.NullString{String: pair[1], Valid: !null}
+	}
+	return columns, values
+}
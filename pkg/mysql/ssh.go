@@ -0,0 +1,217 @@
+package mysql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+	"time"
+
+	gomysql "github.com/go-sql-driver/mysql"
+	"github.com/rs/zerolog"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	sshKeepAliveInterval = 30 * time.Second
+	sshDialTimeout       = 10 * time.Second
+)
+
+var (
+	ErrSSHNoAuth = errors.New("no SSH authentication method, give --ssh-key or run ssh-agent")
+
+	tunnels   = make(map[SSHConfig]*sshTunnel)
+	tunnelsMu sync.Mutex
+)
+
+// SSHConfig makes connections go through a bastion host, Host empty means connect directly
+type SSHConfig struct {
+	Host       string
+	User       string
+	KeyFile    string
+	Agent      bool
+	KnownHosts string
+}
+
+// sshTunnel keeps one SSH client to the bastion and reconnects when it drops
+type sshTunnel struct {
+	cfg    SSHConfig
+	logger zerolog.Logger
+	mu     sync.Mutex
+	client *ssh.Client
+}
+
+// registerSSH registers a driver dialer for the bastion, instances behind the same bastion share the tunnel
+func (i *Instance) registerSSH() (string, error) {
+	cfg := i.Config.SSH
+	if len(cfg.Host) == 0 {
+		return "", nil
+	}
+
+	tunnelsMu.Lock()
+	defer tunnelsMu.Unlock()
+
+	name := sshDialerName(cfg)
+	if _, ok := tunnels[cfg]; ok {
+		return name, nil
+	}
+
+	t := &sshTunnel{cfg: cfg, logger: i.logger.With().Str("ssh_host", cfg.Host).Logger()}
+	if _, err := t.connect(); err != nil {
+		return "", err
+	}
+	tunnels[cfg] = t
+	gomysql.RegisterDialContext(name, t.DialContext)
+	return name, nil
+}
+
+// sshDialerName identifies the tunnel by every setting of cfg, bastions reached as different users or keys
+// must not share a dialer registered under the same name
+func sshDialerName(cfg SSHConfig) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s@%s\x00%s\x00%t\x00%s", cfg.User, sshAddr(cfg.Host), cfg.KeyFile, cfg.Agent, cfg.KnownHosts)))
+	return "rdsdba-ssh-" + hex.EncodeToString(sum[:8])
+}
+
+// sshAddr is host:port of the bastion, port 22 when not given
+func sshAddr(host string) string {
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		return net.JoinHostPort(host, "22")
+	}
+	return host
+}
+
+func (t *sshTunnel) DialContext(ctx context.Context, addr string) (net.Conn, error) {
+	client, err := t.connect()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := client.DialContext(ctx, "tcp", addr)
+	if err == nil {
+		return conn, nil
+	}
+
+	// tunnel may have dropped without being noticed yet, reconnect once
+	t.logger.Warn().Err(err).Msg("dial through SSH tunnel failed, reconnecting")
+	t.drop(client)
+	client, err = t.connect()
+	if err != nil {
+		return nil, err
+	}
+	return client.DialContext(ctx, "tcp", addr)
+}
+
+func (t *sshTunnel) connect() (*ssh.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.client != nil {
+		return t.client, nil
+	}
+
+	clientCfg, err := t.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	addr := sshAddr(t.cfg.Host)
+	client, err := ssh.Dial("tcp", addr, clientCfg)
+	if err != nil {
+		return nil, fmt.Errorf("ssh %s: %w", addr, err)
+	}
+	t.logger.Debug().Msg("SSH tunnel connected")
+
+	t.client = client
+	go t.keepAlive(client)
+	return client, nil
+}
+
+// drop forgets the client if it is still the current one, next dial reconnects
+func (t *sshTunnel) drop(client *ssh.Client) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.client == client {
+		t.client = nil
+	}
+	client.Close()
+}
+
+func (t *sshTunnel) keepAlive(client *ssh.Client) {
+	done := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(done)
+	}()
+
+	ticker := time.NewTicker(sshKeepAliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			t.logger.Warn().Msg("SSH tunnel closed")
+			t.drop(client)
+			return
+		case <-ticker.C:
+			if _, _, err := client.SendRequest("keepalive@openssh.com", true, nil); err != nil {
+				t.logger.Warn().Err(err).Msg("SSH keepalive failed")
+				t.drop(client)
+				return
+			}
+		}
+	}
+}
+
+func (t *sshTunnel) clientConfig() (*ssh.ClientConfig, error) {
+	cfg := t.cfg
+	sshUser := cfg.User
+	if len(sshUser) == 0 {
+		if u, err := user.Current(); err == nil {
+			sshUser = u.Username
+		}
+	}
+
+	var methods []ssh.AuthMethod
+	if len(cfg.KeyFile) > 0 {
+		key, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("parse SSH key %s, use ssh-agent for keys with passphrase: %w", cfg.KeyFile, err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+	if sock := os.Getenv("SSH_AUTH_SOCK"); len(sock) > 0 && (cfg.Agent || len(cfg.KeyFile) == 0) {
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return nil, fmt.Errorf("ssh-agent: %w", err)
+		}
+		methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+	if len(methods) == 0 {
+		return nil, ErrSSHNoAuth
+	}
+
+	knownHostsFile := cfg.KnownHosts
+	if len(knownHostsFile) == 0 {
+		home, _ := os.UserHomeDir()
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("known hosts %s, ssh to the bastion once to add its key: %w", knownHostsFile, err)
+	}
+
+	return &ssh.ClientConfig{
+		User:            sshUser,
+		Auth:            methods,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         sshDialTimeout,
+	}, nil
+}
@@ -0,0 +1,24 @@
+package mysql
+
+import "testing"
+
+func TestSSHDialerName(t *testing.T) {
+	base := SSHConfig{Host: "bastion.example.com", User: "ec2-user", KeyFile: "/home/dba/.ssh/prod.pem"}
+	name := sshDialerName(base)
+	if name != sshDialerName(SSHConfig{Host: "bastion.example.com:22", User: "ec2-user", KeyFile: "/home/dba/.ssh/prod.pem"}) {
+		t.Error("default port 22 gives another dialer")
+	}
+
+	others := []SSHConfig{
+		{Host: "bastion.example.com", User: "admin", KeyFile: base.KeyFile},
+		{Host: "bastion.example.com:2222", User: base.User, KeyFile: base.KeyFile},
+		{Host: "bastion.example.com", User: base.User, KeyFile: "/home/dba/.ssh/staging.pem"},
+		{Host: "bastion.example.com", User: base.User, KeyFile: base.KeyFile, Agent: true},
+		{Host: "bastion.example.com", User: base.User, KeyFile: base.KeyFile, KnownHosts: "/tmp/known_hosts"},
+	}
+	for _, cfg := range others {
+		if sshDialerName(cfg) == name {
+			t.Errorf("%+v shares the dialer %s of %+v", cfg, name, base)
+		}
+	}
+}
@@ -1,30 +1,125 @@
 package mysql
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
 	"strings"
+
+	"rdsdba/pkg/engine"
+
+	gomysql "github.com/go-sql-driver/mysql"
 )
 
-type Table struct {
-	SchemaName string `mapstructure:"table_schema"`
-	TableName  string `mapstructure:"table_name"`
+// ER_UNKNOWN_SYSTEM_VARIABLE
+const errUnknownSystemVariable = 1193
+
+var intTypeMax = map[string]uint64{
+	"tinyint":   math.MaxInt8,
+	"smallint":  math.MaxInt16,
+	"mediumint": 1<<23 - 1,
+	"int":       math.MaxInt32,
+	"bigint":    math.MaxInt64,
 }
 
-func TabStrToTabStruct(tablesStr []string) ([]Table, error) {
-	//"schema_name.table_name"
-	var tables []Table
-	var tableName string
-	var schemaName string
-	err := fmt.Errorf("unexpected format")
-	for _, table := range tablesStr {
-		res := strings.Split(table, ".")
-		if len(res) != 2 {
-			return nil, err
-		}
-		schemaName = strings.TrimSpace(res[0])
-		tableName = strings.TrimSpace(res[1])
-		tables = append(tables, Table{SchemaName: schemaName, TableName: tableName})
+// TableInfo is the information_schema view of a table, rows and sizes are InnoDB estimates
+type TableInfo struct {
+	engine.Table
+	Engine      string `json:"engine"`
+	RowFormat   string `json:"row_format"`
+	Rows        int64  `json:"rows"`
+	DataLength  int64  `json:"data_length"`
+	IndexLength int64  `json:"index_length"`
+	DataFree    int64  `json:"data_free"`
+	// AutoIncrement is the next value of the auto increment column, nil without one
+	AutoIncrement *uint64 `json:"auto_increment,omitempty"`
+	AutoIncColumn string  `json:"auto_increment_column,omitempty"`
+	// AutoIncType is the column type e.g. int unsigned, AutoIncMax the largest value it holds
+	AutoIncType string `json:"auto_increment_type,omitempty"`
+	AutoIncMax  uint64 `json:"auto_increment_max,omitempty"`
+	// AutoIncUsed is the ratio(0-1) of the column range already used
+	AutoIncUsed float64 `json:"auto_increment_used,omitempty"`
+	Partitions  int     `json:"partitions"`
+	CreateTime  string  `json:"create_time"`
+	UpdateTime  string  `json:"update_time,omitempty"`
+}
+
+func (t TableInfo) TotalLength() int64 {
+	return t.DataLength + t.IndexLength
+}
+
+// IntTypeMax returns the largest value of an integer data type, 0 for other types
+func IntTypeMax(dataType string, unsigned bool) uint64 {
+	max, ok := intTypeMax[strings.ToLower(dataType)]
+	if !ok {
+		return 0
 	}
+	if unsigned {
+		return max*2 + 1
+	}
+	return max
+}
 
+// TableInfos returns size, format and auto increment details of every user table.
+// MySQL 8.0 caches these statistics for information_schema_stats_expiry, the cache is bypassed.
+func (i *Instance) TableInfos(ctx context.Context) ([]TableInfo, error) {
+	stmt := fmt.Sprintf(`select t.table_schema, t.table_name, coalesce(t.engine, ''), coalesce(t.row_format, ''),
+		coalesce(t.table_rows, 0), coalesce(t.data_length, 0), coalesce(t.index_length, 0), coalesce(t.data_free, 0),
+		t.auto_increment, coalesce(c.column_name, ''), coalesce(c.data_type, ''), coalesce(c.column_type, ''),
+		coalesce(p.partitions, 0), coalesce(t.create_time, ''), coalesce(t.update_time, '')
+	from information_schema.tables t
+	left join information_schema.columns c
+		on c.table_schema = t.table_schema and c.table_name = t.table_name and c.extra like '%%auto_increment%%'
+	left join (
+		select table_schema, table_name, count(*) partitions from information_schema.partitions
+		where partition_name is not null group by table_schema, table_name
+	) p on p.table_schema = t.table_schema and p.table_name = t.table_name
+	where t.table_schema not in (%s) and t.table_type = 'BASE TABLE'`, SystemSchema)
+
+	var tables []TableInfo
+	err := i.retry(ctx, func() error {
+		conn, err := i.DB.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		_, err = conn.ExecContext(ctx, "set session information_schema_stats_expiry = 0")
+		var mysqlErr *gomysql.MySQLError
+		if err != nil && !(errors.As(err, &mysqlErr) && mysqlErr.Number == errUnknownSystemVariable) {
+			return err
+		}
+
+		rows, err := conn.QueryContext(ctx, stmt)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		tables = tables[:0]
+		for rows.Next() {
+			var t TableInfo
+			var dataType string
+			err = rows.Scan(&t.SchemaName, &t.TableName, &t.Engine, &t.RowFormat,
+				&t.Rows, &t.DataLength, &t.IndexLength, &t.DataFree,
+				&t.AutoIncrement, &t.AutoIncColumn, &dataType, &t.AutoIncType,
+				&t.Partitions, &t.CreateTime, &t.UpdateTime)
+			if err != nil {
+				return err
+			}
+			if t.AutoIncrement != nil && len(t.AutoIncColumn) > 0 {
+				t.AutoIncMax = IntTypeMax(dataType, strings.Contains(t.AutoIncType, "unsigned"))
+			}
+			if t.AutoIncMax > 0 && *t.AutoIncrement > 0 {
+				t.AutoIncUsed = float64(*t.AutoIncrement-1) / float64(t.AutoIncMax)
+			}
+			tables = append(tables, t)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
 	return tables, nil
 }
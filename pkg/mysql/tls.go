@@ -0,0 +1,159 @@
+package mysql
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	gomysql "github.com/go-sql-driver/mysql"
+)
+
+//go:generate curl -sSfL -o certs/rds-global-bundle.pem https://truststore.pki.rds.amazonaws.com/global/global-bundle.pem
+
+const (
+	SSLDisabled       = "disabled"
+	SSLPreferred      = "preferred"
+	SSLRequired       = "required"
+	SSLVerifyCA       = "verify-ca"
+	SSLVerifyIdentity = "verify-identity"
+
+	// RDSCABundle as ssl-ca uses the built-in RDS global CA bundle
+	RDSCABundle = "rds"
+)
+
+var (
+	//go:embed certs/rds-global-bundle.pem
+	rdsGlobalBundle []byte
+
+	ErrUnknownSSLMode = errors.New("unknown ssl mode")
+	ErrNoCertificates = errors.New("no certificates found")
+)
+
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "TLSv1",
+	tls.VersionTLS11: "TLSv1.1",
+	tls.VersionTLS12: "TLSv1.2",
+	tls.VersionTLS13: "TLSv1.3",
+}
+
+type TLSConfig struct {
+	Mode string
+	CA   string
+	Cert string
+	Key  string
+}
+
+// NormalizeSSLMode accepts the mysql client spelling too, e.g. VERIFY_IDENTITY
+func NormalizeSSLMode(mode string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(mode)), "_", "-")
+}
+
+// registerTLS registers the tls.Config matching the ssl mode with the driver,
+// returns the name to use as TLSConfig in DSN, empty when TLS is disabled
+func (i *Instance) registerTLS() (string, error) {
+	cfg := i.Config.TLS
+	mode := NormalizeSSLMode(cfg.Mode)
+	if len(mode) == 0 {
+		mode = SSLPreferred
+	}
+	if i.Config.IAM.Enabled {
+		switch mode {
+		case SSLDisabled:
+			return "", ErrIAMNeedsTLS
+		case SSLPreferred:
+			mode = SSLRequired
+		}
+	}
+	// like mysql client, required with a CA verifies the CA
+	if mode == SSLRequired && len(cfg.CA) > 0 {
+		mode = SSLVerifyCA
+	}
+
+	tlsCfg := &tls.Config{VerifyConnection: func(cs tls.ConnectionState) error {
+		i.logger.Debug().Str("tls_version", tlsVersions[cs.Version]).Str("tls_cipher", tls.CipherSuiteName(cs.CipherSuite)).Msg("TLS negotiated")
+		return nil
+	}}
+
+	if len(cfg.CA) > 0 {
+		pool, err := loadCA(cfg.CA)
+		if err != nil {
+			return "", err
+		}
+		tlsCfg.RootCAs = pool
+	}
+	if len(cfg.Cert) > 0 || len(cfg.Key) > 0 {
+		cert, err := tls.LoadX509KeyPair(cfg.Cert, cfg.Key)
+		if err != nil {
+			return "", err
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	switch mode {
+	case SSLDisabled:
+		return "false", nil
+	case SSLPreferred, SSLRequired:
+		tlsCfg.InsecureSkipVerify = true
+	case SSLVerifyCA:
+		// verify the chain but not the host name, tls.Config can't do it by itself
+		tlsCfg.InsecureSkipVerify = true
+		tlsCfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyChain(rawCerts, tlsCfg.RootCAs)
+		}
+	case SSLVerifyIdentity:
+		tlsCfg.ServerName = i.Config.DSN.Host
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownSSLMode, cfg.Mode)
+	}
+
+	name := fmt.Sprintf("rdsdba-%s-%d-%s", i.Config.DSN.Host, i.Config.DSN.Port, mode)
+	if err := gomysql.RegisterTLSConfig(name, tlsCfg); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+func loadCA(ca string) (*x509.CertPool, error) {
+	pem := rdsGlobalBundle
+	if ca != RDSCABundle {
+		var err error
+		pem, err = os.ReadFile(ca)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		if ca == RDSCABundle {
+			return nil, fmt.Errorf("%w in built-in RDS CA bundle, run go generate ./pkg/mysql before building", ErrNoCertificates)
+		}
+		return nil, fmt.Errorf("%w in %s", ErrNoCertificates, ca)
+	}
+	return pool, nil
+}
+
+func verifyChain(rawCerts [][]byte, roots *x509.CertPool) error {
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return ErrNoCertificates
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+	return err
+}
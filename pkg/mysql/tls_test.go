@@ -0,0 +1,32 @@
+package mysql
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+)
+
+func TestRDSGlobalBundle(t *testing.T) {
+	rest := rdsGlobalBundle
+	certs := 0
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			t.Fatalf("certificate %d: %s", certs+1, err)
+		}
+		certs++
+	}
+	if certs == 0 {
+		t.Fatal("built-in RDS CA bundle has no certificate, run go generate ./pkg/mysql")
+	}
+	if _, err := loadCA(RDSCABundle); err != nil {
+		t.Fatal(err)
+	}
+}
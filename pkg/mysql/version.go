@@ -0,0 +1,91 @@
+package mysql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	gomysql "github.com/go-sql-driver/mysql"
+)
+
+// auroraPatch is the lowest community patch release an Aurora major version is compatible with,
+// Aurora 3 reports @@version as 8.0.mysql_aurora.3.04.0
+var auroraPatch = map[int]int{8: 23, 5: 12}
+
+// ServerVersion is the numeric part of @@version, e.g. 8.0.32 of 8.0.32-log
+type ServerVersion struct {
+	Major int
+	Minor int
+	Patch int
+	// Full is the whole @@version
+	Full string
+}
+
+func (v ServerVersion) String() string {
+	return v.Full
+}
+
+// AtLeast compares with major.minor.patch
+func (v ServerVersion) AtLeast(major, minor, patch int) bool {
+	if v.Major != major {
+		return v.Major > major
+	}
+	if v.Minor != minor {
+		return v.Minor > minor
+	}
+	return v.Patch >= patch
+}
+
+func ParseServerVersion(version string) (ServerVersion, error) {
+	v := ServerVersion{Full: version}
+	numbers, _, _ := strings.Cut(version, "-")
+	parts := strings.SplitN(numbers, ".", 3)
+	if len(parts) < 2 {
+		return v, fmt.Errorf("unexpected server version %s", version)
+	}
+	var err error
+	if v.Major, err = strconv.Atoi(parts[0]); err != nil {
+		return v, fmt.Errorf("unexpected server version %s", version)
+	}
+	if v.Minor, err = strconv.Atoi(parts[1]); err != nil {
+		return v, fmt.Errorf("unexpected server version %s", version)
+	}
+	if len(parts) == 3 {
+		if strings.HasPrefix(parts[2], "mysql_aurora") {
+			v.Patch = auroraPatch[v.Major]
+			return v, nil
+		}
+		v.Patch, _ = strconv.Atoi(parts[2])
+	}
+	return v, nil
+}
+
+func (i *Instance) Version(ctx context.Context) (ServerVersion, error) {
+	var version string
+	if err := i.DB.QueryRowContext(ctx, "select @@version").Scan(&version); err != nil {
+		return ServerVersion{}, err
+	}
+	return ParseServerVersion(version)
+}
+
+// InnoDBStatus returns the text of SHOW ENGINE INNODB STATUS, it needs the PROCESS privilege
+func (i *Instance) InnoDBStatus(ctx context.Context) (string, error) {
+	var typ, name, status string
+	err := i.retry(ctx, func() error {
+		return i.DB.QueryRowContext(ctx, "show engine innodb status").Scan(&typ, &name, &status)
+	})
+	return status, err
+}
+
+// RedoLogCapacity is innodb_redo_log_capacity from 8.0.30, innodb_log_file_size * innodb_log_files_in_group before
+func (i *Instance) RedoLogCapacity(ctx context.Context) (uint64, error) {
+	var capacity uint64
+	err := i.DB.QueryRowContext(ctx, "select @@innodb_redo_log_capacity").Scan(&capacity)
+	var mysqlErr *gomysql.MySQLError
+	if errors.As(err, &mysqlErr) && mysqlErr.Number == errUnknownSystemVariable {
+		err = i.DB.QueryRowContext(ctx, "select @@innodb_log_file_size * @@innodb_log_files_in_group").Scan(&capacity)
+	}
+	return capacity, err
+}
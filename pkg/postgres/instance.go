@@ -0,0 +1,358 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"rdsdba/pkg/engine"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DefaultPort     = 5432
+	DefaultDatabase = "postgres"
+	pingTimeout     = 5 * time.Second
+	connTimeout     = 10 * time.Second
+	// SQLSTATE invalid_password
+	errInvalidPassword = "28P01"
+)
+
+var (
+	// WarmupSessionVars let full scans of big tables run without timeout
+	WarmupSessionVars = map[string]string{
+		"statement_timeout": "0",
+	}
+
+	ErrDBInitFailed = errors.New("connection initialise failed")
+)
+
+// Config of a PostgreSQL endpoint, SSLMode and the file fields are libpq sslmode, sslrootcert, sslcert and sslkey
+type Config struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifeTime time.Duration
+	// Logger is the base logger of the instance, the global logger when nil
+	Logger *zerolog.Logger
+	// Password overrides DSN.Passwd when set, DSN.Passwd and Password are never logged
+	Password engine.PasswordProvider
+	SSLMode  string
+	SSLCA    string
+	SSLCert  string
+	SSLKey   string
+	// RawDSN replaces the connection string built from DSN fields, a libpq URL or keyword/value string
+	RawDSN string
+	// SessionVars are run-time parameters set on every new connection, Params are extra connection string keywords
+	SessionVars map[string]string
+	Params      map[string]string
+	DSN         struct {
+		Host     string
+		Port     int
+		User     string
+		Passwd   string
+		Database string
+	}
+}
+
+type Instance struct {
+	Config Config
+	logger zerolog.Logger
+	DB     *sql.DB
+	// prewarm is true when the pg_prewarm extension is installed in the database
+	prewarm bool
+}
+
+func NewInstance(config Config) (*Instance, error) {
+	base := log.Logger
+	if config.Logger != nil {
+		base = *config.Logger
+	}
+	logger := base.With().
+		Str("user", config.DSN.User).
+		Str("host", config.DSN.Host).
+		Int("port", config.DSN.Port).
+		Logger()
+
+	i := &Instance{
+		Config: config,
+		logger: logger,
+	}
+
+	db, err := i.Open()
+	if err != nil {
+		logger.Error().Err(err).Msg("")
+		return nil, ErrDBInitFailed
+	}
+	i.DB = db
+
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	err = db.QueryRowContext(ctx, "select exists(select 1 from pg_extension where extname = 'pg_prewarm')").Scan(&i.prewarm)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if !i.prewarm {
+		logger.Warn().Msg("pg_prewarm extension not installed, warmup falls back to sequential scans which mostly fill the OS page cache, run 'create extension pg_prewarm' to load shared buffers")
+	}
+	return i, nil
+}
+
+// Close closes the connection pool
+func (i *Instance) Close() error {
+	return i.DB.Close()
+}
+
+func (i *Instance) Open() (*sql.DB, error) {
+	cfg := i.Config
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+
+	connConfig, err := pgx.ParseConfig(i.connString())
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range cfg.SessionVars {
+		connConfig.RuntimeParams[name] = value
+	}
+	i.logger.Debug().Str("dsn", redactConnString(connConfig)).Msg("open")
+
+	db := sql.OpenDB(&connector{
+		Connector: stdlib.GetConnector(*connConfig, stdlib.OptionBeforeConnect(i.beforeConnect)),
+		instance:  i,
+	})
+	db.SetConnMaxLifetime(cfg.ConnMaxLifeTime)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+
+	if err = db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func (i *Instance) Logger() zerolog.Logger {
+	return i.logger
+}
+
+// WarmUp loads the table and its indexes into shared buffers with pg_prewarm,
+// without it a sequential scan reads the table, big tables then go through a small ring buffer and mostly stay in the OS page cache
+func (i *Instance) WarmUp(ctx context.Context, table engine.Table) error {
+	ident := pgx.Identifier{table.SchemaName, table.TableName}.Sanitize()
+
+	if i.prewarm {
+		stmt := "select pg_prewarm(c.oid) from pg_class c where c.oid = $1::regclass or c.oid in (select indexrelid from pg_index where indrelid = $1::regclass)"
+		if _, err := i.DB.ExecContext(ctx, stmt, ident); err != nil {
+			return err
+		}
+	} else if err := i.seqScan(ctx, ident); err != nil {
+		return err
+	}
+
+	_, err := i.DB.ExecContext(ctx, "analyze "+ident)
+	return err
+}
+
+// seqScan counts rows with index scans disabled so the heap is read
+func (i *Instance) seqScan(ctx context.Context, ident string) error {
+	tx, err := i.DB.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmts := []string{
+		"set local enable_indexscan = off",
+		"set local enable_indexonlyscan = off",
+		"set local enable_bitmapscan = off",
+		"select count(*) from " + ident,
+	}
+	for _, stmt := range stmts {
+		if _, err = tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (i *Instance) GetUserTables(ctx context.Context) ([]engine.Table, error) {
+	stmt := "select schemaname, tablename from pg_catalog.pg_tables where schemaname not in ('pg_catalog', 'information_schema') and schemaname not like 'pg\\_toast%' order by 1, 2"
+	rows, err := i.DB.QueryContext(ctx, stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []engine.Table
+	for rows.Next() {
+		var table engine.Table
+		if err = rows.Scan(&table.SchemaName, &table.TableName); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+// Stress runs the query and returns its response time in ms
+func (i *Instance) Stress(ctx context.Context, query string) (int64, error) {
+	start := time.Now()
+	rows, err := i.DB.QueryContext(ctx, query)
+	duration := time.Since(start).Milliseconds()
+	if err == nil {
+		for rows.Next() {
+		}
+		// a statement timeout or a lost connection while reading the result ends Next early
+		err = rows.Err()
+		if closeErr := rows.Close(); err == nil {
+			err = closeErr
+		}
+	}
+
+	if err != nil {
+		if !errors.Is(err, context.DeadlineExceeded) {
+			i.logger.Error().Err(err).Msg("")
+		}
+		return 0, err
+	}
+	return duration, nil
+}
+
+// ReadOnly reports whether the instance is a standby
+func (i *Instance) ReadOnly(ctx context.Context) (bool, error) {
+	var inRecovery bool
+	err := i.DB.QueryRowContext(ctx, "select pg_is_in_recovery()").Scan(&inRecovery)
+	return inRecovery, err
+}
+
+// Outages is always empty, statements are not retried on connection errors
+func (i *Instance) Outages() []engine.Outage {
+	return nil
+}
+
+// connString builds a libpq keyword/value connection string, the password is only set when connecting
+func (i *Instance) connString() string {
+	cfg := i.Config
+	if len(cfg.RawDSN) > 0 {
+		if len(cfg.Params) == 0 {
+			return cfg.RawDSN
+		}
+		if !strings.HasPrefix(cfg.RawDSN, "postgres://") && !strings.HasPrefix(cfg.RawDSN, "postgresql://") {
+			return cfg.RawDSN + " " + keywordValues(cfg.Params)
+		}
+		values := url.Values{}
+		for name, value := range cfg.Params {
+			values.Set(name, value)
+		}
+		sep := "?"
+		if strings.Contains(cfg.RawDSN, "?") {
+			sep = "&"
+		}
+		return cfg.RawDSN + sep + values.Encode()
+	}
+
+	database := cfg.DSN.Database
+	if len(database) == 0 {
+		database = DefaultDatabase
+	}
+	params := map[string]string{
+		"host":             cfg.DSN.Host,
+		"port":             strconv.Itoa(cfg.DSN.Port),
+		"user":             cfg.DSN.User,
+		"dbname":           database,
+		"connect_timeout":  strconv.Itoa(int(connTimeout.Seconds())),
+		"application_name": "rdsdba",
+	}
+	optional := map[string]string{
+		"sslmode":     cfg.SSLMode,
+		"sslrootcert": cfg.SSLCA,
+		"sslcert":     cfg.SSLCert,
+		"sslkey":      cfg.SSLKey,
+	}
+	for name, value := range optional {
+		if len(value) > 0 {
+			params[name] = value
+		}
+	}
+	for name, value := range cfg.Params {
+		params[name] = value
+	}
+	return keywordValues(params)
+}
+
+// beforeConnect sets the password of every new connection, a password in the raw DSN wins
+func (i *Instance) beforeConnect(ctx context.Context, connConfig *pgx.ConnConfig) error {
+	if len(connConfig.Password) > 0 {
+		return nil
+	}
+	connConfig.Password = i.Config.DSN.Passwd
+	if i.Config.Password == nil {
+		return nil
+	}
+	password, err := i.Config.Password.Password(ctx)
+	if err != nil {
+		return err
+	}
+	connConfig.Password = password
+	return nil
+}
+
+// connector reloads the password and connects again once when the server refuses it
+type connector struct {
+	driver.Connector
+	instance *Instance
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if isInvalidPassword(err) && c.instance.Config.Password != nil {
+		// password may have been rotated, reload it once
+		c.instance.logger.Warn().Msg("access denied, reloading password")
+		c.instance.Config.Password.Invalidate()
+		conn, err = c.Connector.Connect(ctx)
+	}
+	return conn, err
+}
+
+func isInvalidPassword(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == errInvalidPassword
+}
+
+// keywordValues formats params sorted by name, values are quoted as libpq expects
+func keywordValues(params map[string]string) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	quoter := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s='%s'", name, quoter.Replace(params[name])))
+	}
+	return strings.Join(parts, " ")
+}
+
+// redactConnString formats the parsed connection for logging, the password is never included
+func redactConnString(c *pgx.ConnConfig) string {
+	tls := "disabled"
+	if c.TLSConfig != nil {
+		tls = "enabled"
+	}
+	return fmt.Sprintf("postgres://%s@%s/%s?tls=%s", c.User, c.Host+":"+strconv.Itoa(int(c.Port)), c.Database, tls)
+}
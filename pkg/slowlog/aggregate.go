@@ -0,0 +1,156 @@
+package slowlog
+
+import (
+	"math"
+	"sort"
+)
+
+// histogram buckets grow by 5%, percentiles are within 5% of the exact value while memory stays bounded on any log size
+const (
+	bucketGrowth = 1.05
+	bucketMin    = 1e-6
+)
+
+// Stats of a metric over the queries of a class
+type Stats struct {
+	Total float64 `json:"total"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Avg   float64 `json:"avg"`
+	P95   float64 `json:"p95"`
+
+	count   uint64
+	buckets map[int]uint64
+}
+
+func (s *Stats) add(value float64) {
+	if s.count == 0 || value < s.Min {
+		s.Min = value
+	}
+	if value > s.Max {
+		s.Max = value
+	}
+	s.count++
+	s.Total += value
+	if s.buckets == nil {
+		s.buckets = make(map[int]uint64)
+	}
+	s.buckets[bucket(value)]++
+}
+
+// finish computes the average and the 95th percentile
+func (s *Stats) finish() {
+	if s.count == 0 {
+		return
+	}
+	s.Avg = s.Total / float64(s.count)
+
+	keys := make([]int, 0, len(s.buckets))
+	for key := range s.buckets {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	rank := uint64(math.Ceil(float64(s.count) * 0.95))
+	var seen uint64
+	for _, key := range keys {
+		seen += s.buckets[key]
+		if seen >= rank {
+			s.P95 = math.Min(bucketUpper(key), s.Max)
+			break
+		}
+	}
+}
+
+// bucket is the histogram bucket of value, values below bucketMin share bucket 0
+func bucket(value float64) int {
+	if value <= bucketMin {
+		return 0
+	}
+	return int(math.Ceil(math.Log(value/bucketMin) / math.Log(bucketGrowth)))
+}
+
+func bucketUpper(key int) float64 {
+	return bucketMin * math.Pow(bucketGrowth, float64(key))
+}
+
+// Class is the queries sharing a fingerprint, Sample is the slowest of them
+type Class struct {
+	ID           string   `json:"id"`
+	Fingerprint  string   `json:"fingerprint"`
+	Count        uint64   `json:"count"`
+	QueryTime    Stats    `json:"query_time"`
+	LockTime     Stats    `json:"lock_time"`
+	RowsSent     Stats    `json:"rows_sent"`
+	RowsExamined Stats    `json:"rows_examined"`
+	Schemas      []string `json:"schemas"`
+	FirstSeen    string   `json:"first_seen"`
+	LastSeen     string   `json:"last_seen"`
+	Sample       Entry    `json:"sample"`
+	// Share is the part of the total query time of the log spent on this class
+	Share float64 `json:"share"`
+}
+
+// Aggregator groups entries by fingerprint
+type Aggregator struct {
+	classes   map[string]*Class
+	schemas   map[string]map[string]bool
+	Entries   uint64
+	QueryTime float64
+}
+
+func NewAggregator() *Aggregator {
+	return &Aggregator{classes: make(map[string]*Class), schemas: make(map[string]map[string]bool)}
+}
+
+func (a *Aggregator) Add(e *Entry) {
+	fingerprint := Fingerprint(e.Query)
+	c, ok := a.classes[fingerprint]
+	if !ok {
+		c = &Class{ID: ID(fingerprint), Fingerprint: fingerprint, FirstSeen: e.Time, LastSeen: e.Time, Sample: *e}
+		a.classes[fingerprint] = c
+		a.schemas[fingerprint] = make(map[string]bool)
+	}
+	a.Entries++
+	a.QueryTime += e.QueryTime
+
+	c.Count++
+	c.QueryTime.add(e.QueryTime)
+	c.LockTime.add(e.LockTime)
+	c.RowsSent.add(float64(e.RowsSent))
+	c.RowsExamined.add(float64(e.RowsExamined))
+	if len(e.Time) > 0 && (len(c.FirstSeen) == 0 || e.Time < c.FirstSeen) {
+		c.FirstSeen = e.Time
+	}
+	if e.Time > c.LastSeen {
+		c.LastSeen = e.Time
+	}
+	if e.QueryTime > c.Sample.QueryTime {
+		c.Sample = *e
+	}
+	if len(e.Schema) > 0 && !a.schemas[fingerprint][e.Schema] {
+		a.schemas[fingerprint][e.Schema] = true
+		c.Schemas = append(c.Schemas, e.Schema)
+	}
+}
+
+// Classes returns the query classes with their stats, ordered by total query time
+func (a *Aggregator) Classes() []*Class {
+	classes := make([]*Class, 0, len(a.classes))
+	for _, c := range a.classes {
+		c.QueryTime.finish()
+		c.LockTime.finish()
+		c.RowsSent.finish()
+		c.RowsExamined.finish()
+		if a.QueryTime > 0 {
+			c.Share = c.QueryTime.Total / a.QueryTime
+		}
+		classes = append(classes, c)
+	}
+	sort.SliceStable(classes, func(x, y int) bool {
+		if classes[x].QueryTime.Total != classes[y].QueryTime.Total {
+			return classes[x].QueryTime.Total > classes[y].QueryTime.Total
+		}
+		return classes[x].Fingerprint < classes[y].Fingerprint
+	})
+	return classes
+}
@@ -0,0 +1,171 @@
+package slowlog
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+// whitespace around operators and punctuation is dropped so a=1 and a = 1 fingerprint the same,
+// glueLeft drops it before the byte and glueRight after
+const (
+	glueLeft  = "=<>!,().+-/%&|^~:;"
+	glueRight = "=<>!,(.+-/%&|^~:"
+)
+
+var (
+	inListRe = regexp.MustCompile(`\bin ?\( ?\?(?: ?, ?\?)* ?\)`)
+	valuesRe = regexp.MustCompile(`\bvalues ?\( ?[?n][^()]*\)(?: ?, ?\( ?[?n][^()]*\))*`)
+	// onlyLiteralsRe is a values tuple made of placeholders and nulls only
+	onlyLiteralsRe = regexp.MustCompile(`^\( ?(?:\?|null)(?: ?, ?(?:\?|null))* ?\)$`)
+)
+
+// Fingerprint is the query with literals replaced by ?, IN lists collapsed to in(?+), multi row VALUES to values(?+),
+// comments removed, whitespace collapsed and lower cased, so queries differing only by their values group together
+func Fingerprint(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	// space is whitespace seen since the last token, glue that the last token drops the whitespace after it
+	space, glue := false, true
+	emit := func(s string) {
+		if space && !glue && !strings.Contains(glueLeft, s[:1]) {
+			b.WriteByte(' ')
+		}
+		space, glue = false, len(s) == 1 && strings.Contains(glueRight, s)
+		b.WriteString(s)
+	}
+
+	for index := 0; index < len(query); index++ {
+		c := query[index]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+		case c == '/' && index+1 < len(query) && query[index+1] == '*':
+			end := strings.Index(query[index+2:], "*/")
+			if end < 0 {
+				index = len(query)
+			} else {
+				index += end + 3
+			}
+			space = true
+		case c == '#' || c == '-' && index+2 < len(query) && query[index+1] == '-' && (query[index+2] == ' ' || query[index+2] == '\t'):
+			end := strings.IndexByte(query[index:], '\n')
+			if end < 0 {
+				index = len(query)
+			} else {
+				index += end
+			}
+			space = true
+		case c == '\'' || c == '"':
+			index = skipString(query, index)
+			emit("?")
+		case c == '`':
+			end := strings.IndexByte(query[index+1:], '`')
+			if end < 0 {
+				emit(strings.ToLower(query[index:]))
+				index = len(query)
+			} else {
+				emit(strings.ToLower(query[index : index+end+2]))
+				index += end + 1
+			}
+		case isDigit(c):
+			index = skipNumber(query, index)
+			emit("?")
+		case isIdentByte(c):
+			start := index
+			for index+1 < len(query) && isIdentByte(query[index+1]) {
+				index++
+			}
+			emit(strings.ToLower(query[start : index+1]))
+		default:
+			emit(query[index : index+1])
+		}
+	}
+
+	fingerprint := strings.TrimRight(b.String(), "; ")
+	fingerprint = inListRe.ReplaceAllString(fingerprint, "in(?+)")
+	fingerprint = valuesRe.ReplaceAllStringFunc(fingerprint, collapseValues)
+	return fingerprint
+}
+
+// ID is a short checksum of the fingerprint to refer to a query class
+func ID(fingerprint string) string {
+	sum := md5.Sum([]byte(fingerprint))
+	return strings.ToUpper(hex.EncodeToString(sum[8:]))
+}
+
+// collapseValues turns values(?, ?), (?, ?) into values(?+) when the tuples hold literals only
+func collapseValues(values string) string {
+	tuples := values[strings.IndexByte(values, '('):]
+	depth, start := 0, 0
+	for index := 0; index < len(tuples); index++ {
+		switch tuples[index] {
+		case '(':
+			if depth == 0 {
+				start = index
+			}
+			depth++
+		case ')':
+			depth--
+			if depth == 0 && !onlyLiteralsRe.MatchString(tuples[start:index+1]) {
+				return values
+			}
+		}
+	}
+	return "values(?+)"
+}
+
+// skipString returns the index of the closing quote, backslash escapes and doubled quotes are part of the string
+func skipString(query string, index int) int {
+	quote := query[index]
+	for index++; index < len(query); index++ {
+		switch query[index] {
+		case '\\':
+			index++
+		case quote:
+			if index+1 < len(query) && query[index+1] == quote {
+				index++
+				continue
+			}
+			return index
+		}
+	}
+	return len(query)
+}
+
+// skipNumber returns the index of the last byte of a decimal, float or 0x hex literal
+func skipNumber(query string, index int) int {
+	if query[index] == '0' && index+1 < len(query) && (query[index+1] == 'x' || query[index+1] == 'X') {
+		index++
+		for index+1 < len(query) && isHexDigit(query[index+1]) {
+			index++
+		}
+		return index
+	}
+	for index+1 < len(query) {
+		next := query[index+1]
+		if isDigit(next) || next == '.' {
+			index++
+			continue
+		}
+		if (next == 'e' || next == 'E') && index+2 < len(query) && (isDigit(query[index+2]) || query[index+2] == '-' || query[index+2] == '+') {
+			index += 2
+			continue
+		}
+		break
+	}
+	return index
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func isIdentByte(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '$' || c >= 0x80
+}
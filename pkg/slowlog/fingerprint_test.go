@@ -0,0 +1,36 @@
+package slowlog
+
+import (
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT * FROM t WHERE id = 42", "select * from t where id=?"},
+		{"select *\n  from t\n where id=7;", "select * from t where id=?"},
+		{"select * from t where name = 'O''Brien' and note = \"a \\\" b\"", "select * from t where name=? and note=?"},
+		{"select * from t where id in (1, 2, 3)", "select * from t where id in(?+)"},
+		{"select * from t where id IN('a')", "select * from t where id in(?+)"},
+		{"select * from t where id in (select id from u)", "select * from t where id in(select id from u)"},
+		{"insert into t (a, b) values (1, 'x'), (2, NULL)", "insert into t(a,b) values(?+)"},
+		{"insert into t values (1, now())", "insert into t values(?,now())"},
+		{"select a from t1 where b > -1.5e3 and c = 0xFF", "select a from t1 where b>-? and c=?"},
+		{"select /* app:checkout */ a from `Orders` -- trailing\nwhere x = 1", "select a from `orders` where x=?"},
+		{"select a from t # comment\nlimit 10", "select a from t limit ?"},
+	}
+	for _, test := range tests {
+		if got := Fingerprint(test.query); got != test.want {
+			t.Errorf("Fingerprint(%q) = %q, want %q", test.query, got, test.want)
+		}
+	}
+}
+
+func TestID(t *testing.T) {
+	id := ID("select * from t where id=?")
+	if len(id) != 16 || id != ID("select * from t where id=?") || id == ID("select * from u where id=?") {
+		t.Errorf("id = %s", id)
+	}
+}
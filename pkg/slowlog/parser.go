@@ -0,0 +1,248 @@
+package slowlog
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrBadTableHeader = errors.New("mysql.slow_log output needs the column names line, export it with mysql --batch")
+
+	attrRe     = regexp.MustCompile(`(\w+): +(\S+)`)
+	userHostRe = regexp.MustCompile(`^([^\[\s]*)\[[^\]]*\]\s*@\s*(\S*)\s*\[([^\]]*)\](?:\s+Id:\s*(\d+))?`)
+	useRe      = regexp.MustCompile("(?i)^use\\s+`?([^`;\\s]+)`?\\s*;\\s*$")
+	setTsRe    = regexp.MustCompile(`(?i)^SET\s+timestamp\s*=\s*\d+\s*;\s*$`)
+)
+
+// Entry is a slow query, times are seconds
+type Entry struct {
+	Time         string  `json:"time"`
+	User         string  `json:"user"`
+	Host         string  `json:"host"`
+	ThreadID     uint64  `json:"thread_id"`
+	Schema       string  `json:"schema"`
+	QueryTime    float64 `json:"query_time"`
+	LockTime     float64 `json:"lock_time"`
+	RowsSent     uint64  `json:"rows_sent"`
+	RowsExamined uint64  `json:"rows_examined"`
+	Query        string  `json:"query"`
+}
+
+// Parser reads slow log entries one at a time so files of any size stream through. It reads the slow log file format,
+// RDS downloaded log files included, and mysql.slow_log rows exported with mysql --batch, tab separated with the column names line.
+type Parser struct {
+	reader *bufio.Reader
+	// next is the entry being read from the file format
+	next    *Entry
+	query   []string
+	schema  string
+	time    string
+	table   bool
+	columns map[string]int
+	started bool
+}
+
+func NewParser(r io.Reader) *Parser {
+	return &Parser{reader: bufio.NewReaderSize(r, 1<<20)}
+}
+
+// Next returns the next entry, io.EOF after the last one
+func (p *Parser) Next() (*Entry, error) {
+	for {
+		line, err := p.reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if len(line) == 0 && err == io.EOF {
+			if p.table {
+				return nil, io.EOF
+			}
+			if e := p.flush(); e != nil {
+				return e, nil
+			}
+			return nil, io.EOF
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if !p.started {
+			if len(strings.TrimSpace(line)) == 0 {
+				continue
+			}
+			p.started = true
+			if strings.HasPrefix(line, "start_time\t") {
+				p.table = true
+				p.columns = make(map[string]int)
+				for index, name := range strings.Split(line, "\t") {
+					p.columns[name] = index
+				}
+				if _, ok := p.columns["sql_text"]; !ok {
+					return nil, ErrBadTableHeader
+				}
+				continue
+			}
+		}
+
+		if p.table {
+			if len(line) == 0 {
+				continue
+			}
+			return p.tableEntry(line)
+		}
+		if e := p.fileLine(line); e != nil {
+			return e, nil
+		}
+	}
+}
+
+// fileLine reads a line of the file format, it returns the previous entry once a line of the next one is read
+func (p *Parser) fileLine(line string) *Entry {
+	switch {
+	case strings.HasPrefix(line, "# Time:"):
+		done := p.flush()
+		p.time = strings.TrimSpace(strings.TrimPrefix(line, "# Time:"))
+		return done
+	case strings.HasPrefix(line, "# User@Host:"):
+		done := p.flush()
+		p.next = &Entry{Time: p.time, Schema: p.schema}
+		if m := userHostRe.FindStringSubmatch(strings.TrimSpace(strings.TrimPrefix(line, "# User@Host:"))); m != nil {
+			p.next.User = m[1]
+			p.next.Host = m[2]
+			if len(m[3]) > 0 {
+				p.next.Host = m[3]
+			}
+			p.next.ThreadID, _ = strconv.ParseUint(m[4], 10, 64)
+		}
+		return done
+	case strings.HasPrefix(line, "# ") && len(p.query) == 0:
+		if p.next == nil {
+			return nil
+		}
+		for _, m := range attrRe.FindAllStringSubmatch(line, -1) {
+			p.next.setAttr(m[1], m[2])
+		}
+		return nil
+	case isServerHeader(line):
+		return nil
+	}
+
+	if p.next == nil {
+		return nil
+	}
+	if len(p.query) == 0 {
+		if m := useRe.FindStringSubmatch(line); m != nil {
+			p.schema = m[1]
+			p.next.Schema = m[1]
+			return nil
+		}
+		if setTsRe.MatchString(line) || len(strings.TrimSpace(line)) == 0 {
+			return nil
+		}
+	}
+	p.query = append(p.query, line)
+	return nil
+}
+
+// flush returns the entry read so far, nil without query
+func (p *Parser) flush() *Entry {
+	e := p.next
+	query := strings.TrimSpace(strings.Join(p.query, "\n"))
+	p.next = nil
+	p.query = p.query[:0]
+	if e == nil || len(query) == 0 {
+		return nil
+	}
+	e.Query = query
+	return e
+}
+
+func (e *Entry) setAttr(name, value string) {
+	switch name {
+	case "Query_time":
+		e.QueryTime, _ = strconv.ParseFloat(value, 64)
+	case "Lock_time":
+		e.LockTime, _ = strconv.ParseFloat(value, 64)
+	case "Rows_sent":
+		e.RowsSent, _ = strconv.ParseUint(value, 10, 64)
+	case "Rows_examined":
+		e.RowsExamined, _ = strconv.ParseUint(value, 10, 64)
+	case "Schema":
+		e.Schema = value
+	}
+}
+
+// isServerHeader matches the lines mysqld writes when it (re)opens the slow log
+func isServerHeader(line string) bool {
+	return strings.Contains(line, ", Version: ") && strings.Contains(line, "started with:") ||
+		strings.HasPrefix(line, "Tcp port: ") ||
+		strings.HasPrefix(line, "Time ") && strings.Contains(line, "Id Command") && strings.HasSuffix(strings.TrimSpace(line), "Argument")
+}
+
+// tableEntry reads a row of mysql.slow_log exported with mysql --batch
+func (p *Parser) tableEntry(line string) (*Entry, error) {
+	fields := strings.Split(line, "\t")
+	field := func(name string) string {
+		if index, ok := p.columns[name]; ok && index < len(fields) {
+			return fields[index]
+		}
+		return ""
+	}
+	if len(fields) < len(p.columns) {
+		return nil, fmt.Errorf("mysql.slow_log row with %d of %d columns: %.80s", len(fields), len(p.columns), line)
+	}
+
+	e := &Entry{Time: field("start_time"), Schema: field("db"), Query: unescapeBatch(field("sql_text"))}
+	if m := userHostRe.FindStringSubmatch(field("user_host")); m != nil {
+		e.User = m[1]
+		e.Host = m[2]
+		if len(m[3]) > 0 {
+			e.Host = m[3]
+		}
+	}
+	e.QueryTime = clockSeconds(field("query_time"))
+	e.LockTime = clockSeconds(field("lock_time"))
+	e.RowsSent, _ = strconv.ParseUint(field("rows_sent"), 10, 64)
+	e.RowsExamined, _ = strconv.ParseUint(field("rows_examined"), 10, 64)
+	e.ThreadID, _ = strconv.ParseUint(field("thread_id"), 10, 64)
+	return e, nil
+}
+
+// clockSeconds converts the hh:mm:ss.ffffff times of mysql.slow_log to seconds
+func clockSeconds(clock string) float64 {
+	var seconds float64
+	for _, part := range strings.Split(clock, ":") {
+		n, _ := strconv.ParseFloat(part, 64)
+		seconds = seconds*60 + n
+	}
+	return seconds
+}
+
+// unescapeBatch reverts the escaping of mysql --batch: \n, \t, \\ and \0
+func unescapeBatch(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for index := 0; index < len(s); index++ {
+		if s[index] != '\\' || index+1 == len(s) {
+			b.WriteByte(s[index])
+			continue
+		}
+		index++
+		switch s[index] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case '0':
+			b.WriteByte(0)
+		default:
+			b.WriteByte(s[index])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,141 @@
+package slowlog
+
+import (
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func readEntries(t *testing.T, r io.Reader) []*Entry {
+	t.Helper()
+	p := NewParser(r)
+	var entries []*Entry
+	for {
+		e, err := p.Next()
+		if errors.Is(err, io.EOF) {
+			return entries
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+}
+
+func openSample(t *testing.T, name string) *os.File {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func TestParserFile(t *testing.T) {
+	entries := readEntries(t, openSample(t, "slow_80.log"))
+	if len(entries) != 5 {
+		t.Fatalf("got %d entries, want 5", len(entries))
+	}
+
+	want := Entry{
+		Time: "2023-02-01T10:00:01.123456Z", User: "app", Host: "10.0.3.17", ThreadID: 812, Schema: "orders",
+		QueryTime: 2.5, LockTime: 0.0001, RowsSent: 1, RowsExamined: 1000000,
+		Query: "SELECT * FROM orders WHERE customer_id = 42 AND status = 'open';",
+	}
+	if !reflect.DeepEqual(*entries[0], want) {
+		t.Errorf("entry 1 =\n%+v\nwant\n%+v", *entries[0], want)
+	}
+
+	// the schema carries over entries without use
+	if entries[1].Schema != "orders" || entries[1].Query != "select *\n  from orders\n where customer_id = 7   and status = \"shipped\";" {
+		t.Errorf("multi line entry = %+v", entries[1])
+	}
+	if entries[2].Host != "10.0.4.2" || entries[2].Schema != "reporting" || !strings.HasPrefix(entries[2].Query, "-- daily report\n") {
+		t.Errorf("entry 3 = %+v", entries[2])
+	}
+	// the server header lines after entry 3 are not part of its query
+	if strings.Contains(entries[2].Query, "Tcp port") || entries[3].ThreadID != 5 {
+		t.Errorf("entries around the server header = %+v %+v", entries[2], entries[3])
+	}
+	if entries[4].QueryTime != 4 || entries[4].Schema != "orders" {
+		t.Errorf("last entry = %+v", entries[4])
+	}
+}
+
+func TestParserTable(t *testing.T) {
+	entries := readEntries(t, openSample(t, "slow_log_table.tsv"))
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	want := Entry{
+		Time: "2023-02-01 10:00:01.123456", User: "app", Host: "10.0.3.17", ThreadID: 812, Schema: "orders",
+		QueryTime: 2.5, LockTime: 0.0001, RowsSent: 1, RowsExamined: 1000000,
+		Query: "SELECT *\n  FROM orders WHERE id = 42",
+	}
+	if !reflect.DeepEqual(*entries[0], want) {
+		t.Errorf("row 1 =\n%+v\nwant\n%+v", *entries[0], want)
+	}
+	if entries[1].QueryTime != 65.25 || entries[1].Host != "localhost" {
+		t.Errorf("row 2 = %+v", entries[1])
+	}
+}
+
+func TestParserTableWithoutSQLText(t *testing.T) {
+	_, err := NewParser(strings.NewReader("start_time\tuser_host\n")).Next()
+	if !errors.Is(err, ErrBadTableHeader) {
+		t.Errorf("err = %v, want ErrBadTableHeader", err)
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	a := NewAggregator()
+	for _, e := range readEntries(t, openSample(t, "slow_80.log")) {
+		a.Add(e)
+	}
+	classes := a.Classes()
+	if len(classes) != 3 {
+		t.Fatalf("got %d classes, want 3", len(classes))
+	}
+
+	top := classes[0]
+	if top.Fingerprint != "select count(*) from order_items where order_id in(?+)" || top.Count != 2 {
+		t.Fatalf("top class = %+v", top)
+	}
+	if top.QueryTime.Total != 14 || top.QueryTime.Avg != 7 || top.QueryTime.Max != 10 || top.QueryTime.Min != 4 {
+		t.Errorf("query time = %+v", top.QueryTime)
+	}
+	if top.Sample.QueryTime != 10 || top.FirstSeen != "2023-02-01T10:00:04.000000Z" || top.LastSeen != "2023-02-01T11:00:01.000000Z" {
+		t.Errorf("sample and seen = %+v %s %s", top.Sample, top.FirstSeen, top.LastSeen)
+	}
+	if !reflect.DeepEqual(top.Schemas, []string{"reporting", "orders"}) {
+		t.Errorf("schemas = %v", top.Schemas)
+	}
+	if math.Abs(top.Share-14.0/18.5) > 1e-9 {
+		t.Errorf("share = %v", top.Share)
+	}
+
+	selects := classes[1]
+	if selects.Count != 2 || selects.RowsExamined.Total != 1900000 {
+		t.Errorf("second class = %+v", selects)
+	}
+}
+
+func TestStatsP95(t *testing.T) {
+	s := Stats{}
+	for value := 1; value <= 100; value++ {
+		s.add(float64(value))
+	}
+	s.finish()
+	if s.P95 < 95 || s.P95 > 95*bucketGrowth {
+		t.Errorf("p95 = %v, want 95 within %v", s.P95, bucketGrowth)
+	}
+	if s.Avg != 50.5 {
+		t.Errorf("avg = %v", s.Avg)
+	}
+}